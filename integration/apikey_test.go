@@ -0,0 +1,97 @@
+package integration
+
+import (
+	"dmt/internal"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	t.Run("Create, use, rotate and revoke key", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var createResponse map[string]interface{}
+		req := JSONRequestWithApiKey("POST", "/api/v1/keys", []byte(`{"name":"dashboard","scopes":["devices:read"]}`))
+		makeRequest(t, app, req, http.StatusCreated, &createResponse)
+
+		secret := createResponse["secret"].(string)
+		id := int(createResponse["key"].(map[string]interface{})["id"].(float64))
+		require.NotEmpty(t, secret)
+
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices", secret), http.StatusOK, nil)
+
+		var listResponse map[string]interface{}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/keys", nil), http.StatusOK, &listResponse)
+		require.Equal(t, float64(1), listResponse["count"])
+		listedKey := listResponse["keys"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "dashboard", listedKey["name"])
+		assert.NotNil(t, listedKey["last_used_at"])
+		assert.NotContains(t, listedKey, "secret_hash")
+
+		var rotateResponse map[string]interface{}
+		req = JSONRequestWithApiKey("POST", fmt.Sprintf("/api/v1/keys/%d/rotate", id), nil)
+		makeRequest(t, app, req, http.StatusOK, &rotateResponse)
+		rotatedSecret := rotateResponse["secret"].(string)
+
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices", secret), http.StatusUnauthorized, nil)
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices", rotatedSecret), http.StatusOK, nil)
+
+		req = JSONRequestWithApiKey("DELETE", fmt.Sprintf("/api/v1/keys/%d", id), nil)
+		makeRequest(t, app, req, http.StatusOK, nil)
+
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices", rotatedSecret), http.StatusUnauthorized, nil)
+
+		req = JSONRequestWithApiKey("DELETE", fmt.Sprintf("/api/v1/keys/%d", id), nil)
+		makeRequest(t, app, req, http.StatusNotFound, nil)
+	})
+
+	t.Run("Expired key is rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		req := JSONRequestWithApiKey("POST", "/api/v1/keys", []byte(`{"name":"old","scopes":["devices:read"],"expires_at":"2000-01-01T00:00:00Z"}`))
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+	})
+
+	t.Run("Read-only key cannot write", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var createResponse map[string]interface{}
+		req := JSONRequestWithApiKey("POST", "/api/v1/keys", []byte(`{"name":"helpdesk","scopes":["devices:read"]}`))
+		makeRequest(t, app, req, http.StatusCreated, &createResponse)
+		secret := createResponse["secret"].(string)
+
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices", secret), http.StatusOK, nil)
+		makeRequest(t, app, RequestWithKey("DELETE", "/api/v1/devices/1", secret), http.StatusForbidden, nil)
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/keys", secret), http.StatusForbidden, nil)
+
+		resp, err := app.Test(RequestWithKey("DELETE", "/api/v1/devices/1/employee", secret), 5000)
+		require.NoError(t, err)
+		var forbidden map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&forbidden))
+		assert.Equal(t, "assignments:write", forbidden["missing_scope"])
+	})
+
+	t.Run("Unknown scope is rejected", func(t *testing.T) {
+		req := JSONRequestWithApiKey("POST", "/api/v1/keys", []byte(`{"name":"x","scopes":["devices:everything"]}`))
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+	})
+
+	t.Run("Unknown key is rejected", func(t *testing.T) {
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices", "dmt_000000000000_abcdef"), http.StatusUnauthorized, nil)
+	})
+}
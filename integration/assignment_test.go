@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"dmt/internal"
+	"dmt/pkg/device"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssignmentHistory(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	t.Run("Employee changes are recorded", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+		ctx := t.Context()
+
+		testDevice := createTestDevice(withEmployee("jdo"))
+		require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+
+		beforeReassign := time.Now().UTC()
+
+		err := device.UpdateDevice(ctx, db, testDevice, &device.DeviceUpdate{Employee: stringPtr("jsm")})
+		require.NoError(t, err)
+
+		err = device.UpdateDevice(ctx, db, testDevice, &device.DeviceUpdate{Employee: stringPtr("")})
+		require.NoError(t, err)
+
+		var deviceResponse map[string]interface{}
+		req := JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/devices/%d/assignments", testDevice.ID), nil)
+		makeRequest(t, app, req, http.StatusOK, &deviceResponse)
+		require.Equal(t, float64(2), deviceResponse["count"])
+
+		assignments := deviceResponse["assignments"].([]interface{})
+		latest := assignments[0].(map[string]interface{})
+		assert.Equal(t, "jsm", latest["employee"])
+		assert.NotNil(t, latest["unassigned_at"], "Expected assignment to be closed after unassigning")
+
+		var employeeResponse map[string]interface{}
+		req = JSONRequestWithApiKey("GET", "/api/v1/employees/jdo/assignments", nil)
+		makeRequest(t, app, req, http.StatusOK, &employeeResponse)
+		assert.Equal(t, float64(1), employeeResponse["count"])
+
+		var atResponse map[string]interface{}
+		req = JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/employees/jdo/assignments?at=%s", beforeReassign.Format(time.RFC3339Nano)), nil)
+		makeRequest(t, app, req, http.StatusOK, &atResponse)
+		assert.Equal(t, float64(1), atResponse["count"], "Expected jdo to hold the device before reassignment")
+
+		var afterResponse map[string]interface{}
+		req = JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/employees/jsm/assignments?from=%s", time.Now().UTC().Add(time.Minute).Format(time.RFC3339)), nil)
+		makeRequest(t, app, req, http.StatusOK, &afterResponse)
+		assert.Equal(t, float64(0), afterResponse["count"], "Expected jsm to hold nothing after unassignment")
+	})
+
+	t.Run("Deleting a device closes its assignment", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+		ctx := t.Context()
+
+		testDevice := createTestDevice(withEmployee("jdo"))
+		require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+		require.NoError(t, device.DeleteDevice(ctx, db, testDevice))
+
+		var response map[string]interface{}
+		req := JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/devices/%d/assignments", testDevice.ID), nil)
+		makeRequest(t, app, req, http.StatusOK, &response)
+		require.Equal(t, float64(1), response["count"])
+		assert.NotNil(t, response["assignments"].([]interface{})[0].(map[string]interface{})["unassigned_at"])
+	})
+
+	t.Run("Invalid time range", func(t *testing.T) {
+		req := JSONRequestWithApiKey("GET", "/api/v1/employees/jdo/assignments?from=yesterday", nil)
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+	})
+}
@@ -0,0 +1,219 @@
+package integration
+
+import (
+	"dmt/internal"
+	"dmt/pkg/device"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const workstationSchema = `{
+	"type": "object",
+	"required": ["ram_gb", "cpu"],
+	"additionalProperties": false,
+	"properties": {
+		"ram_gb": {"type": "integer", "minimum": 4},
+		"cpu": {"type": "string", "enum": ["M3", "i7", "Ryzen 7"]},
+		"os": {"type": "object", "properties": {"name": {"type": "string"}}}
+	}
+}`
+
+func TestDeviceAttributes(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	createWorkstationType := func(t *testing.T) {
+		body := fmt.Sprintf(`{"name":"workstation","display_name":"Workstation","attribute_schema":%s}`, workstationSchema)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/device-types", []byte(body)), http.StatusCreated, nil)
+	}
+
+	workstation := func(attributes map[string]any) *device.Device {
+		d := createTestDevice(withType("workstation"))
+		d.Attributes = attributes
+		return d
+	}
+
+	type validationResponse struct {
+		Error  string              `json:"error"`
+		Fields []device.FieldError `json:"fields"`
+	}
+
+	listDevices := func(t *testing.T, filters ...string) []device.Device {
+		query := ""
+		for _, filter := range filters {
+			query += "&" + url.QueryEscape(filter)
+		}
+		var response struct {
+			Devices []device.Device `json:"devices"`
+		}
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices?limit=100"+query, testAPIKey), http.StatusOK, &response)
+		return response.Devices
+	}
+
+	t.Run("Attributes Are Validated Against The Type Schema", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createWorkstationType(t)
+
+		body, err := json.Marshal(workstation(map[string]any{"ram_gb": 2, "gpu": "RTX"}))
+		require.NoError(t, err)
+
+		var response validationResponse
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices", body), http.StatusBadRequest, &response)
+		assert.ElementsMatch(t, []device.FieldError{
+			{Field: "attributes.cpu", Message: "is required"},
+			{Field: "attributes.ram_gb", Message: "must be at least 4"},
+			{Field: "attributes.gpu", Message: "is not allowed"},
+		}, response.Fields)
+
+		valid := workstation(map[string]any{"ram_gb": 32, "cpu": "M3"})
+		require.NoError(t, device.InsertDevice(ctx, db, valid))
+
+		fetched := &device.Device{ID: valid.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, fetched))
+		assert.Equal(t, map[string]any{"ram_gb": float64(32), "cpu": "M3"}, fetched.Attributes)
+
+		// Types without a schema take any attributes.
+		laptop := createTestDevice()
+		laptop.Attributes = map[string]any{"sticker": "blue"}
+		require.NoError(t, device.InsertDevice(ctx, db, laptop))
+	})
+
+	t.Run("Patches Are Merged Into The Attributes Before Validation", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createWorkstationType(t)
+
+		d := workstation(map[string]any{"ram_gb": 16, "cpu": "i7", "os": map[string]any{"name": "Linux"}})
+		require.NoError(t, device.InsertDevice(ctx, db, d))
+
+		patch := func(body string, expectedStatus int, response any) {
+			req := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", d.ID), []byte(body))
+			makeRequest(t, app, req, expectedStatus, response)
+		}
+
+		var updated struct {
+			Device device.Device `json:"device"`
+		}
+		patch(`{"attributes":{"ram_gb":64}}`, http.StatusOK, &updated)
+		assert.Equal(t, map[string]any{"ram_gb": float64(64), "cpu": "i7", "os": map[string]any{"name": "Linux"}}, updated.Device.Attributes)
+
+		patch(`{"attributes":{"os":null}}`, http.StatusOK, &updated)
+		assert.Equal(t, map[string]any{"ram_gb": float64(64), "cpu": "i7"}, updated.Device.Attributes)
+
+		var response validationResponse
+		patch(`{"attributes":{"cpu":null}}`, http.StatusBadRequest, &response)
+		assert.Equal(t, []device.FieldError{{Field: "attributes.cpu", Message: "is required"}}, response.Fields)
+
+		// Switching to a type with a schema validates the existing attributes.
+		laptop := createTestDevice()
+		require.NoError(t, device.InsertDevice(ctx, db, laptop))
+		req := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", laptop.ID), []byte(`{"type":"workstation"}`))
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+
+		unchanged := &device.Device{ID: d.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, unchanged))
+		assert.Equal(t, "i7", unchanged.Attributes["cpu"])
+	})
+
+	t.Run("Invalid Schemas Are Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		body := `{"name":"monitor","display_name":"Monitor","attribute_schema":{"properties":{"size":{"type":"inch"}}}}`
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/device-types", []byte(body)), http.StatusBadRequest, nil)
+
+		body = `{"name":"monitor","display_name":"Monitor","attribute_schema":{"oneOf":[]}}`
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/device-types", []byte(body)), http.StatusBadRequest, nil)
+
+		// Unsupported keywords are rejected at any depth instead of being ignored.
+		var errorResponse map[string]any
+		body = `{"name":"monitor","display_name":"Monitor","attribute_schema":{"properties":{"contact":{"type":"string","format":"email"}}}}`
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/device-types", []byte(body)), http.StatusBadRequest, &errorResponse)
+		assert.Contains(t, fmt.Sprint(errorResponse), "format is not supported")
+
+		body = `{"name":"monitor","display_name":"Monitor","attribute_schema":{"properties":{"size_in":{"type":"number","exclusiveMinimum":0}}}}`
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/device-types", []byte(body)), http.StatusCreated, nil)
+
+		req := JSONRequestWithApiKey("PATCH", "/api/v1/device-types/monitor", []byte(`{"attribute_schema":null}`))
+		var response struct {
+			DeviceType device.DeviceType `json:"device_type"`
+		}
+		makeRequest(t, app, req, http.StatusOK, &response)
+		assert.Nil(t, response.DeviceType.AttributeSchema)
+	})
+
+	t.Run("Devices Can Be Filtered By Attributes", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createWorkstationType(t)
+
+		small := workstation(map[string]any{"ram_gb": 8, "cpu": "i7"})
+		large := workstation(map[string]any{"ram_gb": 32, "cpu": "M3", "os": map[string]any{"name": "macOS"}})
+		huge := workstation(map[string]any{"ram_gb": 64, "cpu": "Ryzen 7"})
+		for _, d := range []*device.Device{small, large, huge, createTestDevice()} {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+
+		ids := func(devices []device.Device) []int {
+			result := []int{}
+			for _, d := range devices {
+				result = append(result, d.ID)
+			}
+			return result
+		}
+
+		assert.ElementsMatch(t, []int{large.ID, huge.ID}, ids(listDevices(t, "attr.ram_gb>=16")))
+		assert.ElementsMatch(t, []int{large.ID}, ids(listDevices(t, "attr.ram_gb>=16", "attr.ram_gb<64")))
+		assert.ElementsMatch(t, []int{huge.ID}, ids(listDevices(t, "attr.cpu=Ryzen 7")))
+		assert.ElementsMatch(t, []int{small.ID, huge.ID}, ids(listDevices(t, "attr.cpu!=M3")))
+		assert.ElementsMatch(t, []int{large.ID}, ids(listDevices(t, "attr.os.name=macOS")))
+
+		// The filters work unencoded as well, as long as the URL allows them.
+		var response struct {
+			Devices []device.Device `json:"devices"`
+			Total   int             `json:"total"`
+			Next    *string         `json:"next"`
+		}
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices?attr.ram_gb>16&limit=1", testAPIKey), http.StatusOK, &response)
+		assert.Equal(t, 2, response.Total)
+		require.NotNil(t, response.Next)
+		assert.Contains(t, *response.Next, url.QueryEscape("attr.ram_gb>16"))
+
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices?"+url.QueryEscape("attr.ram gb>1"), testAPIKey), http.StatusBadRequest, nil)
+	})
+
+	t.Run("Bulk Operations Take Attribute Filters", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createWorkstationType(t)
+
+		old := workstation(map[string]any{"ram_gb": 4, "cpu": "i7"})
+		current := workstation(map[string]any{"ram_gb": 32, "cpu": "M3"})
+		for _, d := range []*device.Device{old, current} {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+
+		var report device.BulkReport
+		body := `{"action":"delete","filter":{"attributes":["ram_gb<8"]}}`
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices/bulk", []byte(body)), http.StatusOK, &report)
+
+		remaining := &device.Device{ID: current.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, remaining))
+		count, err := device.CountDevices(ctx, db, &device.DeviceFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
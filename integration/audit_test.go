@@ -0,0 +1,129 @@
+package integration
+
+import (
+	"dmt/internal"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	t.Run("Mutating calls are audited", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		jsonData, err := json.Marshal(createTestDevice())
+		require.NoError(t, err)
+
+		var createResponse map[string]interface{}
+		req := JSONRequestWithApiKey("POST", "/api/v1/devices", jsonData)
+		makeRequest(t, app, req, http.StatusCreated, &createResponse)
+		id := int(createResponse["device"].(map[string]interface{})["id"].(float64))
+
+		req = JSONRequestWithApiKey("PUT", fmt.Sprintf("/api/v1/devices/%d/employee", id), []byte(`{"employee":"jdo"}`))
+		makeRequest(t, app, req, http.StatusOK, nil)
+
+		req = JSONRequestWithApiKey("DELETE", fmt.Sprintf("/api/v1/devices/%d", id), nil)
+		makeRequest(t, app, req, http.StatusOK, nil)
+
+		var auditResponse map[string]interface{}
+		req = JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/audit?device_id=%d", id), nil)
+		makeRequest(t, app, req, http.StatusOK, &auditResponse)
+		require.Equal(t, float64(3), auditResponse["count"])
+
+		entries := auditResponse["entries"].([]interface{})
+		deleted := entries[0].(map[string]interface{})
+		assigned := entries[1].(map[string]interface{})
+		created := entries[2].(map[string]interface{})
+
+		assert.Equal(t, "delete", deleted["action"])
+		assert.NotNil(t, deleted["before"])
+		assert.Nil(t, deleted["after"])
+
+		assert.Equal(t, "assign", assigned["action"])
+		assert.Nil(t, assigned["before"].(map[string]interface{})["employee"])
+		assert.Equal(t, "jdo", assigned["after"].(map[string]interface{})["employee"])
+
+		assert.Equal(t, "create", created["action"])
+		assert.Nil(t, created["before"])
+		assert.NotEmpty(t, created["actor"])
+		assert.NotEmpty(t, created["request_id"])
+
+		var filteredResponse map[string]interface{}
+		req = JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/audit?action=create&actor=%s", created["actor"]), nil)
+		makeRequest(t, app, req, http.StatusOK, &filteredResponse)
+		assert.Equal(t, float64(1), filteredResponse["count"])
+	})
+
+	t.Run("Audit log is append-only", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		jsonData, err := json.Marshal(createTestDevice())
+		require.NoError(t, err)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices", jsonData), http.StatusCreated, nil)
+
+		for _, statement := range []string{"UPDATE audit_log SET actor = 'someone'", "DELETE FROM audit_log", "TRUNCATE audit_log"} {
+			_, err := db.Exec(ctx, statement)
+			assert.ErrorContains(t, err, "audit_log is append-only", statement)
+		}
+
+		var count int
+		require.NoError(t, db.QueryRow(ctx, "SELECT COUNT(*) FROM audit_log").Scan(&count))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Changes fail without their audit entry", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		device := createTestDevice()
+		jsonData, err := json.Marshal(device)
+		require.NoError(t, err)
+
+		var createResponse map[string]interface{}
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices", jsonData), http.StatusCreated, &createResponse)
+		id := int(createResponse["device"].(map[string]interface{})["id"].(float64))
+
+		_, err = db.Exec(ctx, `
+			CREATE FUNCTION audit_log_reject() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'audit log unavailable';
+			END;
+			$$ LANGUAGE plpgsql;
+			CREATE TRIGGER audit_log_reject_trigger BEFORE INSERT ON audit_log
+				FOR EACH ROW EXECUTE FUNCTION audit_log_reject();
+		`)
+		require.NoError(t, err)
+		defer db.Exec(ctx, "DROP TRIGGER audit_log_reject_trigger ON audit_log; DROP FUNCTION audit_log_reject();")
+
+		req := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", id), []byte(`{"name":"Renamed"}`))
+		makeRequest(t, app, req, http.StatusInternalServerError, nil)
+
+		jsonData, err = json.Marshal(createTestDevice())
+		require.NoError(t, err)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices", jsonData), http.StatusInternalServerError, nil)
+
+		var name string
+		var count int
+		require.NoError(t, db.QueryRow(ctx, "SELECT name, (SELECT COUNT(*) FROM device) FROM device WHERE id = $1", id).Scan(&name, &count))
+		assert.Equal(t, device.Name, name)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Invalid filters", func(t *testing.T) {
+		req := JSONRequestWithApiKey("GET", "/api/v1/audit?from=yesterday", nil)
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+	})
+}
@@ -0,0 +1,166 @@
+package integration
+
+import (
+	"dmt/internal"
+	"dmt/pkg/device"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceBulkOperations(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	insertDevices := func(t *testing.T, devices []*device.Device) []int {
+		ids := []int{}
+		for _, d := range devices {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+			ids = append(ids, d.ID)
+		}
+		return ids
+	}
+
+	bulk := func(t *testing.T, body string, expectedStatus int) *device.BulkReport {
+		var report device.BulkReport
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices/bulk", []byte(body)), expectedStatus, &report)
+		return &report
+	}
+
+	pendingCounts := func(t *testing.T) map[string][]int {
+		entries, err := device.GetOutboxEntries(ctx, db, device.OutboxPending, 100)
+		require.NoError(t, err)
+
+		counts := map[string][]int{}
+		for _, entry := range entries {
+			var notification device.Notification
+			require.NoError(t, json.Unmarshal(entry.Payload, &notification))
+			counts[notification.Employee] = append(counts[notification.Employee], notification.Count)
+		}
+		return counts
+	}
+
+	clearOutbox := func(t *testing.T) {
+		_, err := db.Exec(ctx, "DELETE FROM notification_outbox")
+		require.NoError(t, err)
+	}
+
+	t.Run("Unassign By IDs Reports Every Device", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		ids := insertDevices(t, createTestDevicesForEmployee(2, "jdo"))
+		unassigned := insertDevices(t, []*device.Device{createTestDevice()})
+
+		body := fmt.Sprintf(`{"action":"unassign","ids":[%d,%d,%d,999999]}`, ids[0], ids[1], unassigned[0])
+		report := bulk(t, body, http.StatusOK)
+
+		assert.Equal(t, 3, report.Matched)
+		assert.Equal(t, 2, report.Changed)
+		require.Len(t, report.Results, 4)
+		assert.Equal(t, device.BulkResultUnassigned, report.Results[0].Status)
+		assert.Equal(t, "jdo", *report.Results[0].PreviousEmployee)
+		assert.Nil(t, report.Results[0].Device.Employee)
+		assert.Equal(t, device.BulkResultUnchanged, report.Results[2].Status)
+		assert.Equal(t, device.BulkResultNotFound, report.Results[3].Status)
+
+		count, err := device.CountDevices(ctx, db, &device.DeviceFilter{Employee: "jdo"})
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("Reassign By Filter Notifies Both Owners Once", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		insertDevices(t, createTestDevicesForEmployee(4, "old"))
+		insertDevices(t, createTestDevicesForEmployee(1, "old", withType("phone")))
+		insertDevices(t, createTestDevicesForEmployee(1, "new"))
+		clearOutbox(t)
+
+		report := bulk(t, `{"action":"reassign","filter":{"employee":"old","type":"laptop"},"employee":"new"}`, http.StatusOK)
+		assert.Equal(t, 4, report.Changed)
+		for _, result := range report.Results {
+			assert.Equal(t, device.BulkResultReassigned, result.Status)
+			assert.Equal(t, "new", *result.Device.Employee)
+		}
+
+		counts := pendingCounts(t)
+		assert.Equal(t, []int{5}, counts["new"])
+		assert.Equal(t, []int{1}, counts["old"])
+
+		var assignments int
+		require.NoError(t, db.QueryRow(ctx, "SELECT COUNT(*) FROM device_assignment WHERE employee = 'new' AND unassigned_at IS NULL").Scan(&assignments))
+		assert.Equal(t, 5, assignments)
+	})
+
+	t.Run("Delete Runs In A Single Transaction", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		ids := insertDevices(t, createTestDevicesForEmployee(3, "jdo"))
+		clearOutbox(t)
+
+		body := fmt.Sprintf(`{"action":"delete","ids":[%d,%d,%d]}`, ids[0], ids[1], ids[2])
+		report := bulk(t, body, http.StatusOK)
+		assert.Equal(t, 3, report.Changed)
+		assert.Nil(t, report.Results[0].Device)
+
+		assert.Equal(t, []int{0}, pendingCounts(t)["jdo"])
+
+		count, err := device.CountDevices(ctx, db, &device.DeviceFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("Invalid Requests Are Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		insertDevices(t, createTestDevicesForEmployee(1, "jdo"))
+
+		bulk(t, `{"action":"shred","ids":[1]}`, http.StatusBadRequest)
+		bulk(t, `{"action":"reassign","ids":[1]}`, http.StatusBadRequest)
+		bulk(t, `{"action":"reassign","ids":[1],"employee":"toolong"}`, http.StatusBadRequest)
+		bulk(t, `{"action":"unassign"}`, http.StatusBadRequest)
+		bulk(t, `{"action":"unassign","ids":[1],"filter":{"employee":"jdo"}}`, http.StatusBadRequest)
+		bulk(t, `{"action":"delete","filter":{}}`, http.StatusBadRequest)
+
+		count, err := device.CountDevices(ctx, db, &device.DeviceFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Action Requires Its Scope", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createKey := func(scopes string) string {
+			var createResponse map[string]interface{}
+			body := fmt.Sprintf(`{"name":"helpdesk","scopes":%s}`, scopes)
+			makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/keys", []byte(body)), http.StatusCreated, &createResponse)
+			return createResponse["secret"].(string)
+		}
+
+		bulkWithKey := func(secret string, body string) *http.Request {
+			req := JSONRequestWithApiKey("POST", "/api/v1/devices/bulk", []byte(body))
+			req.Header.Set("Authorization", "Bearer "+base64.StdEncoding.EncodeToString([]byte(secret)))
+			return req
+		}
+
+		// The route itself needs devices:write, whatever the action.
+		assigner := createKey(`["assignments:write"]`)
+		makeRequest(t, app, bulkWithKey(assigner, `{"action":"unassign","filter":{"employee":"jdo"}}`), http.StatusForbidden, nil)
+
+		helpdesk := createKey(`["devices:write","assignments:write"]`)
+		makeRequest(t, app, bulkWithKey(helpdesk, `{"action":"unassign","filter":{"employee":"jdo"}}`), http.StatusOK, nil)
+		makeRequest(t, app, bulkWithKey(helpdesk, `{"action":"delete","filter":{"employee":"jdo"}}`), http.StatusForbidden, nil)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
@@ -25,7 +26,7 @@ func TestDeviceAPI(t *testing.T) {
 	db, err := testDB.GetConnectionPool()
 	require.NoError(t, err)
 
-	app := internal.CreateHttpServer(db, testAPIKey)
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
 
 	t.Run("Create and Get Device", func(t *testing.T) {
 		defer testDB.ClearDB(t)
@@ -138,6 +139,95 @@ func TestDeviceAPI(t *testing.T) {
 		assert.Equal(t, testDevice.MAC, updatedDevice.MAC, "Expected device MAC be the same but is not")
 	})
 
+	t.Run("Patch Device", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+		ctx := t.Context()
+
+		testDevice := createTestDevice(withName("Old Name"), withEmployee("jdo"))
+		err := device.InsertDevice(ctx, db, testDevice)
+		require.NoError(t, err)
+
+		patch := []byte(`{"name":"New Name","ip":"10.1.2.3","employee":null}`)
+		patchReq := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", testDevice.ID), patch)
+		makeRequest(t, app, patchReq, http.StatusOK, nil)
+
+		updatedDevice := &device.Device{ID: testDevice.ID}
+		err = device.GetDeviceByID(ctx, db, updatedDevice)
+		require.NoError(t, err)
+		assert.Equal(t, "New Name", updatedDevice.Name)
+		assert.Equal(t, "10.1.2.3", updatedDevice.IP.String())
+		assert.Nil(t, updatedDevice.Employee)
+		assert.Equal(t, testDevice.Type, updatedDevice.Type)
+		assert.Equal(t, testDevice.MAC, updatedDevice.MAC)
+		assert.Equal(t, testDevice.CreatedAt.Unix(), updatedDevice.CreatedAt.Unix())
+		assert.True(t, updatedDevice.UpdatedAt.After(testDevice.UpdatedAt), "Expected updated_at to be bumped")
+	})
+
+	t.Run("Patch Device - invalid values", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+		ctx := t.Context()
+
+		testDevice := createTestDevice()
+		err := device.InsertDevice(ctx, db, testDevice)
+		require.NoError(t, err)
+
+		invalidType := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", testDevice.ID), []byte(`{"type":"toaster"}`))
+		makeRequest(t, app, invalidType, http.StatusBadRequest, nil)
+
+		nullName := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", testDevice.ID), []byte(`{"name":null}`))
+		makeRequest(t, app, nullName, http.StatusBadRequest, nil)
+
+		unknownField := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", testDevice.ID), []byte(`{"id":42}`))
+		makeRequest(t, app, unknownField, http.StatusBadRequest, nil)
+
+		emptyPatch := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", testDevice.ID), []byte(`{}`))
+		makeRequest(t, app, emptyPatch, http.StatusBadRequest, nil)
+
+		notFound := JSONRequestWithApiKey("PATCH", "/api/v1/devices/9999999", []byte(`{"name":"x"}`))
+		makeRequest(t, app, notFound, http.StatusNotFound, nil)
+	})
+
+	t.Run("Patch Device - duplicate IP", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+		ctx := t.Context()
+
+		first := createTestDevice(withIP("10.0.0.1"))
+		second := createTestDevice(withIP("10.0.0.2"))
+		require.NoError(t, device.InsertDevice(ctx, db, first))
+		require.NoError(t, device.InsertDevice(ctx, db, second))
+
+		patchReq := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", second.ID), []byte(`{"ip":"10.0.0.1"}`))
+		makeRequest(t, app, patchReq, http.StatusConflict, nil)
+	})
+
+	t.Run("Replace Device", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+		ctx := t.Context()
+
+		testDevice := createTestDevice(withEmployee("jdo"))
+		description := "to be removed"
+		testDevice.Description = &description
+		err := device.InsertDevice(ctx, db, testDevice)
+		require.NoError(t, err)
+
+		replacement := createTestDevice(withName("Replaced"), withType("desktop"))
+		jsonData, err := json.Marshal(replacement)
+		require.NoError(t, err)
+
+		putReq := JSONRequestWithApiKey("PUT", fmt.Sprintf("/api/v1/devices/%d", testDevice.ID), jsonData)
+		makeRequest(t, app, putReq, http.StatusOK, nil)
+
+		updatedDevice := &device.Device{ID: testDevice.ID}
+		err = device.GetDeviceByID(ctx, db, updatedDevice)
+		require.NoError(t, err)
+		assert.Equal(t, "Replaced", updatedDevice.Name)
+		assert.Equal(t, "desktop", updatedDevice.Type)
+		assert.Equal(t, replacement.IP, updatedDevice.IP)
+		assert.Equal(t, replacement.MAC, updatedDevice.MAC)
+		assert.Nil(t, updatedDevice.Description, "Expected description to be cleared")
+		assert.Nil(t, updatedDevice.Employee, "Expected employee to be cleared")
+	})
+
 	t.Run("Get Devices with Filters", func(t *testing.T) {
 		defer testDB.ClearDB(t)
 		ctx := t.Context()
@@ -220,6 +310,59 @@ func TestDeviceAPI(t *testing.T) {
 		makeRequest(t, app, getCombinedReq, http.StatusOK, &getCombinedResponse)
 		assert.Equal(t, float64(2), getCombinedResponse["count"], "Expected 2 devices with employee jdo and type laptop but got %0.f", getCombinedResponse["count"])
 	})
+
+	t.Run("Get Devices with Pagination", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+		ctx := t.Context()
+
+		for _, name := range []string{"e", "b", "d", "a", "c"} {
+			err := device.InsertDevice(ctx, db, createTestDevice(withName(name)))
+			require.NoError(t, err)
+		}
+
+		names := []string{}
+		path := "/api/v1/devices?limit=2&sort=name&order=asc"
+		var lastResponse map[string]interface{}
+		for path != "" {
+			req := httptest.NewRequest("GET", path, nil)
+			SetAuthHeader(req)
+
+			lastResponse = map[string]interface{}{}
+			makeRequest(t, app, req, http.StatusOK, &lastResponse)
+			assert.Equal(t, float64(5), lastResponse["total"])
+
+			for _, d := range lastResponse["devices"].([]interface{}) {
+				names = append(names, d.(map[string]interface{})["name"].(string))
+			}
+
+			path = ""
+			if next, ok := lastResponse["next"].(string); ok {
+				nextURL, err := url.Parse(next)
+				require.NoError(t, err)
+				path = nextURL.RequestURI()
+			}
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+
+		prev, ok := lastResponse["prev"].(string)
+		require.True(t, ok, "Expected last page to link to the previous page")
+		prevURL, err := url.Parse(prev)
+		require.NoError(t, err)
+
+		prevReq := httptest.NewRequest("GET", prevURL.RequestURI(), nil)
+		SetAuthHeader(prevReq)
+
+		var prevResponse map[string]interface{}
+		makeRequest(t, app, prevReq, http.StatusOK, &prevResponse)
+		prevDevices := prevResponse["devices"].([]interface{})
+		require.Len(t, prevDevices, 2)
+		assert.Equal(t, "c", prevDevices[0].(map[string]interface{})["name"])
+		assert.Equal(t, "d", prevDevices[1].(map[string]interface{})["name"])
+
+		invalidSortReq := httptest.NewRequest("GET", "/api/v1/devices?sort=password", nil)
+		SetAuthHeader(invalidSortReq)
+		makeRequest(t, app, invalidSortReq, http.StatusBadRequest, nil)
+	})
 }
 
 func makeRequest(t *testing.T, app *fiber.App, req *http.Request, expectedStatus int, response interface{}) {
@@ -227,7 +370,7 @@ func makeRequest(t *testing.T, app *fiber.App, req *http.Request, expectedStatus
 	require.NoError(t, err)
 	require.Equal(t, expectedStatus, resp.StatusCode, "Expected status code %d but got %d", expectedStatus, resp.StatusCode)
 
-	if response != nil && expectedStatus < 300 {
+	if response != nil {
 		err = json.NewDecoder(resp.Body).Decode(response)
 		require.NoError(t, err)
 	}
@@ -242,7 +385,7 @@ func BenchmarkDeviceCreation(b *testing.B) {
 	db, err := testDB.GetConnectionPool()
 	require.NoError(b, err)
 
-	app := internal.CreateHttpServer(db, testAPIKey)
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
 
 	b.ResetTimer()
 
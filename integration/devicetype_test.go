@@ -0,0 +1,156 @@
+package integration
+
+import (
+	"dmt/internal"
+	"dmt/pkg/device"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceTypes(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	createType := func(t *testing.T, body string, expectedStatus int) *device.DeviceType {
+		var response struct {
+			DeviceType device.DeviceType `json:"device_type"`
+		}
+		req := JSONRequestWithApiKey("POST", "/api/v1/device-types", []byte(body))
+		makeRequest(t, app, req, expectedStatus, &response)
+		return &response.DeviceType
+	}
+
+	t.Run("Seeded Types Are Listed", func(t *testing.T) {
+		var response struct {
+			DeviceTypes []device.DeviceType `json:"device_types"`
+			Count       int                 `json:"count"`
+		}
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/device-types", testAPIKey), http.StatusOK, &response)
+
+		assert.Equal(t, 4, response.Count)
+		names := []string{}
+		for _, deviceType := range response.DeviceTypes {
+			names = append(names, deviceType.Name)
+			assert.True(t, deviceType.CountsTowardLimit)
+		}
+		assert.Equal(t, []string{"desktop", "laptop", "phone", "tablet"}, names)
+	})
+
+	t.Run("Created Type Can Be Used By Devices", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		created := createType(t, `{"name":"server","display_name":"Server","icon":"server","required_fields":["ip"]}`, http.StatusCreated)
+		assert.Equal(t, "Server", created.DisplayName)
+		assert.Equal(t, []string{"ip"}, created.RequiredFields)
+		assert.True(t, created.CountsTowardLimit)
+
+		createType(t, `{"name":"server","display_name":"Server"}`, http.StatusConflict)
+
+		server := createTestDevice(withType("server"))
+		require.NoError(t, device.InsertDevice(ctx, db, server))
+
+		fetched := &device.DeviceType{}
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/device-types/server", testAPIKey), http.StatusOK, fetched)
+		assert.Equal(t, "server", fetched.Name)
+	})
+
+	t.Run("Invalid Types Are Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createType(t, `{"name":"Server!","display_name":"Server"}`, http.StatusBadRequest)
+		createType(t, `{"name":"server"}`, http.StatusBadRequest)
+		createType(t, `{"name":"server","display_name":"Server","required_fields":["serial"]}`, http.StatusBadRequest)
+
+		body, err := json.Marshal(createTestDevice(withType("toaster")))
+		require.NoError(t, err)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices", body), http.StatusBadRequest, nil)
+	})
+
+	t.Run("Required Fields Are Enforced", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createType(t, `{"name":"server","display_name":"Server","required_fields":["ip"]}`, http.StatusCreated)
+
+		withoutIP := createTestDevice(withType("server"))
+		withoutIP.IP = nil
+		err := device.InsertDevice(ctx, db, withoutIP)
+		assert.ErrorIs(t, err, device.ErrValidation)
+		assert.ErrorContains(t, err, "ip is required for server devices")
+
+		server := createTestDevice(withType("server"))
+		require.NoError(t, device.InsertDevice(ctx, db, server))
+
+		req := JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", server.ID), []byte(`{"ip":null}`))
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+
+		unchanged := &device.Device{ID: server.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, unchanged))
+		assert.Equal(t, server.IP.String(), unchanged.IP.String())
+
+		laptop := createTestDevice(withType("laptop"))
+		laptop.IP = nil
+		require.NoError(t, device.InsertDevice(ctx, db, laptop))
+
+		req = JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/devices/%d", laptop.ID), []byte(`{"type":"server"}`))
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+	})
+
+	t.Run("Types Not Counting Toward The Limit Are Left Out Of The Total", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createType(t, `{"name":"monitor","display_name":"Monitor","counts_toward_limit":false}`, http.StatusCreated)
+
+		for _, d := range createTestDevicesForEmployee(2, "jdo", withType("monitor")) {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+		require.NoError(t, device.InsertDevice(ctx, db, createTestDevice(withEmployee("jdo"))))
+
+		counts, err := device.GetDeviceCounts(ctx, db)
+		require.NoError(t, err)
+		require.Len(t, counts, 1)
+		assert.Equal(t, 1, counts[0].Count)
+		assert.Equal(t, 2, counts[0].Types["monitor"])
+
+		req := JSONRequestWithApiKey("PATCH", "/api/v1/device-types/monitor", []byte(`{"counts_toward_limit":true}`))
+		var response struct {
+			DeviceType device.DeviceType `json:"device_type"`
+		}
+		makeRequest(t, app, req, http.StatusOK, &response)
+		assert.True(t, response.DeviceType.CountsTowardLimit)
+
+		counts, err = device.GetDeviceCounts(ctx, db)
+		require.NoError(t, err)
+		require.Len(t, counts, 1)
+		assert.Equal(t, 3, counts[0].Count)
+	})
+
+	t.Run("Types In Use Cannot Be Deleted", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createType(t, `{"name":"server","display_name":"Server"}`, http.StatusCreated)
+		server := createTestDevice(withType("server"))
+		require.NoError(t, device.InsertDevice(ctx, db, server))
+
+		makeRequest(t, app, RequestWithKey("DELETE", "/api/v1/device-types/server", testAPIKey), http.StatusConflict, nil)
+
+		require.NoError(t, device.DeleteDevice(ctx, db, server))
+		makeRequest(t, app, RequestWithKey("DELETE", "/api/v1/device-types/server", testAPIKey), http.StatusOK, nil)
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/device-types/server", testAPIKey), http.StatusNotFound, nil)
+
+		body, err := json.Marshal(createTestDevice(withType("server")))
+		require.NoError(t, err)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices", body), http.StatusBadRequest, nil)
+	})
+}
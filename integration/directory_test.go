@@ -0,0 +1,173 @@
+package integration
+
+import (
+	"dmt/internal"
+	"dmt/pkg/device"
+	"dmt/pkg/directory"
+	"dmt/pkg/employee"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectorySync(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	ldapServer, err := NewLDAPServer(ctx)
+	require.NoError(t, err)
+	defer ldapServer.Terminate()
+
+	ldapConfig := directory.LDAPConfig{
+		URL:                   ldapServer.URL,
+		BindDN:                ldapAdminDN,
+		BindPassword:          ldapPassword,
+		BaseDN:                ldapBaseDN,
+		Filter:                "(objectClass=inetOrgPerson)",
+		AbbreviationAttribute: "uid",
+		NameAttribute:         "cn",
+		EmailAttribute:        "mail",
+		DepartmentAttribute:   "departmentNumber",
+	}
+
+	syncer := directory.NewSyncer(db, directory.NewLDAPSource(ldapConfig), time.Hour, "test")
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, syncer)
+
+	type syncResponse struct {
+		Sync directory.Sync `json:"sync"`
+	}
+
+	runSync := func(t *testing.T, expectedStatus int) directory.Sync {
+		var response syncResponse
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/directory/sync", nil), expectedStatus, &response)
+		return response.Sync
+	}
+
+	getEmployee := func(t *testing.T, abbreviation string) *employee.Employee {
+		e := &employee.Employee{Abbreviation: abbreviation}
+		require.NoError(t, employee.GetEmployeeByAbbreviation(ctx, db, e))
+		return e
+	}
+
+	t.Run("Sync Without Directory Is Unavailable", func(t *testing.T) {
+		app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/directory/sync", nil), http.StatusServiceUnavailable, nil)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/directory/sync", nil), http.StatusServiceUnavailable, nil)
+	})
+
+	t.Run("Sync Creates, Updates And Deactivates Employees", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var status map[string]interface{}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/directory/sync", nil), http.StatusOK, &status)
+		assert.Nil(t, status["last_sync"])
+
+		sync := runSync(t, http.StatusOK)
+		assert.Equal(t, directory.StatusSucceeded, sync.Status)
+		assert.Equal(t, 3, sync.Created)
+		assert.Equal(t, 0, sync.Deactivated)
+		require.Len(t, sync.SkippedUsers, 1)
+		assert.Equal(t, "toolong", sync.SkippedUsers[0].Abbreviation)
+
+		ada := getEmployee(t, "ada")
+		assert.Equal(t, "Ada Lovelace", ada.Name)
+		assert.Equal(t, "ada@example.org", *ada.Email)
+		assert.Equal(t, "Research", *ada.Department)
+		assert.Equal(t, directory.LDAPSourceName, ada.Source)
+		assert.True(t, ada.Active)
+		assert.Nil(t, getEmployee(t, "cid").Email)
+
+		sync = runSync(t, http.StatusOK)
+		assert.Equal(t, 0, sync.Created)
+		assert.Equal(t, 0, sync.Updated)
+
+		require.NoError(t, ldapServer.Modify(ctx, "dn: uid=cid,ou=people,dc=example,dc=org\nchangetype: modify\nreplace: mail\nmail: cid@example.org\n"))
+
+		sync = runSync(t, http.StatusOK)
+		assert.Equal(t, 1, sync.Updated)
+		assert.Equal(t, "cid@example.org", *getEmployee(t, "cid").Email)
+
+		bobsDevice := createTestDevice(withEmployee("bob"))
+		require.NoError(t, device.InsertDevice(ctx, db, bobsDevice))
+
+		require.NoError(t, ldapServer.Run(ctx, "ldapdelete", "uid=bob,ou=people,dc=example,dc=org"))
+
+		sync = runSync(t, http.StatusOK)
+		assert.Equal(t, 1, sync.Deactivated)
+		assert.Equal(t, []string{"bob"}, sync.DeactivatedEmployees)
+		assert.False(t, getEmployee(t, "bob").Active)
+
+		// Manually maintained employees are not part of the directory
+		assert.True(t, getEmployee(t, "jsm").Active)
+
+		var orphans struct {
+			Devices []directory.OrphanedDevice `json:"devices"`
+			Count   int                        `json:"count"`
+		}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/directory/orphaned-devices", nil), http.StatusOK, &orphans)
+		require.Equal(t, 1, orphans.Count)
+		assert.Equal(t, bobsDevice.ID, orphans.Devices[0].DeviceID)
+		assert.Equal(t, "Bob Builder", orphans.Devices[0].EmployeeName)
+
+		var last struct {
+			LastSync directory.Sync `json:"last_sync"`
+		}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/directory/sync", nil), http.StatusOK, &last)
+		assert.Equal(t, sync.ID, last.LastSync.ID)
+		assert.Equal(t, 1, last.LastSync.Deactivated)
+	})
+
+	t.Run("Empty Directory Fails Without Deactivating", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		runSync(t, http.StatusOK)
+
+		emptyConfig := ldapConfig
+		emptyConfig.Filter = "(uid=nobody)"
+		emptySyncer := directory.NewSyncer(db, directory.NewLDAPSource(emptyConfig), time.Hour, "test")
+		app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, emptySyncer)
+
+		var response syncResponse
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/directory/sync", nil), http.StatusBadGateway, &response)
+		assert.Equal(t, directory.StatusFailed, response.Sync.Status)
+		assert.True(t, getEmployee(t, "ada").Active)
+	})
+
+	t.Run("Employees Of Other Sources Are Not Taken Over", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		require.NoError(t, employee.InsertEmployee(ctx, db, &employee.Employee{Abbreviation: "ada", Name: "Ada Manual"}))
+
+		sync := runSync(t, http.StatusOK)
+		reasons := map[string]string{}
+		for _, skipped := range sync.SkippedUsers {
+			reasons[skipped.Abbreviation] = skipped.Reason
+		}
+		assert.Contains(t, reasons["ada"], "another source")
+
+		ada := getEmployee(t, "ada")
+		assert.Equal(t, "Ada Manual", ada.Name)
+		assert.Equal(t, employee.SourceManual, ada.Source)
+	})
+
+	t.Run("Sync Requires Admin Scope", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var createResponse map[string]interface{}
+		req := JSONRequestWithApiKey("POST", "/api/v1/keys", []byte(`{"name":"helpdesk","scopes":["devices:read"]}`))
+		makeRequest(t, app, req, http.StatusCreated, &createResponse)
+		secret := createResponse["secret"].(string)
+
+		makeRequest(t, app, RequestWithKey("POST", "/api/v1/directory/sync", secret), http.StatusForbidden, nil)
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/directory/orphaned-devices", secret), http.StatusOK, nil)
+	})
+}
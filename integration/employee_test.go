@@ -0,0 +1,95 @@
+package integration
+
+import (
+	"dmt/internal"
+	"dmt/pkg/device"
+	"dmt/pkg/employee"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmployeeDirectory(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	t.Run("Create, Update And Delete Employee", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var created struct {
+			Employee employee.Employee `json:"employee"`
+		}
+		body := []byte(`{"abbreviation":"mmu","name":"Max Mustermann","email":"max@example.com","department":"IT"}`)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/employees", body), http.StatusCreated, &created)
+		assert.Equal(t, "mmu", created.Employee.Abbreviation)
+		assert.True(t, created.Employee.Active)
+
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/employees", body), http.StatusConflict, nil)
+
+		var updated struct {
+			Employee employee.Employee `json:"employee"`
+		}
+		patch := []byte(`{"department":null,"active":false}`)
+		makeRequest(t, app, JSONRequestWithApiKey("PATCH", "/api/v1/employees/mmu", patch), http.StatusOK, &updated)
+		assert.Nil(t, updated.Employee.Department)
+		assert.False(t, updated.Employee.Active)
+
+		var inactive map[string]interface{}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/employees?active=false", nil), http.StatusOK, &inactive)
+		assert.Equal(t, float64(1), inactive["count"])
+
+		makeRequest(t, app, JSONRequestWithApiKey("DELETE", "/api/v1/employees/mmu", nil), http.StatusOK, nil)
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/employees/mmu", nil), http.StatusNotFound, nil)
+	})
+
+	t.Run("Invalid Employees Are Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/employees", []byte(`{"abbreviation":"toolong","name":"X"}`)), http.StatusBadRequest, nil)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/employees", []byte(`{"abbreviation":"xyz","name":""}`)), http.StatusBadRequest, nil)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/employees", []byte(`{"abbreviation":"xyz","name":"X","email":"nope"}`)), http.StatusBadRequest, nil)
+		makeRequest(t, app, JSONRequestWithApiKey("PATCH", "/api/v1/employees/jdo", []byte(`{"abbreviation":"jdx"}`)), http.StatusBadRequest, nil)
+	})
+
+	t.Run("Assignments To Unknown Or Inactive Employees Are Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		err := device.InsertDevice(ctx, db, createTestDevice(withEmployee("jdp")))
+		assert.ErrorIs(t, err, employee.ErrUnknown)
+
+		testDevice := createTestDevice(withEmployee("jdo"))
+		require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+
+		deactivate := []byte(`{"active":false}`)
+		makeRequest(t, app, JSONRequestWithApiKey("PATCH", "/api/v1/employees/jsm", deactivate), http.StatusOK, nil)
+
+		var response map[string]interface{}
+		url := fmt.Sprintf("/api/v1/devices/%d/employee", testDevice.ID)
+		makeRequest(t, app, JSONRequestWithApiKey("PUT", url, []byte(`{"employee":"jsm"}`)), http.StatusUnprocessableEntity, &response)
+		assert.Equal(t, "employee jsm is inactive", response["error"])
+
+		makeRequest(t, app, JSONRequestWithApiKey("PUT", url, []byte(`{"employee":"jdp"}`)), http.StatusUnprocessableEntity, &response)
+		assert.Equal(t, "employee jdp does not exist", response["error"])
+
+		created := []byte(`{"name":"Laptop","type":"laptop","mac":"00:1a:2b:3c:4d:5e","employee":"jdp"}`)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices", created), http.StatusUnprocessableEntity, nil)
+
+		// Devices stay with employees deactivated after the assignment.
+		makeRequest(t, app, JSONRequestWithApiKey("PATCH", "/api/v1/employees/jdo", deactivate), http.StatusOK, nil)
+		found := &device.Device{ID: testDevice.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, found))
+		assert.Equal(t, "jdo", *found.Employee)
+
+		makeRequest(t, app, JSONRequestWithApiKey("DELETE", "/api/v1/employees/jdo", nil), http.StatusConflict, nil)
+	})
+}
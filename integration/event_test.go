@@ -0,0 +1,174 @@
+package integration
+
+import (
+	"bufio"
+	"context"
+	"dmt/internal"
+	"dmt/pkg/device"
+	"dmt/pkg/event"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseFrame struct {
+	ID    int64
+	Event string
+	Data  event.Event
+}
+
+// openEventStream connects to the event stream and returns its frames.
+func openEventStream(t *testing.T, ctx context.Context, baseURL string, lastEventID string) <-chan sseFrame {
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/api/v1/events", nil)
+	require.NoError(t, err)
+	SetAuthHeader(req)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	frames := make(chan sseFrame, 100)
+	go func() {
+		defer res.Body.Close()
+		defer close(frames)
+
+		var frame sseFrame
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "id":
+				frame.ID, _ = strconv.ParseInt(value, 10, 64)
+			case "event":
+				frame.Event = value
+			case "data":
+				_ = json.Unmarshal([]byte(value), &frame.Data)
+			case "":
+				if frame.Event != "" {
+					frames <- frame
+				}
+				frame = sseFrame{}
+			}
+		}
+	}()
+
+	return frames
+}
+
+func nextFrame(t *testing.T, frames <-chan sseFrame) sseFrame {
+	select {
+	case frame, ok := <-frames:
+		require.True(t, ok, "Event stream closed")
+		return frame
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timeout waiting for event")
+	}
+	return sseFrame{}
+}
+
+func TestEventStream(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	brokerCtx, stopBroker := context.WithCancel(ctx)
+	defer stopBroker()
+
+	broker := event.NewBroker(db, event.DefaultBrokerConfig, "test")
+	go broker.Run(brokerCtx)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, broker, nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(listener)
+	defer app.Shutdown()
+
+	baseURL := "http://" + listener.Addr().String()
+
+	t.Run("Device Changes Are Streamed", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		require.NoError(t, device.InsertDevice(ctx, db, createTestDevice()))
+		frames := openEventStream(t, streamCtx, baseURL, "")
+
+		testDevice := createTestDevice()
+		require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+		require.NoError(t, device.UpdateDevice(ctx, db, testDevice, &device.DeviceUpdate{Name: stringPtr("renamed")}))
+		require.NoError(t, device.UpdateDevice(ctx, db, testDevice, &device.DeviceUpdate{Employee: stringPtr("sse")}))
+		require.NoError(t, device.UpdateDevice(ctx, db, testDevice, &device.DeviceUpdate{Employee: stringPtr("")}))
+		require.NoError(t, device.DeleteDevice(ctx, db, testDevice))
+
+		expected := []string{
+			event.TypeDeviceCreated,
+			event.TypeDeviceUpdated,
+			event.TypeDeviceAssigned,
+			event.TypeDeviceUnassigned,
+			event.TypeDeviceDeleted,
+		}
+		for _, eventType := range expected {
+			frame := nextFrame(t, frames)
+			assert.Equal(t, eventType, frame.Event)
+			assert.Equal(t, frame.ID, frame.Data.ID)
+			assert.Equal(t, testDevice.ID, frame.Data.DeviceID)
+		}
+	})
+
+	t.Run("Streams Resume After Last-Event-ID", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		testDevices := []*device.Device{createTestDevice(), createTestDevice(), createTestDevice()}
+		for _, testDevice := range testDevices {
+			require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+		}
+
+		events, err := event.GetEventsAfter(ctx, db, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+
+		frames := openEventStream(t, streamCtx, baseURL, fmt.Sprint(events[0].ID))
+
+		assert.Equal(t, events[1].ID, nextFrame(t, frames).ID)
+		assert.Equal(t, events[2].ID, nextFrame(t, frames).ID)
+	})
+
+	t.Run("Invalid Last-Event-ID Is Rejected", func(t *testing.T) {
+		req := JSONRequestWithApiKey("GET", "/api/v1/events", nil)
+		req.Header.Set("Last-Event-ID", "abc")
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+	})
+
+	t.Run("Streams End When The Broker Stops", func(t *testing.T) {
+		frames := openEventStream(t, ctx, baseURL, "")
+		stopBroker()
+
+		select {
+		case _, ok := <-frames:
+			assert.False(t, ok)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "Stream was not closed")
+		}
+	})
+}
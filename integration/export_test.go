@@ -0,0 +1,162 @@
+package integration
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"dmt/internal"
+	"dmt/pkg/device"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceExport(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	export := func(t *testing.T, url string, expectedStatus int) (*http.Response, []byte) {
+		req := httptest.NewRequest("GET", url, nil)
+		SetAuthHeader(req)
+
+		resp, err := app.Test(req, 10000)
+		require.NoError(t, err)
+		require.Equal(t, expectedStatus, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	insertDevices := func(t *testing.T, devices ...*device.Device) {
+		for _, d := range devices {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+	}
+
+	t.Run("CSV Export Applies Filters", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		insertDevices(t, createTestDevicesForEmployee(2, "jdo")...)
+		insertDevices(t, createTestDevice(withEmployee("abc")), createTestDevice())
+
+		resp, body := export(t, "/api/v1/devices/export?employee=jdo", http.StatusOK)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/csv")
+		assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, device.ExportColumns, records[0])
+		assert.Equal(t, "jdo", records[1][8])
+	})
+
+	t.Run("Formulas Are Exported As Text", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		insertDevices(t, createTestDevice(withName(`=HYPERLINK("http://evil.example","x")`)), createTestDevice(withName("@SUM(1)")))
+
+		_, body := export(t, "/api/v1/devices/export?columns=id,name", http.StatusOK)
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, `'=HYPERLINK("http://evil.example","x")`, records[1][1])
+		assert.Equal(t, "'@SUM(1)", records[2][1])
+
+		_, body = export(t, "/api/v1/devices/export?format=xlsx&columns=name", http.StatusOK)
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		require.NoError(t, err)
+		for _, file := range archive.File {
+			if file.Name != "xl/worksheets/sheet1.xml" {
+				continue
+			}
+			r, err := file.Open()
+			require.NoError(t, err)
+			sheet, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.NotContains(t, string(sheet), "<f>")
+			assert.Contains(t, string(sheet), `<c t="inlineStr"><is><t xml:space="preserve">=HYPERLINK`)
+		}
+	})
+
+	t.Run("Export Streams More Rows Than One Batch", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		for i := range 1200 {
+			insertDevices(t, createTestDevice(withIP(fmt.Sprintf("10.0.%d.%d", i/250, i%250+1))))
+		}
+
+		_, body := export(t, "/api/v1/devices/export?format=ndjson&columns=id", http.StatusOK)
+
+		lines := 0
+		lastID := 0
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			var row map[string]int
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+			assert.Greater(t, row["id"], lastID)
+			lastID = row["id"]
+			lines++
+		}
+		assert.Equal(t, 1200, lines)
+	})
+
+	t.Run("NDJSON Export Selects Columns", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		d := createTestDevice(withName("Laptop"), withMAC("00:1a:2b:3c:4d:5e"))
+		insertDevices(t, d)
+
+		resp, body := export(t, "/api/v1/devices/export?format=ndjson&columns=name,mac,employee", http.StatusOK)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+		assert.Equal(t, `{"name":"Laptop","mac":"00:1a:2b:3c:4d:5e","employee":null}`+"\n", string(body))
+	})
+
+	t.Run("XLSX Export Is A Workbook", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		insertDevices(t, createTestDevice(withName("Fish & Chips <1>")))
+
+		_, body := export(t, "/api/v1/devices/export?format=xlsx&columns=id,name", http.StatusOK)
+
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		require.NoError(t, err)
+
+		names := []string{}
+		var sheet []byte
+		for _, file := range archive.File {
+			names = append(names, file.Name)
+			if file.Name == "xl/worksheets/sheet1.xml" {
+				r, err := file.Open()
+				require.NoError(t, err)
+				sheet, err = io.ReadAll(r)
+				require.NoError(t, err)
+			}
+		}
+
+		assert.Contains(t, names, "[Content_Types].xml")
+		assert.Contains(t, names, "xl/workbook.xml")
+		assert.Contains(t, string(sheet), "Fish &amp; Chips &lt;1&gt;")
+	})
+
+	t.Run("Invalid Format Or Column Is Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		export(t, "/api/v1/devices/export?format=pdf", http.StatusBadRequest)
+		export(t, "/api/v1/devices/export?columns=name,secret", http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,159 @@
+package integration
+
+import (
+	"bytes"
+	"dmt/internal"
+	"dmt/pkg/device"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func importRequest(url string, contentType string, body string) *http.Request {
+	req := httptest.NewRequest("POST", url, bytes.NewBufferString(body))
+	SetAuthHeader(req)
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+func TestDeviceImport(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	countDevices := func(t *testing.T) int {
+		count, err := device.CountDevices(ctx, db, &device.DeviceFilter{})
+		require.NoError(t, err)
+		return count
+	}
+
+	validCSV := "name,type,ip,mac,employee\n" +
+		"Laptop 1,laptop,10.0.0.1,00:1a:2b:3c:4d:01,abc\n" +
+		"Phone 1,phone,,00:1a:2b:3c:4d:02,\n"
+
+	invalidCSV := validCSV +
+		"Broken,toaster,10.0.0.300,nope,toolong\n" +
+		"Duplicate,desktop,10.0.0.1,00:1a:2b:3c:4d:03,\n"
+
+	t.Run("CSV Import Creates Devices", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var report device.ImportReport
+		makeRequest(t, app, importRequest("/api/v1/devices/import", "text/csv", validCSV), http.StatusCreated, &report)
+
+		assert.True(t, report.Committed)
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 2, countDevices(t))
+		require.NotNil(t, report.Rows[0].DeviceID)
+
+		imported := &device.Device{ID: *report.Rows[0].DeviceID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, imported))
+		assert.Equal(t, "abc", *imported.Employee)
+		assert.Equal(t, "00:1a:2b:3c:4d:01", imported.MAC.String())
+	})
+
+	t.Run("JSON Import Accepts Device Arrays", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		body := `[{"name":"Tablet","type":"tablet","ip":"10.0.0.9","mac":"ABorPE0H"},{"name":"Desktop","type":"desktop","mac":"00-1a-2b-3c-4d-08"}]`
+
+		var report device.ImportReport
+		makeRequest(t, app, importRequest("/api/v1/devices/import", "application/json", body), http.StatusCreated, &report)
+		assert.Equal(t, 2, report.Created)
+	})
+
+	t.Run("All Or Nothing Reports Every Row And Stores Nothing", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var report device.ImportReport
+		makeRequest(t, app, importRequest("/api/v1/devices/import", "text/csv", invalidCSV), http.StatusUnprocessableEntity, &report)
+
+		assert.False(t, report.Committed)
+		assert.Equal(t, 2, report.Invalid)
+		assert.Equal(t, 0, countDevices(t))
+
+		require.Len(t, report.Rows, 4)
+		assert.Equal(t, device.ImportRowValid, report.Rows[0].Status)
+		assert.Equal(t, device.ImportRowInvalid, report.Rows[2].Status)
+		assert.ElementsMatch(t, []string{"invalid IP address", "invalid MAC address", "invalid device type", "employee must be 3 characters"}, report.Rows[2].Errors)
+		assert.Equal(t, []string{"device with this IP already exists"}, report.Rows[3].Errors)
+	})
+
+	t.Run("Best Effort Stores The Valid Rows", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var report device.ImportReport
+		makeRequest(t, app, importRequest("/api/v1/devices/import?mode=best_effort", "text/csv", invalidCSV), http.StatusCreated, &report)
+
+		assert.True(t, report.Committed)
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 2, report.Invalid)
+		assert.Equal(t, 2, countDevices(t))
+	})
+
+	t.Run("Dry Run Stores Nothing", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var report device.ImportReport
+		makeRequest(t, app, importRequest("/api/v1/devices/import?dry_run=true", "text/csv", validCSV), http.StatusOK, &report)
+
+		assert.True(t, report.DryRun)
+		assert.False(t, report.Committed)
+		assert.Equal(t, 0, report.Invalid)
+		assert.Equal(t, 0, countDevices(t))
+	})
+
+	t.Run("Malformed Imports Are Rejected", func(t *testing.T) {
+		makeRequest(t, app, importRequest("/api/v1/devices/import", "text/csv", "name,color\nx,red\n"), http.StatusBadRequest, nil)
+		makeRequest(t, app, importRequest("/api/v1/devices/import", "application/json", `{"name":"x"}`), http.StatusBadRequest, nil)
+		makeRequest(t, app, importRequest("/api/v1/devices/import", "text/plain", validCSV), http.StatusUnsupportedMediaType, nil)
+		makeRequest(t, app, importRequest("/api/v1/devices/import?mode=some", "text/csv", validCSV), http.StatusBadRequest, nil)
+	})
+
+	t.Run("Large Bodies Are Only Accepted By The Import", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var csv strings.Builder
+		csv.WriteString("name,type,mac\n")
+		for range 100 {
+			csv.WriteString("Bulk Laptop,laptop,00:1a:2b:3c:4d:ff\n")
+		}
+
+		makeRequest(t, app, importRequest("/api/v1/devices/import", "text/csv", csv.String()), http.StatusCreated, nil)
+		makeRequest(t, app, importRequest("/api/v1/devices", "application/json", csv.String()), http.StatusRequestEntityTooLarge, nil)
+
+		// Large bodies of unauthenticated requests are not even read.
+		req := importRequest("/api/v1/devices/import", "text/csv", csv.String())
+		req.Header.Del("Authorization")
+		makeRequest(t, app, req, http.StatusUnauthorized, nil)
+	})
+
+	t.Run("Multipart Bodies Are Not Read Before The Authentication", func(t *testing.T) {
+		// The body does not match the announced boundary, so the request
+		// would fail if the form was parsed before the handlers run.
+		body := "--other\r\nContent-Disposition: form-data; name=\"file\"; filename=\"devices.csv\"\r\n\r\n" +
+			strings.Repeat("Bulk Laptop,laptop,00:1a:2b:3c:4d:ff\n", 30000) + "\r\n--other--\r\n"
+
+		req := importRequest("/api/v1/devices/import", "multipart/form-data; boundary=boundary", body)
+		req.Header.Del("Authorization")
+
+		resp, err := app.Test(req, 5000)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		// The unread body must not be parsed as the next request.
+		assert.True(t, resp.Close)
+
+		req = importRequest("/api/v1/devices", "multipart/form-data; boundary=boundary", body)
+		makeRequest(t, app, req, http.StatusRequestEntityTooLarge, nil)
+	})
+}
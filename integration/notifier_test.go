@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"dmt/pkg/device"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifiers(t *testing.T) {
+	smtpServer, err := NewSMTPServer()
+	require.NoError(t, err)
+	defer smtpServer.Close()
+
+	var mu sync.Mutex
+	var webhookBodies []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		webhookBodies = append(webhookBodies, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer webhook.Close()
+
+	warning := &device.NotificationRequest{
+		Level:                device.LevelWarning,
+		EmployeeAbbreviation: "jdo",
+		Message:              `Employee jdo has 3 "devices"`,
+	}
+
+	t.Run("Template Webhook Renders JSON", func(t *testing.T) {
+		notifier, err := device.NewTemplateNotifier(webhook.URL, `{"employee": {{json .EmployeeAbbreviation}}, "text": {{json .Message}}}`)
+		require.NoError(t, err)
+
+		require.NoError(t, notifier.Notify(t.Context(), warning))
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, webhookBodies, 1)
+
+		var body map[string]string
+		require.NoError(t, json.Unmarshal([]byte(webhookBodies[0]), &body))
+		assert.Equal(t, "jdo", body["employee"])
+		assert.Equal(t, warning.Message, body["text"])
+
+		webhookBodies = nil
+	})
+
+	t.Run("Invalid Templates Are Rejected", func(t *testing.T) {
+		_, err := device.NewTemplateNotifier(webhook.URL, `{"text": {{json .Message}`)
+		assert.Error(t, err)
+
+		notifier, err := device.NewTemplateNotifier(webhook.URL, `{"text": {{.Message}}}`)
+		require.NoError(t, err)
+		assert.Error(t, notifier.Notify(t.Context(), warning))
+	})
+
+	t.Run("Email Is Sent Via SMTP", func(t *testing.T) {
+		notifier := device.NewEmailNotifier(device.EmailConfig{
+			Addr: smtpServer.Addr(),
+			From: "dmt@example.com",
+			To:   []string{"admin@example.com"},
+		})
+
+		require.NoError(t, notifier.Notify(t.Context(), warning))
+
+		messages := smtpServer.Messages()
+		require.Len(t, messages, 1)
+		assert.Equal(t, "dmt@example.com", messages[0].From)
+		assert.Equal(t, []string{"admin@example.com"}, messages[0].To)
+		assert.Contains(t, messages[0].Data, "Subject: [dmt] warning for employee jdo")
+		assert.Contains(t, messages[0].Data, warning.Message)
+	})
+
+	t.Run("Router Sends Levels To Matching Channels", func(t *testing.T) {
+		chat, err := device.NewTemplateNotifier(webhook.URL, "")
+		require.NoError(t, err)
+
+		router := device.NewRouter(
+			device.Route{Notifier: chat, Levels: []string{device.LevelWarning}},
+			device.Route{Notifier: device.NewEmailNotifier(device.EmailConfig{
+				Addr: smtpServer.Addr(),
+				From: "dmt@example.com",
+				To:   []string{"it@example.com"},
+			})},
+		)
+
+		resolved := &device.NotificationRequest{
+			Level:                device.LevelInfo,
+			EmployeeAbbreviation: "jdo",
+			Message:              "Employee jdo has 2 devices and complies with all policies",
+		}
+
+		before := len(smtpServer.Messages())
+		require.NoError(t, router.Notify(t.Context(), warning))
+		require.NoError(t, router.Notify(t.Context(), resolved))
+
+		assert.Len(t, smtpServer.Messages(), before+2)
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, webhookBodies, 1)
+		assert.Contains(t, webhookBodies[0], "[warning]")
+	})
+
+	t.Run("Router Reports Failing Channels", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer failing.Close()
+
+		router := device.NewRouter(device.Route{Notifier: device.NewWebhookNotifier(failing.URL)})
+
+		err := router.Notify(t.Context(), warning)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "webhook")
+	})
+}
@@ -3,9 +3,14 @@ package integration
 import (
 	"bytes"
 	"context"
+	"dmt/internal"
 	"dmt/pkg/device"
+	"dmt/pkg/dispatch"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -53,8 +58,7 @@ func TestNotifyDeviceCount(t *testing.T) {
 		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
 		defer cancel()
 
-		err := device.HandleDeviceCountNotifications(ctx, db, notificationContainer.GetNotificationURL())
-		assert.NoError(t, err)
+		device.HandleDeviceCountNotifications(ctx, db, device.NewWebhookNotifier(notificationContainer.GetNotificationURL()), "test")
 
 		testDevices := createTestDevicesForEmployee(3, "jdo")
 		for _, testDevice := range testDevices {
@@ -68,16 +72,324 @@ func TestNotifyDeviceCount(t *testing.T) {
 		err = notificationContainer.WaitForLog("jdo has 3 devices", 10*time.Second)
 		assert.NoError(t, err)
 
+		err = notificationContainer.WaitForLog("allows 2 devices, has 3", 10*time.Second)
+		assert.NoError(t, err)
+
 		fourthDevice := createTestDevice(withEmployee("jsm"))
 		err = device.InsertDevice(context.Background(), db, fourthDevice)
 		require.NoError(t, err)
 
-		fourthDevice.Employee = stringPtr("jdo")
-		device.UpdateDevice(context.Background(), db, fourthDevice)
+		device.UpdateDevice(context.Background(), db, fourthDevice, &device.DeviceUpdate{Employee: stringPtr("jdo")})
+
+		require.Eventually(t, func() bool {
+			pending, err := device.GetOutboxEntries(ctx, db, device.OutboxPending, 100)
+			return err == nil && len(pending) == 0
+		}, 5*time.Second, 100*time.Millisecond)
+
+		matches, err := notificationContainer.SearchLogs("jdo has 4 devices")
+		require.NoError(t, err)
+		assert.Empty(t, matches, "Expected no repeated alert while the employee is still in violation")
+
+		require.NoError(t, device.DeleteDevice(context.Background(), db, fourthDevice))
+		require.NoError(t, device.DeleteDevice(context.Background(), db, testDevices[0]))
 
-		err = notificationContainer.WaitForLog("jdo has 4 devices", 10*time.Second)
+		err = notificationContainer.WaitForLog("jdo has 2 devices and complies with all policies", 10*time.Second)
 		assert.NoError(t, err)
 
 		cancel()
 	})
+
+	t.Run("Employee Is Alerted Again After Recovery", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+		defer cancel()
+
+		dispatcher := device.NewDispatcher(db, device.NewWebhookNotifier(notificationContainer.GetNotificationURL()), dispatch.Config{
+			BatchSize:    10,
+			PollInterval: 50 * time.Millisecond,
+			Lease:        time.Minute,
+			MaxAttempts:  3,
+			BaseBackoff:  10 * time.Millisecond,
+			MaxBackoff:   50 * time.Millisecond,
+		})
+		go dispatcher.Run(ctx)
+
+		testDevices := createTestDevicesForEmployee(3, "rep")
+		for _, testDevice := range testDevices {
+			require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+		}
+
+		err := notificationContainer.WaitForLog("rep has 3 devices - global policy", 5*time.Second)
+		require.NoError(t, err)
+
+		err = device.UpdateDevice(ctx, db, testDevices[0], &device.DeviceUpdate{Employee: stringPtr("")})
+		require.NoError(t, err)
+
+		err = notificationContainer.WaitForLog("rep has 2 devices and complies with all policies", 5*time.Second)
+		require.NoError(t, err)
+
+		alerts, err := notificationContainer.SearchLogs("rep has 3 devices - global policy")
+		require.NoError(t, err)
+
+		err = device.UpdateDevice(ctx, db, testDevices[0], &device.DeviceUpdate{Employee: stringPtr("rep")})
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			matches, err := notificationContainer.SearchLogs("rep has 3 devices - global policy")
+			return err == nil && len(matches) > len(alerts)
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("Notifications Raised While Down Are Delivered Later", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		testDevices := createTestDevicesForEmployee(3, "ofl")
+		for _, testDevice := range testDevices {
+			err := device.InsertDevice(t.Context(), db, testDevice)
+			require.NoError(t, err)
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+		defer cancel()
+
+		device.HandleDeviceCountNotifications(ctx, db, device.NewWebhookNotifier(notificationContainer.GetNotificationURL()), "test")
+
+		err := notificationContainer.WaitForLog("ofl has 3 devices", 10*time.Second)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Failing Notifications Are Dead-Lettered", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var attempts atomic.Int32
+		failingService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer failingService.Close()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+		defer cancel()
+
+		dispatcher := device.NewDispatcher(db, device.NewWebhookNotifier(failingService.URL), dispatch.Config{
+			BatchSize:    10,
+			PollInterval: 50 * time.Millisecond,
+			Lease:        time.Minute,
+			MaxAttempts:  3,
+			BaseBackoff:  10 * time.Millisecond,
+			MaxBackoff:   50 * time.Millisecond,
+		})
+		go dispatcher.Run(ctx)
+
+		testDevices := createTestDevicesForEmployee(3, "dlq")
+		for _, testDevice := range testDevices {
+			err := device.InsertDevice(ctx, db, testDevice)
+			require.NoError(t, err)
+		}
+
+		var dead []device.OutboxEntry
+		require.Eventually(t, func() bool {
+			dead, err = device.GetOutboxEntries(ctx, db, device.OutboxDead, 10)
+			return err == nil && len(dead) == 1
+		}, 5*time.Second, 50*time.Millisecond)
+
+		assert.Equal(t, 3, dead[0].Attempts)
+		assert.Equal(t, int32(3), attempts.Load())
+		require.NotNil(t, dead[0].LastError)
+		assert.Contains(t, *dead[0].LastError, "503")
+
+		app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+		var deadResponse map[string]interface{}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/notifications?status=dead", nil), http.StatusOK, &deadResponse)
+		assert.Equal(t, float64(1), deadResponse["count"])
+
+		retryReq := JSONRequestWithApiKey("POST", fmt.Sprintf("/api/v1/notifications/%d/retry", dead[0].ID), nil)
+		makeRequest(t, app, retryReq, http.StatusOK, nil)
+
+		require.Eventually(t, func() bool {
+			return attempts.Load() > 3
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("Retries Superseded By Newer Notifications Are Skipped", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var failing atomic.Bool
+		failing.Store(true)
+		var delivered atomic.Int32
+		service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			delivered.Add(1)
+		}))
+		defer service.Close()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+		defer cancel()
+
+		dispatcher := device.NewDispatcher(db, device.NewWebhookNotifier(service.URL), dispatch.Config{
+			BatchSize:    10,
+			PollInterval: 50 * time.Millisecond,
+			Lease:        time.Minute,
+			MaxAttempts:  1,
+			BaseBackoff:  10 * time.Millisecond,
+			MaxBackoff:   50 * time.Millisecond,
+		})
+		go dispatcher.Run(ctx)
+
+		testDevices := createTestDevicesForEmployee(3, "sup")
+		for _, testDevice := range testDevices {
+			require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+		}
+
+		var dead []device.OutboxEntry
+		require.Eventually(t, func() bool {
+			dead, err = device.GetOutboxEntries(ctx, db, device.OutboxDead, 10)
+			return err == nil && len(dead) == 1
+		}, 5*time.Second, 50*time.Millisecond)
+
+		// The warning is outdated once the employee is back at 2 devices.
+		failing.Store(false)
+		require.NoError(t, device.DeleteDevice(ctx, db, testDevices[0]))
+		require.Eventually(t, func() bool {
+			pending, err := device.GetOutboxEntries(ctx, db, device.OutboxPending, 10)
+			return err == nil && len(pending) == 0
+		}, 5*time.Second, 50*time.Millisecond)
+
+		require.NoError(t, device.RetryOutboxEntry(ctx, db, &dead[0]))
+
+		var retried *device.OutboxEntry
+		require.Eventually(t, func() bool {
+			skipped, err := device.GetOutboxEntries(ctx, db, device.OutboxSkipped, 10)
+			for _, entry := range skipped {
+				if entry.ID == dead[0].ID {
+					retried = &entry
+				}
+			}
+			return err == nil && retried != nil
+		}, 5*time.Second, 50*time.Millisecond)
+
+		require.NotNil(t, retried.LastError)
+		assert.Equal(t, "superseded by a newer notification", *retried.LastError)
+		assert.Equal(t, int32(0), delivered.Load())
+	})
+
+	t.Run("Listener Reconnects After Connection Loss", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Second)
+		defer cancel()
+
+		pipeline := device.HandleDeviceCountNotifications(ctx, db, device.NewWebhookNotifier(notificationContainer.GetNotificationURL()), "test")
+		require.Eventually(t, func() bool {
+			status, err := pipeline.Status(ctx)
+			return err == nil && status.Listener != nil && status.Listener.State == device.ListenerListening
+		}, 15*time.Second, 50*time.Millisecond)
+
+		_, err := db.Exec(ctx, "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query = 'LISTEN device_count'")
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			status, err := pipeline.Status(ctx)
+			return err == nil && status.Listener != nil && status.Listener.State == device.ListenerListening && status.Listener.Reconnects >= 1
+		}, 10*time.Second, 50*time.Millisecond)
+
+		app := internal.CreateHttpServer(db, testAPIKey, "", pipeline, nil, nil)
+
+		var statusResponse map[string]interface{}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/notifications/status", nil), http.StatusOK, &statusResponse)
+		assert.Equal(t, device.ListenerListening, statusResponse["listener"].(map[string]interface{})["state"])
+
+		testDevices := createTestDevicesForEmployee(3, "rec")
+		for _, testDevice := range testDevices {
+			err := device.InsertDevice(ctx, db, testDevice)
+			require.NoError(t, err)
+		}
+
+		err = notificationContainer.WaitForLog("rec has 3 devices", 10*time.Second)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Only One Instance Leads The Pipeline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 30*time.Second)
+		defer cancel()
+
+		// Let pipelines of previous subtests step down
+		require.Eventually(t, func() bool {
+			var holders int
+			err := db.QueryRow(ctx, "SELECT COUNT(*) FROM pg_locks WHERE locktype = 'advisory' AND granted").Scan(&holders)
+			return err == nil && holders == 0
+		}, 10*time.Second, 100*time.Millisecond)
+
+		firstCtx, stopFirst := context.WithCancel(ctx)
+		defer stopFirst()
+
+		first := device.HandleDeviceCountNotifications(firstCtx, db, device.NewWebhookNotifier(notificationContainer.GetNotificationURL()), "first")
+		require.Eventually(t, func() bool {
+			status, err := first.Status(ctx)
+			return err == nil && status.Leader.IsLeader
+		}, 10*time.Second, 50*time.Millisecond)
+
+		second := device.HandleDeviceCountNotifications(ctx, db, device.NewWebhookNotifier(notificationContainer.GetNotificationURL()), "second")
+
+		status, err := second.Status(ctx)
+		require.NoError(t, err)
+		assert.False(t, status.Leader.IsLeader)
+		assert.Nil(t, status.Listener, "Expected follower not to run a listener")
+		require.NotNil(t, status.Leader.Leader)
+		assert.Equal(t, "first", *status.Leader.Leader)
+
+		stopFirst()
+
+		require.Eventually(t, func() bool {
+			status, err := second.Status(ctx)
+			return err == nil && status.Leader.IsLeader && status.Leader.Leader != nil && *status.Leader.Leader == "second"
+		}, 15*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("Policies Decide Which Notifications Are Sent", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Second)
+		defer cancel()
+
+		app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+		req := JSONRequestWithApiKey("POST", "/api/v1/policies", []byte(`{"scope":"type","device_type":"phone","max_devices":1}`))
+		makeRequest(t, app, req, http.StatusCreated, nil)
+
+		req = JSONRequestWithApiKey("POST", "/api/v1/policies", []byte(`{"scope":"employee","employee":"lab","max_devices":5}`))
+		makeRequest(t, app, req, http.StatusCreated, nil)
+
+		req = JSONRequestWithApiKey("POST", "/api/v1/policies", []byte(`{"scope":"type","device_type":"phone","max_devices":3}`))
+		makeRequest(t, app, req, http.StatusConflict, nil)
+
+		dispatcher := device.NewDispatcher(db, device.NewWebhookNotifier(notificationContainer.GetNotificationURL()), device.DefaultDispatcherConfig)
+		go dispatcher.Run(ctx)
+
+		for _, testDevice := range createTestDevicesForEmployee(3, "lab") {
+			require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+		}
+
+		for _, testDevice := range createTestDevicesForEmployee(2, "phn", withType("phone")) {
+			require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+		}
+		dispatcher.Wake()
+
+		err := notificationContainer.WaitForLog("phn has 2 devices - type policy", 15*time.Second)
+		assert.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			pending, err := device.GetOutboxEntries(ctx, db, device.OutboxPending, 100)
+			return err == nil && len(pending) == 0
+		}, 10*time.Second, 100*time.Millisecond)
+
+		matches, err := notificationContainer.SearchLogs("Employee lab has")
+		require.NoError(t, err)
+		assert.Empty(t, matches, "Expected no notification for employee within their override")
+	})
 }
@@ -0,0 +1,156 @@
+package integration
+
+import (
+	"dmt/internal"
+	"dmt/pkg/assignment"
+	"dmt/pkg/device"
+	"dmt/pkg/employee"
+	"dmt/pkg/offboarding"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmployeeOffboarding(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	insertDevices := func(t *testing.T, devices []*device.Device) {
+		for _, d := range devices {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+	}
+
+	offboard := func(t *testing.T, employee string, body string, expectedStatus int) *offboarding.Offboarding {
+		var response struct {
+			Offboarding offboarding.Offboarding `json:"offboarding"`
+		}
+		req := JSONRequestWithApiKey("POST", "/api/v1/employees/"+employee+"/offboard", []byte(body))
+		makeRequest(t, app, req, expectedStatus, &response)
+		return &response.Offboarding
+	}
+
+	returnItem := func(t *testing.T, o *offboarding.Offboarding, item offboarding.Item, expectedStatus int) {
+		url := fmt.Sprintf("/api/v1/offboardings/%d/items/%d/return", o.ID, item.ID)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", url, []byte(`{"note":"at front desk"}`)), expectedStatus, nil)
+	}
+
+	t.Run("Offboarding Unassigns Devices And Records The Reason", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		devices := createTestDevicesForEmployee(2, "lvr")
+		insertDevices(t, devices)
+		insertDevices(t, createTestDevicesForEmployee(1, "stv"))
+
+		result := offboard(t, "lvr", `{"reason":"left the company"}`, http.StatusCreated)
+		assert.Equal(t, "lvr", result.Employee)
+
+		leaver := &employee.Employee{Abbreviation: "lvr"}
+		require.NoError(t, employee.GetEmployeeByAbbreviation(ctx, db, leaver))
+		assert.False(t, leaver.Active)
+		assert.Nil(t, result.CompletedAt)
+		require.Len(t, result.Items, 2)
+		assert.Equal(t, devices[0].ID, *result.Items[0].DeviceID)
+		assert.Equal(t, devices[0].MAC.String(), result.Items[0].MAC.String())
+
+		count, err := device.CountDevices(ctx, db, &device.DeviceFilter{Employee: "lvr"})
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		count, err = device.CountDevices(ctx, db, &device.DeviceFilter{Employee: "stv"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		history, err := assignment.GetDeviceAssignments(ctx, db, devices[0].ID, &assignment.TimeRange{})
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		require.NotNil(t, history[0].UnassignReason)
+		assert.True(t, strings.HasSuffix(*history[0].UnassignReason, "left the company"))
+	})
+
+	t.Run("Offboarding Can Reassign Devices To A Pool", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		devices := createTestDevicesForEmployee(3, "lvr")
+		insertDevices(t, devices)
+
+		result := offboard(t, "lvr", `{"reason":"contract ended","reassign_to":"itp"}`, http.StatusCreated)
+		require.Len(t, result.Items, 3)
+		assert.Equal(t, "itp", *result.ReassignedTo)
+
+		count, err := device.CountDevices(ctx, db, &device.DeviceFilter{Employee: "itp"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		// Released devices are audited with their full rows.
+		var audit struct {
+			Entries []struct {
+				Action string        `json:"action"`
+				Before device.Device `json:"before"`
+				After  device.Device `json:"after"`
+			} `json:"entries"`
+		}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/audit?device_id=%d", devices[0].ID), nil), http.StatusOK, &audit)
+		require.Len(t, audit.Entries, 1)
+		assert.Equal(t, "assign", audit.Entries[0].Action)
+		assert.Equal(t, "lvr", *audit.Entries[0].Before.Employee)
+		assert.Equal(t, "itp", *audit.Entries[0].After.Employee)
+		assert.Equal(t, devices[0].Name, audit.Entries[0].After.Name)
+		assert.Equal(t, devices[0].MAC.String(), audit.Entries[0].After.MAC.String())
+	})
+
+	t.Run("Offboarding Completes Once Every Device Is Returned", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		insertDevices(t, createTestDevicesForEmployee(2, "lvr"))
+		result := offboard(t, "lvr", `{"reason":"left"}`, http.StatusCreated)
+
+		returnItem(t, result, result.Items[0], http.StatusOK)
+		returnItem(t, result, result.Items[0], http.StatusConflict)
+
+		var open map[string]interface{}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/offboardings?status=open", nil), http.StatusOK, &open)
+		assert.Equal(t, float64(1), open["count"])
+
+		returnItem(t, result, result.Items[1], http.StatusOK)
+
+		var completed offboarding.Offboarding
+		makeRequest(t, app, JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/offboardings/%d", result.ID), nil), http.StatusOK, &completed)
+		assert.NotNil(t, completed.CompletedAt)
+		for _, item := range completed.Items {
+			assert.NotNil(t, item.ReturnedAt)
+			assert.Equal(t, "at front desk", *item.Note)
+		}
+	})
+
+	t.Run("Offboarding Without Devices Is Completed", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		result := offboard(t, "nob", `{"reason":"left"}`, http.StatusCreated)
+		assert.Empty(t, result.Items)
+		assert.NotNil(t, result.CompletedAt)
+	})
+
+	t.Run("Invalid Requests Are Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		offboard(t, "toolong", `{"reason":"left"}`, http.StatusBadRequest)
+		offboard(t, "lvr", `{}`, http.StatusBadRequest)
+		offboard(t, "lvr", `{"reason":"left","reassign_to":"lvr"}`, http.StatusBadRequest)
+		offboard(t, "zzz", `{"reason":"left"}`, http.StatusNotFound)
+		offboard(t, "lvr", `{"reason":"left","reassign_to":"zzz"}`, http.StatusUnprocessableEntity)
+
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/offboardings/99/items/1/return", nil), http.StatusNotFound, nil)
+	})
+}
@@ -0,0 +1,239 @@
+package integration
+
+import (
+	"bytes"
+	"dmt/internal"
+	"dmt/pkg/device"
+	"dmt/pkg/employee"
+	"dmt/pkg/offboarding"
+	"dmt/pkg/scim"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSCIMToken = "scim-test-token-0123456789abcdef"
+
+func SCIMRequest(method string, url string, body []byte) *http.Request {
+	req := httptest.NewRequest(method, url, bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+testSCIMToken)
+	req.Header.Set("Content-Type", scim.ContentType)
+
+	return req
+}
+
+func TestSCIMProvisioning(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, testSCIMToken, nil, nil, nil)
+
+	createUser := func(t *testing.T, body string) scim.User {
+		var user scim.User
+		makeRequest(t, app, SCIMRequest("POST", "/scim/v2/Users", []byte(body)), http.StatusCreated, &user)
+		return user
+	}
+
+	t.Run("SCIM Requires Its Own Token", func(t *testing.T) {
+		var scimError scim.Error
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/scim/v2/Users", nil), http.StatusUnauthorized, &scimError)
+		assert.Equal(t, []string{scim.SchemaError}, scimError.Schemas)
+		assert.Equal(t, "401", scimError.Status)
+
+		req := SCIMRequest("GET", "/api/v1/devices", nil)
+		makeRequest(t, app, req, http.StatusUnauthorized, nil)
+
+		disabled := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+		makeRequest(t, disabled, SCIMRequest("GET", "/scim/v2/Users", nil), http.StatusUnauthorized, nil)
+	})
+
+	t.Run("Create And Find User", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		body := `{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"],
+			"userName": "ada",
+			"name": {"givenName": "Ada", "familyName": "Lovelace"},
+			"emails": [{"value": "ada@home.example", "type": "home"}, {"value": "ada@example.org", "type": "work", "primary": true}],
+			"active": true,
+			"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "Research"}
+		}`
+
+		resp, err := app.Test(SCIMRequest("POST", "/scim/v2/Users", []byte(body)), 5000)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, scim.ContentType, resp.Header.Get("Content-Type"))
+		assert.Contains(t, resp.Header.Get("Location"), "/scim/v2/Users/ada")
+
+		ada := &employee.Employee{Abbreviation: "ada"}
+		require.NoError(t, employee.GetEmployeeByAbbreviation(ctx, db, ada))
+		assert.Equal(t, "Ada Lovelace", ada.Name)
+		assert.Equal(t, "ada@example.org", *ada.Email)
+		assert.Equal(t, "Research", *ada.Department)
+		assert.Equal(t, scim.Source, ada.Source)
+
+		var conflict scim.Error
+		makeRequest(t, app, SCIMRequest("POST", "/scim/v2/Users", []byte(body)), http.StatusConflict, &conflict)
+		assert.Equal(t, "uniqueness", conflict.ScimType)
+
+		var list scim.ListResponse
+		makeRequest(t, app, SCIMRequest("GET", `/scim/v2/Users?filter=userName%20eq%20%22ada%22`, nil), http.StatusOK, &list)
+		require.Equal(t, 1, list.TotalResults)
+		assert.Equal(t, "ada", list.Resources[0].ID)
+		assert.Equal(t, "Research", list.Resources[0].Enterprise.Department)
+
+		makeRequest(t, app, SCIMRequest("GET", `/scim/v2/Users?filter=userName%20eq%20%22zzz%22`, nil), http.StatusOK, &list)
+		assert.Equal(t, 0, list.TotalResults)
+		assert.Empty(t, list.Resources)
+
+		var invalidFilter scim.Error
+		makeRequest(t, app, SCIMRequest("GET", `/scim/v2/Users?filter=name.familyName%20co%20%22L%22`, nil), http.StatusBadRequest, &invalidFilter)
+		assert.Equal(t, "invalidFilter", invalidFilter.ScimType)
+
+		var page scim.ListResponse
+		makeRequest(t, app, SCIMRequest("GET", "/scim/v2/Users?startIndex=2&count=3", nil), http.StatusOK, &page)
+		assert.Equal(t, len(testEmployees)+1, page.TotalResults)
+		assert.Equal(t, 3, page.ItemsPerPage)
+		assert.Equal(t, 2, page.StartIndex)
+		require.Len(t, page.Resources, 3)
+
+		var last scim.ListResponse
+		makeRequest(t, app, SCIMRequest("GET", fmt.Sprintf("/scim/v2/Users?startIndex=%d", len(testEmployees)+1), nil), http.StatusOK, &last)
+		assert.Equal(t, len(testEmployees)+1, last.TotalResults)
+		require.Len(t, last.Resources, 1)
+		assert.Greater(t, last.Resources[0].ID, page.Resources[2].ID)
+
+		var totalOnly scim.ListResponse
+		makeRequest(t, app, SCIMRequest("GET", "/scim/v2/Users?count=0", nil), http.StatusOK, &totalOnly)
+		assert.Equal(t, len(testEmployees)+1, totalOnly.TotalResults)
+		assert.Empty(t, totalOnly.Resources)
+	})
+
+	t.Run("Invalid Users Are Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var invalid scim.Error
+		makeRequest(t, app, SCIMRequest("POST", "/scim/v2/Users", []byte(`{"userName":"toolong","displayName":"X"}`)), http.StatusBadRequest, &invalid)
+		assert.Equal(t, "invalidValue", invalid.ScimType)
+
+		makeRequest(t, app, SCIMRequest("POST", "/scim/v2/Users", []byte(`{"displayName":"X"}`)), http.StatusBadRequest, nil)
+		makeRequest(t, app, SCIMRequest("POST", "/scim/v2/Users", []byte(`{`)), http.StatusBadRequest, nil)
+		makeRequest(t, app, SCIMRequest("GET", "/scim/v2/Users/zzz", nil), http.StatusNotFound, nil)
+	})
+
+	t.Run("Replace And Patch User", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createUser(t, `{"userName":"bob","displayName":"Bob Builder","emails":[{"value":"bob@example.org"}]}`)
+
+		var replaced scim.User
+		body := `{"userName":"bob","displayName":"Robert Builder","active":true}`
+		makeRequest(t, app, SCIMRequest("PUT", "/scim/v2/Users/bob", []byte(body)), http.StatusOK, &replaced)
+		assert.Equal(t, "Robert Builder", replaced.DisplayName)
+		assert.Empty(t, replaced.Emails)
+
+		var mutability scim.Error
+		body = `{"userName":"rob","displayName":"Robert Builder"}`
+		makeRequest(t, app, SCIMRequest("PUT", "/scim/v2/Users/bob", []byte(body)), http.StatusBadRequest, &mutability)
+		assert.Equal(t, "mutability", mutability.ScimType)
+
+		var patched scim.User
+		body = `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [
+				{"op": "Add", "path": "emails[type eq \"work\"].value", "value": "rob@example.org"},
+				{"op": "Replace", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", "value": "Facilities"},
+				{"op": "Replace", "path": "name.givenName", "value": "Rob"},
+				{"op": "Replace", "value": {"displayName": "Rob Builder"}}
+			]
+		}`
+		makeRequest(t, app, SCIMRequest("PATCH", "/scim/v2/Users/bob", []byte(body)), http.StatusOK, &patched)
+		assert.Equal(t, "Rob Builder", patched.DisplayName)
+		require.Len(t, patched.Emails, 1)
+		assert.Equal(t, "rob@example.org", patched.Emails[0].Value)
+		assert.Equal(t, "Facilities", patched.Enterprise.Department)
+
+		body = `{"Operations": [{"op": "Remove", "path": "emails"}]}`
+		makeRequest(t, app, SCIMRequest("PATCH", "/scim/v2/Users/bob", []byte(body)), http.StatusOK, &patched)
+		assert.Empty(t, patched.Emails)
+
+		body = `{"Operations": [{"op": "Replace", "path": "active", "value": "maybe"}]}`
+		makeRequest(t, app, SCIMRequest("PATCH", "/scim/v2/Users/bob", []byte(body)), http.StatusBadRequest, nil)
+	})
+
+	t.Run("Deactivating User Releases Devices", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createUser(t, `{"userName":"cid","displayName":"Cid Highwind"}`)
+		devices := createTestDevicesForEmployee(2, "cid")
+		for _, d := range devices {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+
+		// Some identity providers send booleans as strings
+		var patched scim.User
+		body := `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"Replace","path":"active","value":"False"}]}`
+		makeRequest(t, app, SCIMRequest("PATCH", "/scim/v2/Users/cid", []byte(body)), http.StatusOK, &patched)
+		assert.False(t, *patched.Active)
+
+		count, err := device.CountDevices(ctx, db, &device.DeviceFilter{Employee: "cid"})
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		offboardings, err := offboarding.GetOffboardings(ctx, db, &offboarding.Filter{Employee: "cid"})
+		require.NoError(t, err)
+		require.Len(t, offboardings, 1)
+		assert.Equal(t, scim.DeprovisionReason, offboardings[0].Reason)
+		assert.Equal(t, "scim", offboardings[0].CreatedBy)
+		assert.Len(t, offboardings[0].Items, 2)
+
+		// Deactivating again doesn't start another offboarding
+		makeRequest(t, app, SCIMRequest("PATCH", "/scim/v2/Users/cid", []byte(body)), http.StatusOK, nil)
+		offboardings, err = offboarding.GetOffboardings(ctx, db, &offboarding.Filter{Employee: "cid"})
+		require.NoError(t, err)
+		assert.Len(t, offboardings, 1)
+
+		body = `{"Operations":[{"op":"Replace","path":"active","value":true}]}`
+		makeRequest(t, app, SCIMRequest("PATCH", "/scim/v2/Users/cid", []byte(body)), http.StatusOK, &patched)
+		assert.True(t, *patched.Active)
+	})
+
+	t.Run("Deleting User Releases Devices", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		createUser(t, `{"userName":"dan","displayName":"Dan Delete"}`)
+		d := createTestDevice(withEmployee("dan"))
+		require.NoError(t, device.InsertDevice(ctx, db, d))
+
+		makeRequest(t, app, SCIMRequest("DELETE", "/scim/v2/Users/dan", nil), http.StatusNoContent, nil)
+		makeRequest(t, app, SCIMRequest("GET", "/scim/v2/Users/dan", nil), http.StatusNotFound, nil)
+		makeRequest(t, app, SCIMRequest("DELETE", "/scim/v2/Users/dan", nil), http.StatusNotFound, nil)
+
+		released := &device.Device{ID: d.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, released))
+		assert.Nil(t, released.Employee)
+
+		offboardings, err := offboarding.GetOffboardings(ctx, db, &offboarding.Filter{Employee: "dan"})
+		require.NoError(t, err)
+		require.Len(t, offboardings, 1)
+		assert.Len(t, offboardings[0].Items, 1)
+
+		// Inactive users without devices are deleted without offboarding
+		createUser(t, `{"userName":"eve","displayName":"Eve Inactive","active":false}`)
+		makeRequest(t, app, SCIMRequest("DELETE", "/scim/v2/Users/eve", nil), http.StatusNoContent, nil)
+		makeRequest(t, app, SCIMRequest("GET", "/scim/v2/Users/eve", nil), http.StatusNotFound, nil)
+
+		offboardings, err = offboarding.GetOffboardings(ctx, db, &offboarding.Filter{Employee: "eve"})
+		require.NoError(t, err)
+		assert.Empty(t, offboardings)
+	})
+}
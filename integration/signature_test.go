@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"dmt/pkg/device"
+	"dmt/pkg/signature"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"level":"warning","employeeAbbreviation":"jdo","message":"Employee jdo has 3 devices"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	t.Run("Notifications Carry A Verifiable Signature", func(t *testing.T) {
+		verified := make(chan error, 1)
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := signature.VerifyRequest(r, signature.DefaultTolerance, "current")
+			verified <- err
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer receiver.Close()
+
+		notifier := device.NewWebhookNotifier(receiver.URL, "current", "previous")
+		require.NoError(t, notifier.Notify(t.Context(), &device.NotificationRequest{Level: device.LevelWarning, Message: "test"}))
+		assert.NoError(t, <-verified)
+	})
+
+	t.Run("Either Active Secret Is Accepted During Rotation", func(t *testing.T) {
+		timestamp, err := strconv.ParseInt(now, 10, 64)
+		require.NoError(t, err)
+		header := signature.Header(timestamp, body, "new", "old")
+
+		assert.NoError(t, signature.Verify(body, now, header, signature.DefaultTolerance, "old"))
+		assert.NoError(t, signature.Verify(body, now, header, signature.DefaultTolerance, "new"))
+		assert.ErrorIs(t, signature.Verify(body, now, header, signature.DefaultTolerance, "other"), signature.ErrInvalidSignature)
+	})
+
+	t.Run("Tampered Requests Are Rejected", func(t *testing.T) {
+		timestamp, err := strconv.ParseInt(now, 10, 64)
+		require.NoError(t, err)
+		header := signature.Header(timestamp, body, "secret")
+
+		tampered := []byte(`{"level":"info"}`)
+		assert.ErrorIs(t, signature.Verify(tampered, now, header, signature.DefaultTolerance, "secret"), signature.ErrInvalidSignature)
+
+		later := strconv.FormatInt(timestamp+1, 10)
+		assert.ErrorIs(t, signature.Verify(body, later, header, signature.DefaultTolerance, "secret"), signature.ErrInvalidSignature)
+	})
+
+	t.Run("Old Or Unsigned Requests Are Rejected", func(t *testing.T) {
+		old := time.Now().Add(-time.Hour).Unix()
+		header := signature.Header(old, body, "secret")
+
+		err := signature.Verify(body, strconv.FormatInt(old, 10), header, signature.DefaultTolerance, "secret")
+		assert.ErrorIs(t, err, signature.ErrInvalidTimestamp)
+
+		err = signature.Verify(body, "", "", signature.DefaultTolerance, "secret")
+		assert.ErrorIs(t, err, signature.ErrMissingSignature)
+	})
+}
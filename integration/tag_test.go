@@ -0,0 +1,216 @@
+package integration
+
+import (
+	"dmt/internal"
+	"dmt/pkg/device"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceTags(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	type deviceResponse struct {
+		Device device.Device `json:"device"`
+	}
+
+	addTags := func(t *testing.T, id int, body string, expectedStatus int) device.Device {
+		var response deviceResponse
+		req := JSONRequestWithApiKey("POST", fmt.Sprintf("/api/v1/devices/%d/tags", id), []byte(body))
+		makeRequest(t, app, req, expectedStatus, &response)
+		return response.Device
+	}
+
+	listDevices := func(t *testing.T, query string) []int {
+		var response struct {
+			Devices []device.Device `json:"devices"`
+		}
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices?limit=100&"+query, testAPIKey), http.StatusOK, &response)
+
+		ids := []int{}
+		for _, d := range response.Devices {
+			ids = append(ids, d.ID)
+		}
+		return ids
+	}
+
+	t.Run("Tags Can Be Added And Removed", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		d := createTestDevice()
+		require.NoError(t, device.InsertDevice(ctx, db, d))
+
+		tagged := addTags(t, d.ID, `{"tags":["Loaner"," floor-3 ","loaner"]}`, http.StatusOK)
+		assert.Equal(t, []string{"floor-3", "loaner"}, tagged.Tags)
+
+		// Adding a tag the device already carries is no error.
+		tagged = addTags(t, d.ID, `{"tags":["loaner","spare"]}`, http.StatusOK)
+		assert.Equal(t, []string{"floor-3", "loaner", "spare"}, tagged.Tags)
+
+		var response deviceResponse
+		req := RequestWithKey("DELETE", fmt.Sprintf("/api/v1/devices/%d/tags/loaner", d.ID), testAPIKey)
+		makeRequest(t, app, req, http.StatusOK, &response)
+		assert.Equal(t, []string{"floor-3", "spare"}, response.Device.Tags)
+
+		makeRequest(t, app, req, http.StatusNotFound, nil)
+
+		fetched := &device.Device{ID: d.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, fetched))
+		assert.Equal(t, []string{"floor-3", "spare"}, fetched.Tags)
+	})
+
+	t.Run("Invalid Tags Are Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		d := createTestDevice()
+		require.NoError(t, device.InsertDevice(ctx, db, d))
+
+		addTags(t, d.ID, `{"tags":[]}`, http.StatusBadRequest)
+		addTags(t, d.ID, `{"tags":["no spaces"]}`, http.StatusBadRequest)
+		addTags(t, d.ID, `{"tags":["-leading-dash"]}`, http.StatusBadRequest)
+		addTags(t, d.ID, `{"tags":["loaner"]}`, http.StatusOK)
+
+		addTags(t, d.ID+1000, `{"tags":["loaner"]}`, http.StatusNotFound)
+		makeRequest(t, app, RequestWithKey("DELETE", fmt.Sprintf("/api/v1/devices/%d/tags/loaner", d.ID+1000), testAPIKey), http.StatusNotFound, nil)
+	})
+
+	t.Run("Tags Are Listed With Their Usage", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		first := createTestDevice()
+		second := createTestDevice()
+		for _, d := range []*device.Device{first, second} {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+		addTags(t, first.ID, `{"tags":["loaner","spare"]}`, http.StatusOK)
+		addTags(t, second.ID, `{"tags":["loaner","unused"]}`, http.StatusOK)
+		makeRequest(t, app, RequestWithKey("DELETE", fmt.Sprintf("/api/v1/devices/%d/tags/unused", second.ID), testAPIKey), http.StatusOK, nil)
+
+		var response struct {
+			Tags  []device.Tag `json:"tags"`
+			Count int          `json:"count"`
+		}
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/tags", testAPIKey), http.StatusOK, &response)
+		assert.Equal(t, 3, response.Count)
+		assert.Equal(t, []device.Tag{
+			{Name: "loaner", Devices: 2},
+			{Name: "spare", Devices: 1},
+			{Name: "unused", Devices: 0},
+		}, response.Tags)
+
+		makeRequest(t, app, RequestWithKey("DELETE", "/api/v1/tags/loaner", testAPIKey), http.StatusOK, nil)
+		makeRequest(t, app, RequestWithKey("DELETE", "/api/v1/tags/loaner", testAPIKey), http.StatusNotFound, nil)
+
+		fetched := &device.Device{ID: first.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, fetched))
+		assert.Equal(t, []string{"spare"}, fetched.Tags)
+	})
+
+	t.Run("Deleting A Tag Audits Every Device", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		tagged := createTestDevice()
+		untagged := createTestDevice()
+		for _, d := range []*device.Device{tagged, untagged} {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+		before := addTags(t, tagged.ID, `{"tags":["loaner","spare"]}`, http.StatusOK)
+
+		makeRequest(t, app, RequestWithKey("DELETE", "/api/v1/tags/loaner", testAPIKey), http.StatusOK, nil)
+
+		fetched := &device.Device{ID: tagged.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, fetched))
+		assert.Equal(t, []string{"spare"}, fetched.Tags)
+		assert.True(t, fetched.UpdatedAt.After(before.UpdatedAt))
+
+		var audit struct {
+			Entries []struct {
+				Action string        `json:"action"`
+				Before device.Device `json:"before"`
+				After  device.Device `json:"after"`
+			} `json:"entries"`
+		}
+		makeRequest(t, app, RequestWithKey("GET", fmt.Sprintf("/api/v1/audit?device_id=%d", tagged.ID), testAPIKey), http.StatusOK, &audit)
+		require.Len(t, audit.Entries, 2)
+		assert.Equal(t, "update", audit.Entries[0].Action)
+		assert.Equal(t, []string{"loaner", "spare"}, audit.Entries[0].Before.Tags)
+		assert.Equal(t, []string{"spare"}, audit.Entries[0].After.Tags)
+
+		makeRequest(t, app, RequestWithKey("GET", fmt.Sprintf("/api/v1/audit?device_id=%d", untagged.ID), testAPIKey), http.StatusOK, &audit)
+		assert.Empty(t, audit.Entries)
+	})
+
+	t.Run("Devices Can Be Filtered By Tags", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		loaner := createTestDevice()
+		spare := createTestDevice()
+		both := createTestDevice()
+		untagged := createTestDevice()
+		for _, d := range []*device.Device{loaner, spare, both, untagged} {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+		addTags(t, loaner.ID, `{"tags":["loaner"]}`, http.StatusOK)
+		addTags(t, spare.ID, `{"tags":["spare"]}`, http.StatusOK)
+		addTags(t, both.ID, `{"tags":["loaner","spare"]}`, http.StatusOK)
+
+		assert.ElementsMatch(t, []int{loaner.ID, both.ID}, listDevices(t, "tag=loaner"))
+		assert.ElementsMatch(t, []int{both.ID}, listDevices(t, "tag=loaner,spare"))
+		assert.ElementsMatch(t, []int{both.ID}, listDevices(t, "tag=loaner&tag=spare"))
+		assert.ElementsMatch(t, []int{loaner.ID, spare.ID, both.ID}, listDevices(t, "tag_any=loaner,spare"))
+		assert.ElementsMatch(t, []int{spare.ID, untagged.ID}, listDevices(t, "tag_none=loaner"))
+		assert.ElementsMatch(t, []int{loaner.ID}, listDevices(t, "tag=loaner&tag_none=spare"))
+		assert.ElementsMatch(t, []int{}, listDevices(t, "tag=unknown"))
+
+		var response struct {
+			Total int     `json:"total"`
+			Next  *string `json:"next"`
+		}
+		makeRequest(t, app, RequestWithKey("GET", "/api/v1/devices?tag_any=spare,loaner&limit=1", testAPIKey), http.StatusOK, &response)
+		assert.Equal(t, 3, response.Total)
+		require.NotNil(t, response.Next)
+		assert.Contains(t, *response.Next, "tag_any=spare%2Cloaner")
+	})
+
+	t.Run("Bulk Operations Take Tag Filters", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		broken := createTestDevice()
+		working := createTestDevice()
+		for _, d := range []*device.Device{broken, working} {
+			require.NoError(t, device.InsertDevice(ctx, db, d))
+		}
+		addTags(t, broken.ID, `{"tags":["broken"]}`, http.StatusOK)
+
+		// Filters holding only blank tags would select every device.
+		for _, filter := range []string{`{"tags":[" "]}`, `{"tags_any":[","]}`, `{"tags_none":[""]}`} {
+			body := fmt.Sprintf(`{"action":"delete","filter":%s}`, filter)
+			makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices/bulk", []byte(body)), http.StatusBadRequest, nil)
+		}
+		count, err := device.CountDevices(ctx, db, &device.DeviceFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		var report device.BulkReport
+		body := `{"action":"delete","filter":{"tags_none":["broken"]}}`
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/devices/bulk", []byte(body)), http.StatusOK, &report)
+
+		remaining := &device.Device{ID: broken.ID}
+		require.NoError(t, device.GetDeviceByID(ctx, db, remaining))
+		count, err = device.CountDevices(ctx, db, &device.DeviceFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
@@ -24,6 +24,13 @@ const (
 	dbPassword = "testpass"
 )
 
+// testEmployees are the employees devices are assigned to in the tests. They
+// are created with every clean database.
+var testEmployees = []string{
+	"abc", "dlq", "itp", "jdo", "jsm", "lab", "lvr", "new", "nob",
+	"ofl", "old", "phn", "rec", "rep", "sse", "stv", "whk",
+}
+
 type TestContainer struct {
 	Container  testcontainers.Container
 	ConnString string
@@ -56,6 +63,11 @@ func NewTestDB(ctx context.Context) (*TestContainer, error) {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	err = seedEmployees(ctx, connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seed employees: %w", err)
+	}
+
 	tc := &TestContainer{
 		Container:  container,
 		ConnString: connStr,
@@ -82,10 +94,49 @@ func (tc *TestContainer) ClearDB(t *testing.T) {
 	}
 	defer conn.Close(ctx)
 
-	_, err = conn.Exec(ctx, "TRUNCATE TABLE device RESTART IDENTITY CASCADE")
+	// The audit log refuses TRUNCATE unless triggers are disabled, which
+	// requires a superuser.
+	_, err = conn.Exec(ctx, `
+		BEGIN;
+		SET LOCAL session_replication_role = replica;
+		TRUNCATE TABLE device, device_assignment, audit_log, api_key, notification_outbox, employee_alert_state, webhook_subscription, webhook_delivery, device_event, offboarding, offboarding_item, directory_sync, employee, device_tag, tag RESTART IDENTITY CASCADE;
+		COMMIT;
+	`)
 	if err != nil {
 		t.Fatalf("Failed to clear database: %v", err)
 	}
+
+	_, err = conn.Exec(ctx, "DELETE FROM notification_policy WHERE scope <> 'global'; UPDATE notification_policy SET max_devices = 2")
+	if err != nil {
+		t.Fatalf("Failed to reset notification policies: %v", err)
+	}
+
+	_, err = conn.Exec(ctx, `
+		DELETE FROM device_type WHERE name NOT IN ('desktop', 'laptop', 'phone', 'tablet');
+		UPDATE device_type SET required_fields = '{}', counts_toward_limit = TRUE, attribute_schema = NULL
+	`)
+	if err != nil {
+		t.Fatalf("Failed to reset device types: %v", err)
+	}
+
+	if err := seedEmployees(ctx, tc.ConnString); err != nil {
+		t.Fatalf("Failed to seed employees: %v", err)
+	}
+}
+
+func seedEmployees(ctx context.Context, connectionString string) error {
+	conn, err := pgx.Connect(ctx, connectionString)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, `
+		INSERT INTO employee (abbreviation, name)
+			SELECT abbreviation, 'Test Employee ' || abbreviation FROM unnest($1::text[]) AS abbreviation
+			ON CONFLICT DO NOTHING
+	`, testEmployees)
+	return err
 }
 
 func runMigrations(connectionString string) error {
@@ -28,3 +28,10 @@ func SetAuthHeader(req *http.Request) {
 func stringPtr(s string) *string {
 	return &s
 }
+
+func RequestWithKey(method string, url string, key string) *http.Request {
+	req := httptest.NewRequest(method, url, nil)
+	req.Header.Set("Authorization", "Bearer "+base64.StdEncoding.EncodeToString([]byte(key)))
+
+	return req
+}
@@ -0,0 +1,142 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	ldapBaseDN   = "dc=example,dc=org"
+	ldapAdminDN  = "cn=admin," + ldapBaseDN
+	ldapPassword = "admin"
+)
+
+// ldapUsers are the people of the test directory. "toolong" has no valid
+// abbreviation and is skipped by the sync.
+const ldapUsers = `dn: ou=people,dc=example,dc=org
+objectClass: organizationalUnit
+ou: people
+
+dn: uid=ada,ou=people,dc=example,dc=org
+objectClass: inetOrgPerson
+uid: ada
+cn: Ada Lovelace
+sn: Lovelace
+mail: ada@example.org
+departmentNumber: Research
+
+dn: uid=bob,ou=people,dc=example,dc=org
+objectClass: inetOrgPerson
+uid: bob
+cn: Bob Builder
+sn: Builder
+mail: bob@example.org
+departmentNumber: Facilities
+
+dn: uid=cid,ou=people,dc=example,dc=org
+objectClass: inetOrgPerson
+uid: cid
+cn: Cid Highwind
+sn: Highwind
+
+dn: uid=toolong,ou=people,dc=example,dc=org
+objectClass: inetOrgPerson
+uid: toolong
+cn: Too Long
+sn: Long
+`
+
+// LDAPServer is an OpenLDAP container seeded with ldapUsers.
+type LDAPServer struct {
+	Container testcontainers.Container
+	URL       string
+}
+
+func NewLDAPServer(ctx context.Context) (*LDAPServer, error) {
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "osixia/openldap:1.5.0",
+			ExposedPorts: []string{"389/tcp"},
+			Env: map[string]string{
+				"LDAP_ORGANISATION":   "Example",
+				"LDAP_DOMAIN":         "example.org",
+				"LDAP_ADMIN_PASSWORD": ldapPassword,
+				"LDAP_TLS":            "false",
+			},
+			Files: []testcontainers.ContainerFile{{
+				Reader:            strings.NewReader(ldapUsers),
+				ContainerFilePath: "/tmp/users.ldif",
+				FileMode:          0o644,
+			}},
+			WaitingFor: wait.ForAll(
+				wait.ForListeningPort("389/tcp"),
+				wait.ForExec([]string{"ldapsearch", "-x", "-H", "ldap://localhost", "-D", ldapAdminDN, "-w", ldapPassword, "-b", ldapBaseDN}),
+			).WithDeadline(time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start OpenLDAP container: %w", err)
+	}
+
+	server := &LDAPServer{Container: container}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host: %w", err)
+	}
+
+	port, err := container.MappedPort(ctx, "389/tcp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get port: %w", err)
+	}
+	server.URL = fmt.Sprintf("ldap://%s:%s", host, port.Port())
+
+	if err := server.Run(ctx, "ldapadd", "-f", "/tmp/users.ldif"); err != nil {
+		return nil, fmt.Errorf("failed to seed users: %w", err)
+	}
+
+	return server, nil
+}
+
+// Run runs an OpenLDAP client tool as the admin inside the container.
+func (s *LDAPServer) Run(ctx context.Context, tool string, args ...string) error {
+	command := append([]string{tool, "-x", "-H", "ldap://localhost", "-D", ldapAdminDN, "-w", ldapPassword}, args...)
+
+	code, output, err := s.Container.Exec(ctx, command)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		message, _ := io.ReadAll(output)
+		return fmt.Errorf("%s exited with %d: %s", tool, code, message)
+	}
+
+	return nil
+}
+
+// Modify applies an LDIF change to the directory.
+func (s *LDAPServer) Modify(ctx context.Context, ldif string) error {
+	command := fmt.Sprintf("printf '%%s' '%s' | ldapmodify -x -H ldap://localhost -D %s -w %s", ldif, ldapAdminDN, ldapPassword)
+
+	code, output, err := s.Container.Exec(ctx, []string{"sh", "-c", command})
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		message, _ := io.ReadAll(output)
+		return fmt.Errorf("ldapmodify exited with %d: %s", code, message)
+	}
+
+	return nil
+}
+
+func (s *LDAPServer) Terminate() error {
+	return s.Container.Terminate(context.Background())
+}
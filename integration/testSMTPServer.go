@@ -0,0 +1,111 @@
+package integration
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// SMTPServer is a minimal SMTP stand-in which accepts every mail and keeps it
+// in memory.
+type SMTPServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []SMTPMessage
+}
+
+type SMTPMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+func NewSMTPServer() (*SMTPServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	server := &SMTPServer{listener: listener}
+	go server.serve()
+
+	return server, nil
+}
+
+func (s *SMTPServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *SMTPServer) Close() error {
+	return s.listener.Close()
+}
+
+func (s *SMTPServer) Messages() []SMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SMTPMessage(nil), s.messages...)
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 localhost SMTP test server")
+
+	var message SMTPMessage
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			_ = text.PrintfLine("250 localhost")
+		case "MAIL":
+			message = SMTPMessage{From: smtpAddress(argument)}
+			_ = text.PrintfLine("250 OK")
+		case "RCPT":
+			message.To = append(message.To, smtpAddress(argument))
+			_ = text.PrintfLine("250 OK")
+		case "DATA":
+			_ = text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			message.Data = string(data)
+
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+
+			_ = text.PrintfLine("250 OK")
+		case "QUIT":
+			_ = text.PrintfLine("221 Bye")
+			return
+		default:
+			_ = text.PrintfLine("250 OK")
+		}
+	}
+}
+
+func smtpAddress(argument string) string {
+	_, address, _ := strings.Cut(argument, ":")
+	return strings.Trim(address, "<> ")
+}
@@ -0,0 +1,242 @@
+package integration
+
+import (
+	"dmt/internal"
+	"dmt/pkg/device"
+	"dmt/pkg/dispatch"
+	"dmt/pkg/signature"
+	"dmt/pkg/webhook"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookReceiver struct {
+	mu     sync.Mutex
+	secret string
+	events []webhook.Event
+	errs   []error
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, err := signature.VerifyRequest(req, signature.DefaultTolerance, r.secret)
+	if err != nil {
+		r.errs = append(r.errs, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var event webhook.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		r.errs = append(r.errs, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	r.events = append(r.events, event)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *webhookReceiver) setSecret(secret string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secret = secret
+}
+
+func (r *webhookReceiver) eventTypes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	types := make([]string, 0, len(r.events))
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestWebhooks(t *testing.T) {
+	ctx := t.Context()
+	testDB, err := NewTestDB(ctx)
+	require.NoError(t, err)
+	defer testDB.Terminate()
+
+	db, err := testDB.GetConnectionPool()
+	require.NoError(t, err)
+
+	app := internal.CreateHttpServer(db, testAPIKey, "", nil, nil, nil)
+
+	// The subscriber listens on loopback.
+	webhook.AllowInternalTargets = true
+	defer func() { webhook.AllowInternalTargets = false }()
+
+	receiver := &webhookReceiver{}
+	subscriber := httptest.NewServer(receiver)
+	defer subscriber.Close()
+
+	dispatcher := webhook.NewDispatcher(db, dispatch.Config{
+		BatchSize:    10,
+		PollInterval: 50 * time.Millisecond,
+		Lease:        time.Minute,
+		MaxAttempts:  3,
+		BaseBackoff:  10 * time.Millisecond,
+		MaxBackoff:   50 * time.Millisecond,
+	})
+	go dispatcher.Run(ctx)
+
+	t.Run("Invalid Subscriptions Are Rejected", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		req := JSONRequestWithApiKey("POST", "/api/v1/webhooks", []byte(`{"url":"ftp://example.com","events":["device.created"]}`))
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+
+		req = JSONRequestWithApiKey("POST", "/api/v1/webhooks", []byte(`{"url":"https://example.com","events":["device.updated"]}`))
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+
+		req = JSONRequestWithApiKey("POST", "/api/v1/webhooks", []byte(`{"url":"https://example.com","events":[]}`))
+		makeRequest(t, app, req, http.StatusBadRequest, nil)
+	})
+
+	t.Run("Internal Targets Are Refused", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var createResponse map[string]interface{}
+		body := fmt.Sprintf(`{"url":%q,"events":["device.created"]}`, subscriber.URL)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/webhooks", []byte(body)), http.StatusCreated, &createResponse)
+		id := int(createResponse["webhook"].(map[string]interface{})["id"].(float64))
+
+		webhook.AllowInternalTargets = false
+		defer func() { webhook.AllowInternalTargets = true }()
+
+		for _, url := range []string{
+			subscriber.URL,
+			"http://localhost:8080/hook",
+			"http://[::1]/hook",
+			"http://169.254.169.254/latest/meta-data/",
+			"http://[::ffff:127.0.0.1]/hook",
+		} {
+			body := fmt.Sprintf(`{"url":%q,"events":["device.created"]}`, url)
+			makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/webhooks", []byte(body)), http.StatusBadRequest, nil)
+
+			body = fmt.Sprintf(`{"url":%q}`, url)
+			makeRequest(t, app, JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/webhooks/%d", id), []byte(body)), http.StatusBadRequest, nil)
+		}
+
+		// Subscriptions created before are refused when connecting.
+		var testResponse map[string]interface{}
+		req := JSONRequestWithApiKey("POST", fmt.Sprintf("/api/v1/webhooks/%d/test", id), nil)
+		makeRequest(t, app, req, http.StatusBadGateway, &testResponse)
+		delivery := testResponse["delivery"].(map[string]interface{})
+		assert.Nil(t, delivery["response_status"])
+		assert.NotContains(t, receiver.eventTypes(), webhook.EventTest)
+	})
+
+	t.Run("Subscribed Device Events Are Delivered Signed", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var createResponse map[string]interface{}
+		body := fmt.Sprintf(`{"url":%q,"events":["device.created","device.unassigned"]}`, subscriber.URL)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/webhooks", []byte(body)), http.StatusCreated, &createResponse)
+
+		receiver.setSecret(createResponse["secret"].(string))
+		id := int(createResponse["webhook"].(map[string]interface{})["id"].(float64))
+
+		testDevice := createTestDevice(withEmployee("whk"))
+		require.NoError(t, device.InsertDevice(ctx, db, testDevice))
+		require.NoError(t, device.UpdateDevice(ctx, db, testDevice, &device.DeviceUpdate{Employee: stringPtr("")}))
+
+		require.Eventually(t, func() bool {
+			return len(receiver.eventTypes()) == 2
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.Equal(t, []string{webhook.EventDeviceCreated, webhook.EventDeviceUnassigned}, receiver.eventTypes())
+		assert.Empty(t, receiver.errs)
+
+		var deliveries map[string]interface{}
+		req := JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/webhooks/%d/deliveries?status=delivered", id), nil)
+		makeRequest(t, app, req, http.StatusOK, &deliveries)
+		assert.Equal(t, float64(2), deliveries["count"])
+
+		var listResponse map[string]interface{}
+		makeRequest(t, app, JSONRequestWithApiKey("GET", "/api/v1/webhooks", nil), http.StatusOK, &listResponse)
+		listed := listResponse["webhooks"].([]interface{})[0].(map[string]interface{})
+		assert.NotContains(t, listed, "secret")
+
+		receiver.mu.Lock()
+		receiver.events = nil
+		receiver.mu.Unlock()
+	})
+
+	t.Run("Disabled Subscriptions Receive Nothing", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var createResponse map[string]interface{}
+		body := fmt.Sprintf(`{"url":%q,"events":["device.created"],"enabled":false}`, subscriber.URL)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/webhooks", []byte(body)), http.StatusCreated, &createResponse)
+		id := int(createResponse["webhook"].(map[string]interface{})["id"].(float64))
+
+		require.NoError(t, device.InsertDevice(ctx, db, createTestDevice()))
+
+		var deliveries map[string]interface{}
+		req := JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/webhooks/%d/deliveries", id), nil)
+		makeRequest(t, app, req, http.StatusOK, &deliveries)
+		assert.Equal(t, float64(0), deliveries["count"])
+
+		req = JSONRequestWithApiKey("PATCH", fmt.Sprintf("/api/v1/webhooks/%d", id), []byte(`{"enabled":true}`))
+		makeRequest(t, app, req, http.StatusOK, nil)
+
+		require.NoError(t, device.InsertDevice(ctx, db, createTestDevice()))
+
+		req = JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/webhooks/%d/deliveries", id), nil)
+		makeRequest(t, app, req, http.StatusOK, &deliveries)
+		assert.Equal(t, float64(1), deliveries["count"])
+	})
+
+	t.Run("Test Event Reports The Outcome", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var createResponse map[string]interface{}
+		body := fmt.Sprintf(`{"url":%q,"events":["threshold.exceeded"]}`, subscriber.URL)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/webhooks", []byte(body)), http.StatusCreated, &createResponse)
+		receiver.setSecret(createResponse["secret"].(string))
+		id := int(createResponse["webhook"].(map[string]interface{})["id"].(float64))
+
+		var testResponse map[string]interface{}
+		req := JSONRequestWithApiKey("POST", fmt.Sprintf("/api/v1/webhooks/%d/test", id), nil)
+		makeRequest(t, app, req, http.StatusOK, &testResponse)
+		delivery := testResponse["delivery"].(map[string]interface{})
+		assert.Equal(t, webhook.DeliveryDelivered, delivery["status"])
+		assert.Equal(t, float64(http.StatusNoContent), delivery["response_status"])
+		assert.Contains(t, receiver.eventTypes(), webhook.EventTest)
+
+		receiver.setSecret("rotated elsewhere")
+		makeRequest(t, app, req, http.StatusBadGateway, &testResponse)
+		delivery = testResponse["delivery"].(map[string]interface{})
+		assert.Equal(t, webhook.DeliveryDead, delivery["status"])
+		assert.Equal(t, float64(http.StatusUnauthorized), delivery["response_status"])
+
+		req = JSONRequestWithApiKey("POST", "/api/v1/webhooks/999/test", nil)
+		makeRequest(t, app, req, http.StatusNotFound, nil)
+	})
+
+	t.Run("Deleting A Subscription Removes Its History", func(t *testing.T) {
+		defer testDB.ClearDB(t)
+
+		var createResponse map[string]interface{}
+		body := fmt.Sprintf(`{"url":%q,"events":["device.deleted"]}`, subscriber.URL)
+		makeRequest(t, app, JSONRequestWithApiKey("POST", "/api/v1/webhooks", []byte(body)), http.StatusCreated, &createResponse)
+		id := int(createResponse["webhook"].(map[string]interface{})["id"].(float64))
+
+		makeRequest(t, app, JSONRequestWithApiKey("DELETE", fmt.Sprintf("/api/v1/webhooks/%d", id), nil), http.StatusOK, nil)
+		makeRequest(t, app, JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/webhooks/%d", id), nil), http.StatusNotFound, nil)
+		makeRequest(t, app, JSONRequestWithApiKey("GET", fmt.Sprintf("/api/v1/webhooks/%d/deliveries", id), nil), http.StatusNotFound, nil)
+	})
+}
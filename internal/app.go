@@ -2,37 +2,149 @@ package internal
 
 import (
 	"dmt/internal/middleware"
+	"dmt/pkg/apikey"
+	"dmt/pkg/assignment"
+	"dmt/pkg/audit"
 	"dmt/pkg/device"
+	"dmt/pkg/directory"
+	"dmt/pkg/employee"
+	"dmt/pkg/event"
+	"dmt/pkg/offboarding"
+	"dmt/pkg/scim"
+	"dmt/pkg/webhook"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/healthcheck"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func CreateHttpServer(db *pgxpool.Pool, apiKey string) *fiber.App {
+// CreateHttpServer sets up all routes. SCIM provisioning is disabled if
+// scimToken is empty. The notification pipeline, the event broker and the
+// directory syncer may be nil if this instance does not run them.
+func CreateHttpServer(db *pgxpool.Pool, apiKey string, scimToken string, pipeline *device.NotificationPipeline, broker *event.Broker, syncer *directory.Syncer) *fiber.App {
 	app := fiber.New(fiber.Config{
-		BodyLimit: 512,
+		BodyLimit:         middleware.DefaultBodyLimit,
+		StreamRequestBody: true,
+		// Multipart bodies would otherwise be read in full before any
+		// handler runs, bypassing the authentication and the body limits.
+		DisablePreParseMultipartForm: true,
 	})
 
+	app.Use(middleware.CloseUnreadBody(middleware.DefaultBodyLimit))
+	app.Use(requestid.New())
 	app.Use(logger.New())
 	app.Use(recover.New())
 	app.Use(healthcheck.New())
 
+	// Identity providers authenticate with their own token, so SCIM is not
+	// part of the API key protected routes.
+	scimUsers := scim.NewUserHandler(db)
+	scimV2 := app.Group("/scim/v2", middleware.SCIMAuthMiddleware(scimToken), middleware.BodyLimit(64<<10))
+	scimV2.Get("/ServiceProviderConfig", scimUsers.GetServiceProviderConfig)
+	scimV2.Post("/Users", scimUsers.CreateUser)
+	scimV2.Get("/Users", scimUsers.GetUsers)
+	scimV2.Get("/Users/:id", scimUsers.GetUser)
+	scimV2.Put("/Users/:id", scimUsers.ReplaceUser)
+	scimV2.Patch("/Users/:id", scimUsers.PatchUser)
+	scimV2.Delete("/Users/:id", scimUsers.DeleteUser)
+
+	keyValidator := apikey.NewValidator(db, apikey.DefaultCacheTTL)
+
 	api := app.Group("/api")
-	api.Use(middleware.KeyAuthMiddleware(apiKey))
+	api.Use(middleware.KeyAuthMiddleware(apiKey, keyValidator))
 
-	v1 := api.Group("/v1")
+	v1 := api.Group("/v1", middleware.BodyLimit(middleware.DefaultBodyLimit, "/api/v1/devices/import", "/api/v1/devices/bulk"))
 
 	deviceHandler := device.NewDeviceHandler(db)
+	assignmentHandler := assignment.NewAssignmentHandler(db)
+	auditHandler := audit.NewAuditHandler(db)
+	keyHandler := apikey.NewKeyHandler(db, keyValidator)
+	outboxHandler := device.NewOutboxHandler(db)
+	policyHandler := device.NewPolicyHandler(db)
+	deviceTypeHandler := device.NewDeviceTypeHandler(db)
+	tagHandler := device.NewTagHandler(db)
+	webhookHandler := webhook.NewWebhookHandler(db)
+	eventHandler := event.NewEventHandler(db, broker)
+	offboardingHandler := offboarding.NewOffboardingHandler(db)
+	employeeHandler := employee.NewEmployeeHandler(db)
+	directoryHandler := directory.NewDirectoryHandler(db, syncer)
+
+	read := middleware.RequireScope(apikey.ScopeDevicesRead)
+	write := middleware.RequireScope(apikey.ScopeDevicesWrite)
+	remove := middleware.RequireScope(apikey.ScopeDevicesDelete)
+	assign := middleware.RequireScope(apikey.ScopeAssignmentsWrite)
+	webhooks := middleware.RequireScope(apikey.ScopeWebhooksManage)
+	employees := middleware.RequireScope(apikey.ScopeEmployeesWrite)
+	admin := middleware.RequireScope(apikey.ScopeAdmin)
+
+	v1.Post("/devices", write, deviceHandler.CreateDevice)
+	v1.Get("/devices", read, deviceHandler.GetDevices)
+	v1.Post("/devices/import", write, middleware.BodyLimit(device.MaxImportBodySize), deviceHandler.ImportDevices)
+	v1.Get("/devices/export", read, deviceHandler.ExportDevices)
+	v1.Post("/devices/bulk", write, middleware.BodyLimit(device.MaxBulkBodySize), deviceHandler.BulkUpdate)
+	v1.Get("/devices/:id", read, deviceHandler.GetDeviceByID)
+	v1.Put("/devices/:id", write, deviceHandler.ReplaceDevice)
+	v1.Patch("/devices/:id", write, deviceHandler.PatchDevice)
+	v1.Delete("/devices/:id", remove, deviceHandler.DeleteDevice)
+	v1.Put("/devices/:id/employee", assign, deviceHandler.UpdateDeviceEmployee)
+	v1.Delete("/devices/:id/employee", assign, deviceHandler.DeleteDeviceEmployee)
+	v1.Get("/devices/:id/assignments", read, assignmentHandler.GetDeviceAssignments)
+	v1.Post("/devices/:id/tags", write, deviceHandler.AddDeviceTags)
+	v1.Delete("/devices/:id/tags/:tag", write, deviceHandler.RemoveDeviceTag)
+
+	v1.Get("/tags", read, tagHandler.GetTags)
+	v1.Delete("/tags/:name", admin, tagHandler.DeleteTag)
+
+	v1.Get("/device-types", read, deviceTypeHandler.GetDeviceTypes)
+	v1.Post("/device-types", admin, deviceTypeHandler.CreateDeviceType)
+	v1.Get("/device-types/:name", read, deviceTypeHandler.GetDeviceType)
+	v1.Patch("/device-types/:name", admin, deviceTypeHandler.UpdateDeviceType)
+	v1.Delete("/device-types/:name", admin, deviceTypeHandler.DeleteDeviceType)
+
+	v1.Post("/employees", employees, employeeHandler.CreateEmployee)
+	v1.Get("/employees", read, employeeHandler.GetEmployees)
+	v1.Get("/employees/:abbr", read, employeeHandler.GetEmployee)
+	v1.Patch("/employees/:abbr", employees, employeeHandler.PatchEmployee)
+	v1.Delete("/employees/:abbr", employees, employeeHandler.DeleteEmployee)
+	v1.Get("/employees/:abbr/assignments", read, assignmentHandler.GetEmployeeAssignments)
+	v1.Post("/employees/:abbr/offboard", assign, offboardingHandler.Offboard)
+
+	v1.Get("/directory/sync", admin, directoryHandler.GetSync)
+	v1.Post("/directory/sync", admin, directoryHandler.RunSync)
+	v1.Get("/directory/orphaned-devices", read, directoryHandler.GetOrphanedDevices)
+
+	v1.Get("/offboardings", read, offboardingHandler.GetOffboardings)
+	v1.Get("/offboardings/:id", read, offboardingHandler.GetOffboarding)
+	v1.Post("/offboardings/:id/items/:item/return", assign, offboardingHandler.ReturnItem)
+
+	v1.Get("/events", read, eventHandler.Stream)
+
+	v1.Get("/audit", admin, auditHandler.GetEntries)
+
+	v1.Get("/notifications", admin, outboxHandler.GetEntries)
+	v1.Get("/notifications/status", admin, pipeline.GetStatus)
+	v1.Post("/notifications/:id/retry", admin, outboxHandler.RetryEntry)
+
+	v1.Get("/policies", admin, policyHandler.GetPolicies)
+	v1.Post("/policies", admin, policyHandler.CreatePolicy)
+	v1.Put("/policies/:id", admin, policyHandler.UpdatePolicy)
+	v1.Delete("/policies/:id", admin, policyHandler.DeletePolicy)
+
+	v1.Post("/webhooks", webhooks, webhookHandler.CreateWebhook)
+	v1.Get("/webhooks", webhooks, webhookHandler.GetWebhooks)
+	v1.Get("/webhooks/:id", webhooks, webhookHandler.GetWebhook)
+	v1.Patch("/webhooks/:id", webhooks, webhookHandler.UpdateWebhook)
+	v1.Delete("/webhooks/:id", webhooks, webhookHandler.DeleteWebhook)
+	v1.Get("/webhooks/:id/deliveries", webhooks, webhookHandler.GetDeliveries)
+	v1.Post("/webhooks/:id/test", webhooks, webhookHandler.SendTestEvent)
 
-	v1.Post("/devices", deviceHandler.CreateDevice)
-	v1.Get("/devices", deviceHandler.GetDevices)
-	v1.Get("/devices/:id", deviceHandler.GetDeviceByID)
-	v1.Delete("/devices/:id", deviceHandler.DeleteDevice)
-	v1.Put("/devices/:id/employee", deviceHandler.UpdateDeviceEmployee)
-	v1.Delete("/devices/:id/employee", deviceHandler.DeleteDeviceEmployee)
+	v1.Post("/keys", admin, keyHandler.CreateKey)
+	v1.Get("/keys", admin, keyHandler.GetKeys)
+	v1.Post("/keys/:id/rotate", admin, keyHandler.RotateKey)
+	v1.Delete("/keys/:id", admin, keyHandler.RevokeKey)
 
 	return app
 }
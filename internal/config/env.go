@@ -4,6 +4,8 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func GetPort() string {
@@ -19,10 +21,12 @@ func GetPort() string {
 	return port
 }
 
+// GetAPIKey returns the bootstrap key used to manage the keys stored in the
+// database. Without it only database keys are accepted.
 func GetAPIKey() string {
 	apiKey := os.Getenv("API_KEY")
 	if apiKey == "" {
-		log.Fatal("API_KEY is not set")
+		log.Println("API_KEY is not set, only API keys stored in the database are accepted")
 	}
 	return apiKey
 }
@@ -39,3 +43,166 @@ func GetNotifyUrl() string {
 	notifyURL := os.Getenv("NOTIFY_URL")
 	return notifyURL
 }
+
+// GetNotifyLevels returns the notification levels a channel receives, read
+// from a comma separated list. Unset means every level.
+func GetNotifyLevels(name string) []string {
+	var levels []string
+	for _, level := range strings.Split(os.Getenv(name), ",") {
+		if level = strings.TrimSpace(level); level != "" {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// GetNotifySecrets returns the secrets outgoing webhooks are signed with. The
+// previous secret stays active while receivers switch to the current one.
+func GetNotifySecrets() []string {
+	var secrets []string
+	for _, name := range []string{"NOTIFY_SECRET", "NOTIFY_SECRET_PREVIOUS"} {
+		if secret := os.Getenv(name); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+type WebhookConfig struct {
+	URL      string
+	Template string
+}
+
+func GetWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		URL:      os.Getenv("NOTIFY_WEBHOOK_URL"),
+		Template: os.Getenv("NOTIFY_WEBHOOK_TEMPLATE"),
+	}
+}
+
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func GetSMTPConfig() SMTPConfig {
+	config := SMTPConfig{
+		Addr:     os.Getenv("SMTP_ADDR"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+
+	for _, to := range strings.Split(os.Getenv("SMTP_TO"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			config.To = append(config.To, to)
+		}
+	}
+
+	if config.From == "" {
+		config.From = "dmt@localhost"
+	}
+
+	if config.Addr != "" && len(config.To) == 0 {
+		log.Fatalf("SMTP_TO is required when SMTP_ADDR is set")
+	}
+
+	return config
+}
+
+// GetInstanceID identifies this replica in leader election, defaulting to the
+// hostname which is the container ID in Docker.
+func GetInstanceID() string {
+	instanceID := os.Getenv("INSTANCE_ID")
+	if instanceID != "" {
+		return instanceID
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "dmt"
+	}
+	return hostname
+}
+
+type LDAPConfig struct {
+	URL          string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	Filter       string
+
+	AbbreviationAttribute string
+	NameAttribute         string
+	EmailAttribute        string
+	DepartmentAttribute   string
+
+	SyncInterval time.Duration
+}
+
+// GetLDAPConfig reads the directory sync settings. The sync is disabled if
+// LDAP_URL is not set.
+func GetLDAPConfig() LDAPConfig {
+	config := LDAPConfig{
+		URL:                   os.Getenv("LDAP_URL"),
+		BindDN:                os.Getenv("LDAP_BIND_DN"),
+		BindPassword:          os.Getenv("LDAP_BIND_PASSWORD"),
+		BaseDN:                os.Getenv("LDAP_BASE_DN"),
+		Filter:                getEnvDefault("LDAP_FILTER", "(objectClass=inetOrgPerson)"),
+		AbbreviationAttribute: getEnvDefault("LDAP_ATTR_ABBREVIATION", "uid"),
+		NameAttribute:         getEnvDefault("LDAP_ATTR_NAME", "cn"),
+		EmailAttribute:        getEnvDefault("LDAP_ATTR_EMAIL", "mail"),
+		DepartmentAttribute:   getEnvDefault("LDAP_ATTR_DEPARTMENT", "departmentNumber"),
+		SyncInterval:          time.Hour,
+	}
+
+	if interval := os.Getenv("LDAP_SYNC_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed < time.Minute {
+			log.Fatalf("Invalid LDAP_SYNC_INTERVAL %q, must be a duration of at least 1m", interval)
+		}
+		config.SyncInterval = parsed
+	}
+
+	if config.URL != "" && config.BaseDN == "" {
+		log.Fatalf("LDAP_BASE_DN is required when LDAP_URL is set")
+	}
+
+	return config
+}
+
+func getEnvDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetSCIMToken returns the bearer token identity providers authenticate with
+// at /scim/v2. SCIM provisioning is disabled if it is not set.
+func GetSCIMToken() string {
+	token := os.Getenv("SCIM_TOKEN")
+	if token != "" && len(token) < 32 {
+		log.Fatalf("SCIM_TOKEN must be at least 32 characters")
+	}
+	return token
+}
+
+// GetWebhookAllowInternalTargets reports whether webhook subscriptions may
+// point to loopback, link-local and metadata addresses, e.g. for local
+// development.
+func GetWebhookAllowInternalTargets() bool {
+	value := os.Getenv("WEBHOOK_ALLOW_INTERNAL_TARGETS")
+	if value == "" {
+		return false
+	}
+
+	allow, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("Invalid WEBHOOK_ALLOW_INTERNAL_TARGETS %q, must be a boolean", value)
+	}
+	return allow
+}
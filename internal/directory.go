@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"dmt/internal/config"
+	"dmt/pkg/directory"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CreateDirectorySyncer creates the LDAP directory sync configured in the
+// environment. It returns nil if no directory is configured.
+func CreateDirectorySyncer(db *pgxpool.Pool, instance string) *directory.Syncer {
+	ldap := config.GetLDAPConfig()
+	if ldap.URL == "" {
+		return nil
+	}
+
+	source := directory.NewLDAPSource(directory.LDAPConfig{
+		URL:                   ldap.URL,
+		BindDN:                ldap.BindDN,
+		BindPassword:          ldap.BindPassword,
+		BaseDN:                ldap.BaseDN,
+		Filter:                ldap.Filter,
+		AbbreviationAttribute: ldap.AbbreviationAttribute,
+		NameAttribute:         ldap.NameAttribute,
+		EmailAttribute:        ldap.EmailAttribute,
+		DepartmentAttribute:   ldap.DepartmentAttribute,
+	})
+
+	return directory.NewSyncer(db, source, ldap.SyncInterval, instance)
+}
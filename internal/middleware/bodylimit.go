@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"io"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DefaultBodyLimit is the body limit of routes without a limit of their own.
+const DefaultBodyLimit = 512
+
+// BodyLimit rejects request bodies larger than limit, except on the exempt
+// paths, which set a limit of their own.
+//
+// The server streams request bodies and does not pre-parse multipart forms,
+// so only a small prefix is read before the handlers run. Bodies announcing a
+// larger Content-Length are rejected without reading them, all others are
+// read up to the limit, so a body is never buffered beyond the limit of its
+// route. Register it behind the authentication to not read bodies of
+// unauthenticated requests beyond that prefix, and CloseUnreadBody in front
+// of everything to not keep connections with unread bodies open.
+func BodyLimit(limit int, exempt ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if slices.Contains(exempt, c.Path()) {
+			return c.Next()
+		}
+
+		request := c.Request()
+		if request.Header.ContentLength() > limit {
+			return bodyTooLarge(c)
+		}
+
+		if request.IsBodyStream() {
+			body, err := io.ReadAll(io.LimitReader(request.BodyStream(), int64(limit)+1))
+			if err != nil {
+				c.Context().SetConnectionClose()
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Failed to read request body",
+				})
+			}
+			if len(body) > limit {
+				return bodyTooLarge(c)
+			}
+			request.SetBody(body)
+		}
+
+		return c.Next()
+	}
+}
+
+// CloseUnreadBody closes the connection if the request was answered without
+// reading its streamed body. The server only reads the first prefetch bytes
+// of a body up front, the rest would otherwise be parsed as the next request.
+func CloseUnreadBody(prefetch int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		request := c.Request()
+		length := request.Header.ContentLength()
+		if request.IsBodyStream() && (length > prefetch || length == -1) {
+			c.Context().SetConnectionClose()
+		}
+
+		return err
+	}
+}
+
+func bodyTooLarge(c *fiber.Ctx) error {
+	// The rest of the body is left unread on the connection.
+	c.Context().SetConnectionClose()
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"error": "Request body too large",
+	})
+}
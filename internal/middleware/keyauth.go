@@ -1,16 +1,24 @@
 package middleware
 
 import (
+	"crypto/sha256"
 	"crypto/subtle"
+	"dmt/pkg/apikey"
+	"dmt/pkg/audit"
 	"encoding/base64"
-	"errors"
+	"encoding/hex"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/keyauth"
 )
 
-func KeyAuthMiddleware(apiKey string) fiber.Handler {
+// KeyAuthMiddleware accepts the bootstrap key from the environment, which has
+// admin scope, and any active key stored in the database.
+func KeyAuthMiddleware(apiKey string, keys *apikey.Validator) fiber.Handler {
+	fingerprint := sha256.Sum256([]byte(apiKey))
+	actor := "key:" + hex.EncodeToString(fingerprint[:4])
+
 	return keyauth.New(keyauth.Config{
 		Validator: func(c *fiber.Ctx, key string) (bool, error) {
 			providedKey, err := base64.StdEncoding.DecodeString(key)
@@ -18,11 +26,20 @@ func KeyAuthMiddleware(apiKey string) fiber.Handler {
 				return false, err
 			}
 
-			if subtle.ConstantTimeCompare(providedKey, []byte(apiKey)) == 1 {
+			if apiKey != "" && subtle.ConstantTimeCompare(providedKey, []byte(apiKey)) == 1 {
+				c.Locals(audit.ActorLocal, actor)
+				c.Locals(apikey.ScopesLocal, []string{apikey.ScopeAdmin})
 				return true, nil
 			}
 
-			return false, errors.New("invalid API key")
+			storedKey, err := keys.Validate(c.Context(), string(providedKey))
+			if err != nil {
+				return false, err
+			}
+
+			c.Locals(audit.ActorLocal, storedKey.Actor())
+			c.Locals(apikey.ScopesLocal, storedKey.Scopes)
+			return true, nil
 		},
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			log.Warnf("Access denied from '%s' - %s", c.IP(), err.Error())
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"dmt/pkg/audit"
+	"dmt/pkg/scim"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// scimActor is recorded in the audit log for changes made by the identity
+// provider.
+const scimActor = "scim"
+
+// SCIMAuthMiddleware accepts the SCIM token as plain bearer token, as sent by
+// identity providers. API keys are not accepted and the SCIM token grants
+// nothing outside of /scim. Without a token every request is rejected.
+func SCIMAuthMiddleware(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return scim.SendError(c, fiber.StatusUnauthorized, "", "SCIM provisioning is not configured")
+		}
+
+		provided, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(token)) != 1 {
+			log.Warnf("SCIM access denied from '%s'", c.IP())
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="dmt-scim"`)
+			return scim.SendError(c, fiber.StatusUnauthorized, "", "Invalid SCIM token")
+		}
+
+		c.Locals(audit.ActorLocal, scimActor)
+		return c.Next()
+	}
+}
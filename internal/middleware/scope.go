@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"dmt/pkg/apikey"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// RequireScope rejects requests whose API key lacks the given scope. It must
+// run after KeyAuthMiddleware.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, _ := c.Locals(apikey.ScopesLocal).([]string)
+		if !apikey.HasScope(scopes, scope) {
+			log.Warnf("Forbidden request from '%s' to %s %s - missing scope %s", c.IP(), c.Method(), c.Path(), scope)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":         "Missing required scope",
+				"missing_scope": scope,
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS device_assignment_history_trigger ON device;
+DROP FUNCTION IF EXISTS record_device_assignment();
+DROP TABLE IF EXISTS device_assignment;
@@ -0,0 +1,49 @@
+CREATE TABLE IF NOT EXISTS device_assignment (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    device_id INTEGER NOT NULL,
+    employee VARCHAR(3) NOT NULL,
+    assigned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    unassigned_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX IF NOT EXISTS device_assignment_device_idx ON device_assignment (device_id, assigned_at);
+CREATE INDEX IF NOT EXISTS device_assignment_employee_idx ON device_assignment (employee, assigned_at);
+
+INSERT INTO device_assignment (device_id, employee, assigned_at)
+    SELECT id, employee, updated_at
+    FROM device
+    WHERE employee IS NOT NULL;
+
+DROP TRIGGER IF EXISTS device_assignment_history_trigger ON device;
+
+DROP FUNCTION IF EXISTS record_device_assignment();
+CREATE OR REPLACE FUNCTION record_device_assignment()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        IF TG_OP = 'UPDATE' AND OLD.employee IS NOT DISTINCT FROM NEW.employee THEN
+            RETURN NEW;
+        END IF;
+
+        UPDATE device_assignment
+        SET unassigned_at = NOW()
+        WHERE device_id = OLD.id AND unassigned_at IS NULL;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.employee IS NOT NULL THEN
+        INSERT INTO device_assignment (device_id, employee, assigned_at)
+        VALUES (NEW.id, NEW.employee, NOW());
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER device_assignment_history_trigger
+    AFTER INSERT OR UPDATE OF employee OR DELETE ON device
+    FOR EACH ROW
+    EXECUTE FUNCTION record_device_assignment();
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS audit_log_append_only_trigger ON audit_log;
+DROP TRIGGER IF EXISTS audit_log_no_truncate_trigger ON audit_log;
+DROP FUNCTION IF EXISTS prevent_audit_log_modification();
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    device_id INTEGER NULL,
+    before JSONB NULL,
+    after JSONB NULL,
+    request_id TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_device_idx ON audit_log (device_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+
+DROP TRIGGER IF EXISTS audit_log_append_only_trigger ON audit_log;
+DROP TRIGGER IF EXISTS audit_log_no_truncate_trigger ON audit_log;
+
+DROP FUNCTION IF EXISTS prevent_audit_log_modification();
+CREATE OR REPLACE FUNCTION prevent_audit_log_modification()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only_trigger
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_audit_log_modification();
+
+-- Row triggers don't fire on TRUNCATE, so it is blocked separately.
+CREATE TRIGGER audit_log_no_truncate_trigger
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION prevent_audit_log_modification();
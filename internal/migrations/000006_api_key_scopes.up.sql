@@ -0,0 +1,4 @@
+ALTER TABLE api_key ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+
+-- Keys created before scopes existed had full access
+UPDATE api_key SET scopes = ARRAY['admin'];
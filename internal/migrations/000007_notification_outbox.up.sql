@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'skipped', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX IF NOT EXISTS notification_outbox_pending_idx ON notification_outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS notification_outbox_status_idx ON notification_outbox (status, id);
+
+-- The outbox row is written in the same transaction as the device change, the
+-- pg_notify only wakes up the dispatcher.
+CREATE OR REPLACE FUNCTION notify_device_count()
+RETURNS TRIGGER AS $$
+DECLARE
+    device_count INTEGER;
+    payload JSONB;
+BEGIN
+    IF NEW.employee IS NULL THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT COUNT(*)
+    INTO device_count
+    FROM device
+    WHERE device.employee = NEW.employee;
+
+    payload := jsonb_build_object(
+        'employee', NEW.employee,
+        'count', device_count
+    );
+
+    INSERT INTO notification_outbox (payload) VALUES (payload);
+
+    PERFORM pg_notify('device_count', payload::text);
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,29 @@
+CREATE OR REPLACE FUNCTION notify_device_count()
+RETURNS TRIGGER AS $$
+DECLARE
+    device_count INTEGER;
+    payload JSONB;
+BEGIN
+    IF NEW.employee IS NULL THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT COUNT(*)
+    INTO device_count
+    FROM device
+    WHERE device.employee = NEW.employee;
+
+    payload := jsonb_build_object(
+        'employee', NEW.employee,
+        'count', device_count
+    );
+
+    INSERT INTO notification_outbox (payload) VALUES (payload);
+
+    PERFORM pg_notify('device_count', payload::text);
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS notification_policy;
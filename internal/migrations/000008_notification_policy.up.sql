@@ -0,0 +1,57 @@
+CREATE TABLE IF NOT EXISTS notification_policy (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    scope TEXT NOT NULL CHECK (scope IN ('global', 'employee', 'type')),
+    employee VARCHAR(3) NULL,
+    device_type TEXT NULL,
+    max_devices INTEGER NOT NULL CHECK (max_devices >= 0),
+    CHECK (
+        (scope = 'global' AND employee IS NULL AND device_type IS NULL) OR
+        (scope = 'employee' AND employee IS NOT NULL AND device_type IS NULL) OR
+        (scope = 'type' AND employee IS NULL AND device_type IS NOT NULL)
+    )
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS notification_policy_global_idx ON notification_policy (scope) WHERE scope = 'global';
+CREATE UNIQUE INDEX IF NOT EXISTS notification_policy_employee_idx ON notification_policy (employee) WHERE scope = 'employee';
+CREATE UNIQUE INDEX IF NOT EXISTS notification_policy_type_idx ON notification_policy (device_type) WHERE scope = 'type';
+
+-- Employees with 3 or more devices used to trigger a notification
+INSERT INTO notification_policy (scope, max_devices) VALUES ('global', 2);
+
+-- Per-type counts are part of the payload so that type policies can be
+-- evaluated against the state at the time of the change.
+CREATE OR REPLACE FUNCTION notify_device_count()
+RETURNS TRIGGER AS $$
+DECLARE
+    device_count INTEGER;
+    type_counts JSONB;
+    payload JSONB;
+BEGIN
+    IF NEW.employee IS NULL THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT COALESCE(SUM(counts.count), 0), COALESCE(jsonb_object_agg(counts.type, counts.count), '{}'::jsonb)
+    INTO device_count, type_counts
+    FROM (
+        SELECT device.type, COUNT(*) AS count
+        FROM device
+        WHERE device.employee = NEW.employee
+        GROUP BY device.type
+    ) AS counts;
+
+    payload := jsonb_build_object(
+        'employee', NEW.employee,
+        'count', device_count,
+        'types', type_counts
+    );
+
+    INSERT INTO notification_outbox (payload) VALUES (payload);
+
+    PERFORM pg_notify('device_count', payload::text);
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,43 @@
+DROP TRIGGER IF EXISTS device_count_notification_trigger ON device;
+
+CREATE OR REPLACE FUNCTION notify_device_count()
+RETURNS TRIGGER AS $$
+DECLARE
+    device_count INTEGER;
+    type_counts JSONB;
+    payload JSONB;
+BEGIN
+    IF NEW.employee IS NULL THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT COALESCE(SUM(counts.count), 0), COALESCE(jsonb_object_agg(counts.type, counts.count), '{}'::jsonb)
+    INTO device_count, type_counts
+    FROM (
+        SELECT device.type, COUNT(*) AS count
+        FROM device
+        WHERE device.employee = NEW.employee
+        GROUP BY device.type
+    ) AS counts;
+
+    payload := jsonb_build_object(
+        'employee', NEW.employee,
+        'count', device_count,
+        'types', type_counts
+    );
+
+    INSERT INTO notification_outbox (payload) VALUES (payload);
+
+    PERFORM pg_notify('device_count', payload::text);
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER device_count_notification_trigger
+    AFTER INSERT OR UPDATE OF employee ON device
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_device_count();
+
+DROP FUNCTION IF EXISTS enqueue_device_count(TEXT);
+DROP TABLE IF EXISTS employee_alert_state;
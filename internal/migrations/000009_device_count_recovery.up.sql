@@ -0,0 +1,63 @@
+-- outbox_id is the newest notification applied to the state, so retries of
+-- older notifications can be recognized as superseded.
+CREATE TABLE IF NOT EXISTS employee_alert_state (
+    employee VARCHAR(3) PRIMARY KEY,
+    policy_ids INTEGER[] NOT NULL,
+    outbox_id BIGINT NOT NULL DEFAULT 0,
+    alerted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+DROP FUNCTION IF EXISTS enqueue_device_count(TEXT);
+CREATE OR REPLACE FUNCTION enqueue_device_count(target_employee TEXT)
+RETURNS VOID AS $$
+DECLARE
+    device_count INTEGER;
+    type_counts JSONB;
+    payload JSONB;
+BEGIN
+    SELECT COALESCE(SUM(counts.count), 0), COALESCE(jsonb_object_agg(counts.type, counts.count), '{}'::jsonb)
+    INTO device_count, type_counts
+    FROM (
+        SELECT device.type, COUNT(*) AS count
+        FROM device
+        WHERE device.employee = target_employee
+        GROUP BY device.type
+    ) AS counts;
+
+    payload := jsonb_build_object(
+        'employee', target_employee,
+        'count', device_count,
+        'types', type_counts
+    );
+
+    INSERT INTO notification_outbox (payload) VALUES (payload);
+
+    PERFORM pg_notify('device_count', payload::text);
+END;
+$$ LANGUAGE plpgsql;
+
+-- Reports the counts of the previous owner on reassignment and deletion, so
+-- that recoveries below the limits are noticed as well.
+CREATE OR REPLACE FUNCTION notify_device_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' AND OLD.employee IS NOT NULL THEN
+        PERFORM enqueue_device_count(OLD.employee);
+    END IF;
+
+    IF TG_OP <> 'DELETE' AND NEW.employee IS NOT NULL
+        AND (TG_OP = 'INSERT' OR NEW.employee IS DISTINCT FROM OLD.employee) THEN
+        PERFORM enqueue_device_count(NEW.employee);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS device_count_notification_trigger ON device;
+
+CREATE TRIGGER device_count_notification_trigger
+    AFTER INSERT OR UPDATE OF employee, type OR DELETE ON device
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_device_count();
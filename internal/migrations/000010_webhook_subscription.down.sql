@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS device_webhook_trigger ON device;
+DROP FUNCTION IF EXISTS enqueue_device_webhooks();
+DROP FUNCTION IF EXISTS enqueue_webhook_event(TEXT, JSONB);
+DROP TABLE IF EXISTS webhook_delivery;
+DROP TABLE IF EXISTS webhook_subscription;
@@ -0,0 +1,75 @@
+CREATE TABLE IF NOT EXISTS webhook_subscription (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    url TEXT NOT NULL,
+    description TEXT NULL,
+    events TEXT[] NOT NULL,
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES webhook_subscription (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    event TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    response_status INTEGER NULL,
+    last_error TEXT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_pending_idx ON webhook_delivery (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_delivery_subscription_idx ON webhook_delivery (subscription_id, id);
+
+-- Queues a delivery for every enabled subscription of the event. Used by the
+-- device trigger and by the application for events computed outside SQL.
+CREATE OR REPLACE FUNCTION enqueue_webhook_event(event_type TEXT, event_payload JSONB)
+RETURNS VOID AS $$
+BEGIN
+    INSERT INTO webhook_delivery (subscription_id, event, payload)
+    SELECT id, event_type, event_payload
+    FROM webhook_subscription
+    WHERE enabled AND event_type = ANY(events);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION enqueue_device_webhooks()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM enqueue_webhook_event('device.created', jsonb_build_object('device', to_jsonb(NEW)));
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM enqueue_webhook_event('device.deleted', jsonb_build_object('device', to_jsonb(OLD)));
+    END IF;
+
+    IF TG_OP <> 'INSERT' AND OLD.employee IS NOT NULL
+        AND (TG_OP = 'DELETE' OR NEW.employee IS DISTINCT FROM OLD.employee) THEN
+        PERFORM enqueue_webhook_event('device.unassigned', jsonb_build_object(
+            'device', to_jsonb(COALESCE(NEW, OLD)),
+            'employee', OLD.employee
+        ));
+    END IF;
+
+    IF TG_OP <> 'DELETE' AND NEW.employee IS NOT NULL
+        AND (TG_OP = 'INSERT' OR NEW.employee IS DISTINCT FROM OLD.employee) THEN
+        PERFORM enqueue_webhook_event('device.assigned', jsonb_build_object(
+            'device', to_jsonb(NEW),
+            'employee', NEW.employee
+        ));
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS device_webhook_trigger ON device;
+
+CREATE TRIGGER device_webhook_trigger
+    AFTER INSERT OR UPDATE OF employee OR DELETE ON device
+    FOR EACH ROW
+    EXECUTE FUNCTION enqueue_device_webhooks();
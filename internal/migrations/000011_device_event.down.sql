@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS device_event_trigger ON device;
+DROP FUNCTION IF EXISTS record_device_event();
+DROP TABLE IF EXISTS device_event;
@@ -0,0 +1,72 @@
+CREATE TABLE IF NOT EXISTS device_event (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    type TEXT NOT NULL,
+    device_id INTEGER NOT NULL,
+    data JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS device_event_created_at_idx ON device_event (created_at);
+
+-- Records every device change and announces its id on the general purpose
+-- device_event channel. Employee changes are reported as (un)assignments,
+-- changes of any other column as update.
+--
+-- Streams resume after the last event id they have seen, so ids must become
+-- visible in order. The trigger is deferred to commit and serializes the
+-- committing transactions on an advisory lock, which is held until the
+-- commit, so an event never commits with a lower id than one already visible.
+-- Deferring the trigger keeps the lock out of the transaction's statements,
+-- so waiting for it can't deadlock with row locks.
+CREATE OR REPLACE FUNCTION record_device_event()
+RETURNS TRIGGER AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    PERFORM pg_advisory_xact_lock(1684894821); -- 0x646d7465
+
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO device_event (type, device_id, data)
+        VALUES ('device.created', NEW.id, jsonb_build_object('device', to_jsonb(NEW)))
+        RETURNING id INTO event_id;
+        PERFORM pg_notify('device_event', event_id::text);
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO device_event (type, device_id, data)
+        VALUES ('device.deleted', OLD.id, jsonb_build_object('device', to_jsonb(OLD)))
+        RETURNING id INTO event_id;
+        PERFORM pg_notify('device_event', event_id::text);
+        RETURN NULL;
+    END IF;
+
+    IF (to_jsonb(NEW) - 'employee' - 'updated_at') IS DISTINCT FROM (to_jsonb(OLD) - 'employee' - 'updated_at') THEN
+        INSERT INTO device_event (type, device_id, data)
+        VALUES ('device.updated', NEW.id, jsonb_build_object('device', to_jsonb(NEW), 'previous', to_jsonb(OLD)))
+        RETURNING id INTO event_id;
+        PERFORM pg_notify('device_event', event_id::text);
+    END IF;
+
+    IF NEW.employee IS DISTINCT FROM OLD.employee THEN
+        INSERT INTO device_event (type, device_id, data)
+        VALUES (
+            CASE WHEN NEW.employee IS NULL THEN 'device.unassigned' ELSE 'device.assigned' END,
+            NEW.id,
+            jsonb_build_object('device', to_jsonb(NEW), 'employee', NEW.employee, 'previous_employee', OLD.employee)
+        )
+        RETURNING id INTO event_id;
+        PERFORM pg_notify('device_event', event_id::text);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS device_event_trigger ON device;
+
+CREATE CONSTRAINT TRIGGER device_event_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON device
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW
+    EXECUTE FUNCTION record_device_event();
@@ -0,0 +1,25 @@
+DROP TRIGGER IF EXISTS device_count_insert_trigger ON device;
+DROP TRIGGER IF EXISTS device_count_update_trigger ON device;
+DROP TRIGGER IF EXISTS device_count_delete_trigger ON device;
+DROP FUNCTION IF EXISTS notify_device_counts();
+
+CREATE OR REPLACE FUNCTION notify_device_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' AND OLD.employee IS NOT NULL THEN
+        PERFORM enqueue_device_count(OLD.employee);
+    END IF;
+
+    IF TG_OP <> 'DELETE' AND NEW.employee IS NOT NULL
+        AND (TG_OP = 'INSERT' OR NEW.employee IS DISTINCT FROM OLD.employee) THEN
+        PERFORM enqueue_device_count(NEW.employee);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER device_count_notification_trigger
+    AFTER INSERT OR UPDATE OF employee, type OR DELETE ON device
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_device_count();
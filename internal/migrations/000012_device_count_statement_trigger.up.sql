@@ -0,0 +1,68 @@
+DROP TRIGGER IF EXISTS device_count_notification_trigger ON device;
+DROP FUNCTION IF EXISTS notify_device_count();
+
+-- Reports the counts once per statement and affected employee, so that bulk
+-- changes enqueue the final count of every previous and new owner instead of
+-- one row per changed device.
+CREATE OR REPLACE FUNCTION notify_device_counts()
+RETURNS TRIGGER AS $$
+DECLARE
+    affected TEXT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        FOR affected IN
+            SELECT DISTINCT employee FROM new_devices
+            WHERE employee IS NOT NULL
+            ORDER BY employee
+        LOOP
+            PERFORM enqueue_device_count(affected);
+        END LOOP;
+    ELSIF TG_OP = 'DELETE' THEN
+        FOR affected IN
+            SELECT DISTINCT employee FROM old_devices
+            WHERE employee IS NOT NULL
+            ORDER BY employee
+        LOOP
+            PERFORM enqueue_device_count(affected);
+        END LOOP;
+    ELSE
+        FOR affected IN
+            SELECT employee FROM (
+                SELECT old_devices.employee
+                FROM old_devices JOIN new_devices USING (id)
+                WHERE old_devices.employee IS DISTINCT FROM new_devices.employee
+                    OR old_devices.type IS DISTINCT FROM new_devices.type
+                UNION
+                SELECT new_devices.employee
+                FROM old_devices JOIN new_devices USING (id)
+                WHERE old_devices.employee IS DISTINCT FROM new_devices.employee
+                    OR old_devices.type IS DISTINCT FROM new_devices.type
+            ) AS changed
+            WHERE employee IS NOT NULL
+            ORDER BY employee
+        LOOP
+            PERFORM enqueue_device_count(affected);
+        END LOOP;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER device_count_insert_trigger
+    AFTER INSERT ON device
+    REFERENCING NEW TABLE AS new_devices
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION notify_device_counts();
+
+CREATE TRIGGER device_count_update_trigger
+    AFTER UPDATE ON device
+    REFERENCING OLD TABLE AS old_devices NEW TABLE AS new_devices
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION notify_device_counts();
+
+CREATE TRIGGER device_count_delete_trigger
+    AFTER DELETE ON device
+    REFERENCING OLD TABLE AS old_devices
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION notify_device_counts();
@@ -0,0 +1,30 @@
+DROP TABLE IF EXISTS offboarding_item;
+DROP TABLE IF EXISTS offboarding;
+
+CREATE OR REPLACE FUNCTION record_device_assignment()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        IF TG_OP = 'UPDATE' AND OLD.employee IS NOT DISTINCT FROM NEW.employee THEN
+            RETURN NEW;
+        END IF;
+
+        UPDATE device_assignment
+        SET unassigned_at = NOW()
+        WHERE device_id = OLD.id AND unassigned_at IS NULL;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.employee IS NOT NULL THEN
+        INSERT INTO device_assignment (device_id, employee, assigned_at)
+        VALUES (NEW.id, NEW.employee, NOW());
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE device_assignment DROP COLUMN IF EXISTS unassign_reason;
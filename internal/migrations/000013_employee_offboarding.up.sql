@@ -0,0 +1,59 @@
+ALTER TABLE device_assignment ADD COLUMN IF NOT EXISTS unassign_reason TEXT NULL;
+
+-- The reason of an unassignment is passed by the changing transaction through
+-- the dmt.assignment_reason setting, see set_config(..., true).
+CREATE OR REPLACE FUNCTION record_device_assignment()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        IF TG_OP = 'UPDATE' AND OLD.employee IS NOT DISTINCT FROM NEW.employee THEN
+            RETURN NEW;
+        END IF;
+
+        UPDATE device_assignment
+        SET unassigned_at = NOW(),
+            unassign_reason = NULLIF(current_setting('dmt.assignment_reason', true), '')
+        WHERE device_id = OLD.id AND unassigned_at IS NULL;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.employee IS NOT NULL THEN
+        INSERT INTO device_assignment (device_id, employee, assigned_at)
+        VALUES (NEW.id, NEW.employee, NOW());
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TABLE IF NOT EXISTS offboarding (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by TEXT NOT NULL,
+    employee VARCHAR(3) NOT NULL,
+    reason TEXT NOT NULL,
+    reassigned_to VARCHAR(3) NULL,
+    completed_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX IF NOT EXISTS offboarding_employee_idx ON offboarding (employee, created_at);
+
+-- The checklist keeps a copy of the device, so it stays readable when the
+-- device is deleted before it is returned.
+CREATE TABLE IF NOT EXISTS offboarding_item (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    offboarding_id INTEGER NOT NULL REFERENCES offboarding (id) ON DELETE CASCADE,
+    device_id INTEGER NULL REFERENCES device (id) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    ip INET NULL,
+    mac MACADDR NOT NULL,
+    returned_at TIMESTAMP WITH TIME ZONE NULL,
+    returned_by TEXT NULL,
+    note TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS offboarding_item_offboarding_idx ON offboarding_item (offboarding_id);
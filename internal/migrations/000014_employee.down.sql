@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS device_employee_check_trigger ON device;
+DROP FUNCTION IF EXISTS check_device_employee();
+
+ALTER TABLE device DROP CONSTRAINT IF EXISTS device_employee_fkey;
+
+DROP TABLE IF EXISTS employee;
@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS employee (
+    abbreviation VARCHAR(3) PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    name TEXT NOT NULL,
+    email TEXT NULL UNIQUE,
+    department TEXT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- Employees of existing assignments are added with their abbreviation as name,
+-- so the foreign key can be created.
+INSERT INTO employee (abbreviation, name)
+    SELECT DISTINCT employee, employee
+    FROM device
+    WHERE employee IS NOT NULL
+    ON CONFLICT DO NOTHING;
+
+ALTER TABLE device
+    ADD CONSTRAINT device_employee_fkey
+    FOREIGN KEY (employee) REFERENCES employee (abbreviation);
+
+-- Rejects new assignments to unknown or inactive employees with errors naming
+-- the constraint, which the API reports as 422. Devices stay assigned when
+-- their employee is deactivated.
+CREATE OR REPLACE FUNCTION check_device_employee()
+RETURNS TRIGGER AS $$
+DECLARE
+    is_active BOOLEAN;
+BEGIN
+    IF NEW.employee IS NULL OR (TG_OP = 'UPDATE' AND NEW.employee IS NOT DISTINCT FROM OLD.employee) THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT active INTO is_active FROM employee WHERE abbreviation = NEW.employee;
+
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'employee % does not exist', NEW.employee
+            USING ERRCODE = 'foreign_key_violation', CONSTRAINT = 'device_employee_fkey';
+    END IF;
+
+    IF NOT is_active THEN
+        RAISE EXCEPTION 'employee % is inactive', NEW.employee
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'device_employee_active';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER device_employee_check_trigger
+    BEFORE INSERT OR UPDATE OF employee ON device
+    FOR EACH ROW
+    EXECUTE FUNCTION check_device_employee();
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS directory_sync;
+
+ALTER TABLE employee DROP COLUMN IF EXISTS source;
@@ -0,0 +1,18 @@
+-- Employees created by a directory sync are only deactivated by the same
+-- source, manually created employees are never touched by a sync.
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'manual';
+
+CREATE TABLE IF NOT EXISTS directory_sync (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    source TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('succeeded', 'failed')),
+    created INTEGER NOT NULL DEFAULT 0,
+    updated INTEGER NOT NULL DEFAULT 0,
+    deactivated INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    error TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS directory_sync_source_idx ON directory_sync (source, started_at);
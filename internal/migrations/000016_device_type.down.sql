@@ -0,0 +1,33 @@
+-- Restores the count of 000009, counting every device.
+CREATE OR REPLACE FUNCTION enqueue_device_count(target_employee TEXT)
+RETURNS VOID AS $$
+DECLARE
+    device_count INTEGER;
+    type_counts JSONB;
+    payload JSONB;
+BEGIN
+    SELECT COALESCE(SUM(counts.count), 0), COALESCE(jsonb_object_agg(counts.type, counts.count), '{}'::jsonb)
+    INTO device_count, type_counts
+    FROM (
+        SELECT device.type, COUNT(*) AS count
+        FROM device
+        WHERE device.employee = target_employee
+        GROUP BY device.type
+    ) AS counts;
+
+    payload := jsonb_build_object(
+        'employee', target_employee,
+        'count', device_count,
+        'types', type_counts
+    );
+
+    INSERT INTO notification_outbox (payload) VALUES (payload);
+
+    PERFORM pg_notify('device_count', payload::text);
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE notification_policy DROP CONSTRAINT IF EXISTS notification_policy_device_type_fkey;
+ALTER TABLE device DROP CONSTRAINT IF EXISTS device_type_fkey;
+
+DROP TABLE IF EXISTS device_type;
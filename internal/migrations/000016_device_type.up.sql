@@ -0,0 +1,64 @@
+CREATE TABLE IF NOT EXISTS device_type (
+    name TEXT PRIMARY KEY CHECK (name ~ '^[a-z0-9][a-z0-9_-]{0,31}$'),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    display_name TEXT NOT NULL,
+    icon TEXT NULL,
+    required_fields TEXT[] NOT NULL DEFAULT '{}',
+    counts_toward_limit BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+INSERT INTO device_type (name, display_name, icon) VALUES
+    ('desktop', 'Desktop', 'desktop'),
+    ('laptop', 'Laptop', 'laptop'),
+    ('phone', 'Phone', 'smartphone'),
+    ('tablet', 'Tablet', 'tablet')
+    ON CONFLICT DO NOTHING;
+
+-- Types referenced by policies are added as well, so the foreign keys can be
+-- created. Devices could only be created with the types above.
+INSERT INTO device_type (name, display_name)
+    SELECT DISTINCT device_type, device_type
+    FROM notification_policy
+    WHERE device_type IS NOT NULL
+    ON CONFLICT DO NOTHING;
+
+ALTER TABLE device
+    ADD CONSTRAINT device_type_fkey
+    FOREIGN KEY (type) REFERENCES device_type (name);
+
+ALTER TABLE notification_policy
+    ADD CONSTRAINT notification_policy_device_type_fkey
+    FOREIGN KEY (device_type) REFERENCES device_type (name);
+
+-- Devices of types not counting toward the limit are left out of the total
+-- count, the per-type counts still include them for type policies.
+CREATE OR REPLACE FUNCTION enqueue_device_count(target_employee TEXT)
+RETURNS VOID AS $$
+DECLARE
+    device_count INTEGER;
+    type_counts JSONB;
+    payload JSONB;
+BEGIN
+    SELECT COALESCE(SUM(counts.count) FILTER (WHERE counts.counts_toward_limit), 0),
+            COALESCE(jsonb_object_agg(counts.type, counts.count), '{}'::jsonb)
+    INTO device_count, type_counts
+    FROM (
+        SELECT device.type, device_type.counts_toward_limit, COUNT(*) AS count
+        FROM device
+        JOIN device_type ON device_type.name = device.type
+        WHERE device.employee = target_employee
+        GROUP BY device.type, device_type.counts_toward_limit
+    ) AS counts;
+
+    payload := jsonb_build_object(
+        'employee', target_employee,
+        'count', device_count,
+        'types', type_counts
+    );
+
+    INSERT INTO notification_outbox (payload) VALUES (payload);
+
+    PERFORM pg_notify('device_count', payload::text);
+END;
+$$ LANGUAGE plpgsql;
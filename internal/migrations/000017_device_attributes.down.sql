@@ -0,0 +1,7 @@
+DROP FUNCTION IF EXISTS jsonb_merge_patch(JSONB, JSONB);
+
+ALTER TABLE device_type DROP COLUMN IF EXISTS attribute_schema;
+
+DROP INDEX IF EXISTS device_attributes_idx;
+
+ALTER TABLE device DROP COLUMN IF EXISTS attributes;
@@ -0,0 +1,35 @@
+ALTER TABLE device
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}'
+    CONSTRAINT device_attributes_object CHECK (jsonb_typeof(attributes) = 'object');
+
+-- Serves the equality attribute filters, which are translated into jsonpath
+-- queries. jsonb_path_ops can't serve range comparisons, those scan the table.
+CREATE INDEX IF NOT EXISTS device_attributes_idx ON device USING GIN (attributes jsonb_path_ops);
+
+ALTER TABLE device_type ADD COLUMN IF NOT EXISTS attribute_schema JSONB NULL;
+
+-- Applies a JSON Merge Patch (RFC 7396): members set to null are removed,
+-- objects are merged recursively and any other value replaces the target.
+CREATE OR REPLACE FUNCTION jsonb_merge_patch(target JSONB, patch JSONB)
+RETURNS JSONB AS $$
+BEGIN
+    IF jsonb_typeof(patch) <> 'object' THEN
+        RETURN patch;
+    END IF;
+
+    IF target IS NULL OR jsonb_typeof(target) <> 'object' THEN
+        target := '{}';
+    END IF;
+
+    RETURN (
+        SELECT COALESCE(jsonb_object_agg(key, value), '{}')
+        FROM (
+            SELECT key,
+                CASE WHEN p.value IS NULL THEN t.value ELSE jsonb_merge_patch(t.value, p.value) END AS value
+            FROM jsonb_each(target) AS t
+            FULL JOIN jsonb_each(patch) AS p USING (key)
+            WHERE p.value IS NULL OR p.value <> 'null'::jsonb
+        ) AS merged
+    );
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
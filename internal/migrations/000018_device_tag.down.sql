@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS device_tag;
+
+DROP TABLE IF EXISTS tag;
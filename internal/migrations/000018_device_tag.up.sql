@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS tag (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    name TEXT NOT NULL UNIQUE CHECK (name ~ '^[a-z0-9][a-z0-9_-]{0,63}$')
+);
+
+CREATE TABLE IF NOT EXISTS device_tag (
+    device_id INTEGER NOT NULL REFERENCES device (id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tag (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device_id, tag_id)
+);
+
+-- The primary key serves lookups by device, this one the tag filters and
+-- usage counts.
+CREATE INDEX IF NOT EXISTS device_tag_tag_id_idx ON device_tag (tag_id, device_id);
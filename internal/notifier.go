@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"dmt/internal/config"
+	"dmt/pkg/device"
+	"fmt"
+)
+
+// CreateNotifier routes notifications to every channel configured in the
+// environment. It returns nil if no channel is configured.
+func CreateNotifier() (device.Notifier, error) {
+	var routes []device.Route
+	secrets := config.GetNotifySecrets()
+
+	if url := config.GetNotifyUrl(); url != "" {
+		routes = append(routes, device.Route{
+			Notifier: device.NewWebhookNotifier(url, secrets...),
+			Levels:   config.GetNotifyLevels("NOTIFY_LEVELS"),
+		})
+	}
+
+	if webhook := config.GetWebhookConfig(); webhook.URL != "" {
+		notifier, err := device.NewTemplateNotifier(webhook.URL, webhook.Template, secrets...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create webhook notifier: %w", err)
+		}
+
+		routes = append(routes, device.Route{
+			Notifier: notifier,
+			Levels:   config.GetNotifyLevels("NOTIFY_WEBHOOK_LEVELS"),
+		})
+	}
+
+	if smtp := config.GetSMTPConfig(); smtp.Addr != "" {
+		routes = append(routes, device.Route{
+			Notifier: device.NewEmailNotifier(device.EmailConfig{
+				Addr:     smtp.Addr,
+				Username: smtp.Username,
+				Password: smtp.Password,
+				From:     smtp.From,
+				To:       smtp.To,
+			}),
+			Levels: config.GetNotifyLevels("SMTP_LEVELS"),
+		})
+	}
+
+	if len(routes) == 0 {
+		return nil, nil
+	}
+
+	return device.NewRouter(routes...), nil
+}
@@ -5,6 +5,8 @@ import (
 	"dmt/internal"
 	"dmt/internal/config"
 	"dmt/pkg/device"
+	"dmt/pkg/event"
+	"dmt/pkg/webhook"
 	"log"
 	"os"
 	"os/signal"
@@ -13,9 +15,11 @@ import (
 
 func main() {
 	apiKey := config.GetAPIKey()
+	scimToken := config.GetSCIMToken()
 	databaseURL := config.GetDatabaseURL()
-	notificationUrl := config.GetNotifyUrl()
 	port := config.GetPort()
+	instanceID := config.GetInstanceID()
+	webhook.AllowInternalTargets = config.GetWebhookAllowInternalTargets()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -25,11 +29,22 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	if err := device.HandleDeviceCountNotifications(ctx, db, notificationUrl); err != nil {
-		log.Fatalf("Failed to start notification handler: %v", err)
+	notifier, err := internal.CreateNotifier()
+	if err != nil {
+		log.Fatalf("Failed to configure notifications: %v", err)
+	}
+
+	pipeline := device.HandleDeviceCountNotifications(ctx, db, notifier, instanceID)
+
+	broker := event.NewBroker(db, event.DefaultBrokerConfig, instanceID)
+	go broker.Run(ctx)
+
+	syncer := internal.CreateDirectorySyncer(db, instanceID)
+	if syncer != nil {
+		go syncer.Run(ctx)
 	}
 
-	server := internal.CreateHttpServer(db, apiKey)
+	server := internal.CreateHttpServer(db, apiKey, scimToken, pipeline, broker, syncer)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,156 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const keyColumns = "id, name, prefix, scopes, secret_hash, created_at, expires_at, revoked_at, last_used_at"
+
+// InsertKey stores a new key and returns its secret. The secret is not
+// persisted and cannot be retrieved again.
+func InsertKey(ctx context.Context, db *pgxpool.Pool, key *Key) (string, error) {
+	prefix, secret, err := generateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+	INSERT INTO api_key (name, prefix, scopes, secret_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + keyColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, key.Name, prefix, key.Scopes, hashSecret(secret), key.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	inserted, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Key])
+	if err != nil {
+		return "", err
+	}
+	*key = inserted
+
+	return secret, nil
+}
+
+// RotateKey replaces the secret of an active key and returns the new secret.
+func RotateKey(ctx context.Context, db *pgxpool.Pool, key *Key) (string, error) {
+	prefix, secret, err := generateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+		UPDATE api_key
+		SET prefix = $1, secret_hash = $2, last_used_at = NULL
+		WHERE id = $3 AND revoked_at IS NULL
+		RETURNING ` + keyColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, prefix, hashSecret(secret), key.ID)
+	if err != nil {
+		return "", err
+	}
+
+	rotated, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Key])
+	if err != nil {
+		return "", err
+	}
+	*key = rotated
+
+	return secret, nil
+}
+
+func RevokeKey(ctx context.Context, db *pgxpool.Pool, key *Key) error {
+	query := `
+		UPDATE api_key
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING ` + keyColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, key.ID)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Key])
+	if err != nil {
+		return err
+	}
+	*key = revoked
+
+	return nil
+}
+
+func GetKeys(ctx context.Context, db *pgxpool.Pool) ([]Key, error) {
+	query := `
+		SELECT ` + keyColumns + `
+		FROM api_key
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys, err := pgx.CollectRows(rows, pgx.RowToStructByName[Key])
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func GetKeyByPrefix(ctx context.Context, db *pgxpool.Pool, prefix string) (*Key, error) {
+	query := `
+		SELECT ` + keyColumns + `
+		FROM api_key
+		WHERE prefix = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Key])
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func TouchKey(ctx context.Context, db *pgxpool.Pool, key *Key) error {
+	query := `
+		UPDATE api_key
+		SET last_used_at = NOW()
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := db.Exec(ctx, query, key.ID)
+	return err
+}
@@ -0,0 +1,164 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type KeyHandler struct {
+	db        *pgxpool.Pool
+	validator *Validator
+}
+
+func NewKeyHandler(db *pgxpool.Pool, validator *Validator) *KeyHandler {
+	return &KeyHandler{db: db, validator: validator}
+}
+
+func (s *KeyHandler) CreateKey(c *fiber.Ctx) error {
+	var requestBody struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	err := c.BodyParser(&requestBody)
+	if err != nil {
+		log.Errorf("Invalid JSON format: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	key := &Key{
+		Name:      strings.TrimSpace(requestBody.Name),
+		Scopes:    requestBody.Scopes,
+		ExpiresAt: requestBody.ExpiresAt,
+	}
+
+	if key.Name == "" || len(key.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name is required and must be less than 100 characters",
+		})
+	}
+
+	if len(key.Scopes) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "at least one scope is required",
+		})
+	}
+
+	for _, scope := range key.Scopes {
+		if !IsKnownScope(scope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("unknown scope %q", scope),
+			})
+		}
+	}
+
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "expires_at must be in the future",
+		})
+	}
+
+	secret, err := InsertKey(c.Context(), s.db, key)
+	if err != nil {
+		log.Errorf("Failed to create API key: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create API key",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "API key created successfully, the secret is only shown once",
+		"key":     key,
+		"secret":  secret,
+	})
+}
+
+func (s *KeyHandler) GetKeys(c *fiber.Ctx) error {
+	keys, err := GetKeys(c.Context(), s.db)
+	if err != nil {
+		log.Errorf("Failed to retrieve API keys: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve API keys",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"keys":  keys,
+		"count": len(keys),
+	})
+}
+
+func (s *KeyHandler) RotateKey(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Errorf("Invalid API key ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid API key ID",
+		})
+	}
+
+	key := &Key{ID: id}
+	secret, err := RotateKey(c.Context(), s.db, key)
+	if err != nil {
+		log.Errorf("Failed to rotate API key: %s", err.Error())
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "API key not found or revoked",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to rotate API key",
+		})
+	}
+
+	s.validator.Invalidate(id)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "API key rotated successfully, the secret is only shown once",
+		"key":     key,
+		"secret":  secret,
+	})
+}
+
+func (s *KeyHandler) RevokeKey(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Errorf("Invalid API key ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid API key ID",
+		})
+	}
+
+	key := &Key{ID: id}
+	err = RevokeKey(c.Context(), s.db, key)
+	if err != nil {
+		log.Errorf("Failed to revoke API key: %s", err.Error())
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "API key not found or already revoked",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke API key",
+		})
+	}
+
+	s.validator.Invalidate(id)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "API key revoked successfully",
+		"key":     key,
+	})
+}
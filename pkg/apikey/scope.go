@@ -0,0 +1,36 @@
+package apikey
+
+import "slices"
+
+const (
+	ScopeDevicesRead      = "devices:read"
+	ScopeDevicesWrite     = "devices:write"
+	ScopeDevicesDelete    = "devices:delete"
+	ScopeAssignmentsWrite = "assignments:write"
+	ScopeWebhooksManage   = "webhooks:manage"
+	ScopeEmployeesWrite   = "employees:write"
+	// ScopeAdmin grants every other scope.
+	ScopeAdmin = "admin"
+)
+
+// ScopesLocal is the fiber.Ctx locals key under which the authentication
+// middleware stores the scopes of the caller.
+const ScopesLocal = "scopes"
+
+var knownScopes = []string{
+	ScopeDevicesRead,
+	ScopeDevicesWrite,
+	ScopeDevicesDelete,
+	ScopeAssignmentsWrite,
+	ScopeWebhooksManage,
+	ScopeEmployeesWrite,
+	ScopeAdmin,
+}
+
+func IsKnownScope(scope string) bool {
+	return slices.Contains(knownScopes, scope)
+}
+
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, ScopeAdmin) || slices.Contains(scopes, scope)
+}
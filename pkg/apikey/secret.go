@@ -0,0 +1,45 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const secretPrefix = "dmt"
+
+var ErrMalformedKey = errors.New("malformed API key")
+
+// generateSecret creates a new key of the form dmt_<prefix>_<secret>. The
+// prefix is stored in clear text to look the key up, the full key only as hash.
+func generateSecret() (prefix string, secret string, err error) {
+	prefixBytes := make([]byte, 6)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", err
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	secret = strings.Join([]string{secretPrefix, prefix, hex.EncodeToString(secretBytes)}, "_")
+
+	return prefix, secret, nil
+}
+
+func parseSecret(secret string) (string, error) {
+	parts := strings.Split(secret, "_")
+	if len(parts) != 3 || parts[0] != secretPrefix || parts[1] == "" || parts[2] == "" {
+		return "", ErrMalformedKey
+	}
+	return parts[1], nil
+}
+
+func hashSecret(secret string) []byte {
+	hash := sha256.Sum256([]byte(secret))
+	return hash[:]
+}
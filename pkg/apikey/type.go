@@ -0,0 +1,28 @@
+package apikey
+
+import "time"
+
+type Key struct {
+	ID         int        `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	SecretHash []byte     `json:"-" db:"secret_hash"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+// Active reports whether the key is neither revoked nor expired at the given time.
+func (k *Key) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || k.ExpiresAt.After(now)
+}
+
+// Actor is the identity recorded for requests made with this key.
+func (k *Key) Actor() string {
+	return "key:" + k.Prefix
+}
@@ -0,0 +1,96 @@
+package apikey
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DefaultCacheTTL = 30 * time.Second
+
+var ErrInvalidKey = errors.New("invalid API key")
+
+type cacheEntry struct {
+	key     *Key
+	expires time.Time
+}
+
+// Validator checks presented secrets against the api_key table. Successfully
+// validated keys are cached for the TTL, which also throttles last_used_at
+// updates to one per TTL and instance.
+type Validator struct {
+	db    *pgxpool.Pool
+	ttl   time.Duration
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func NewValidator(db *pgxpool.Pool, ttl time.Duration) *Validator {
+	return &Validator{
+		db:    db,
+		ttl:   ttl,
+		cache: make(map[string]cacheEntry),
+	}
+}
+
+func (v *Validator) Validate(ctx context.Context, secret string) (*Key, error) {
+	hash := hashSecret(secret)
+	now := time.Now()
+
+	v.mu.Lock()
+	entry, ok := v.cache[string(hash)]
+	v.mu.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		if !entry.key.Active(now) {
+			return nil, ErrInvalidKey
+		}
+		return entry.key, nil
+	}
+
+	prefix, err := parseSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := GetKeyByPrefix(ctx, v.db, prefix)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(hash, key.SecretHash) != 1 || !key.Active(now) {
+		return nil, ErrInvalidKey
+	}
+
+	if err := TouchKey(ctx, v.db, key); err != nil {
+		log.Errorf("Failed to update last usage of API key %s: %v", key.Prefix, err)
+	}
+
+	v.mu.Lock()
+	v.cache[string(hash)] = cacheEntry{key: key, expires: now.Add(v.ttl)}
+	v.mu.Unlock()
+
+	return key, nil
+}
+
+// Invalidate drops all cached entries of the key with the given ID so that
+// revocations and rotations take effect immediately on this instance.
+func (v *Validator) Invalidate(id int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for hash, entry := range v.cache {
+		if entry.key.ID == id || time.Now().After(entry.expires) {
+			delete(v.cache, hash)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func GetDeviceAssignments(ctx context.Context, db *pgxpool.Pool, deviceID int, timeRange *TimeRange) ([]Assignment, error) {
+	return getAssignments(ctx, db, "device_id", deviceID, timeRange)
+}
+
+func GetEmployeeAssignments(ctx context.Context, db *pgxpool.Pool, employee string, timeRange *TimeRange) ([]Assignment, error) {
+	return getAssignments(ctx, db, "employee", employee, timeRange)
+}
+
+func getAssignments(ctx context.Context, db *pgxpool.Pool, column string, value interface{}, timeRange *TimeRange) ([]Assignment, error) {
+	query := fmt.Sprintf(`
+		SELECT id, device_id, employee, assigned_at, unassigned_at, unassign_reason
+		FROM device_assignment
+		WHERE %s = $1
+	`, column)
+
+	args := []interface{}{value}
+
+	if timeRange.To != nil {
+		args = append(args, *timeRange.To)
+		query += fmt.Sprintf(" AND assigned_at <= $%d", len(args))
+	}
+
+	if timeRange.From != nil {
+		args = append(args, *timeRange.From)
+		query += fmt.Sprintf(" AND (unassigned_at IS NULL OR unassigned_at > $%d)", len(args))
+	}
+
+	query += " ORDER BY assigned_at DESC, id DESC"
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments, err := pgx.CollectRows(rows, pgx.RowToStructByName[Assignment])
+	if err != nil {
+		return nil, err
+	}
+
+	return assignments, nil
+}
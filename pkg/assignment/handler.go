@@ -0,0 +1,138 @@
+package assignment
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const dateLayout = "2006-01-02"
+
+type AssignmentHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewAssignmentHandler(db *pgxpool.Pool) *AssignmentHandler {
+	return &AssignmentHandler{db: db}
+}
+
+func (s *AssignmentHandler) GetDeviceAssignments(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Errorf("Invalid device ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid device ID",
+		})
+	}
+
+	timeRange, err := parseTimeRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	assignments, err := GetDeviceAssignments(c.Context(), s.db, id, timeRange)
+	if err != nil {
+		log.Errorf("Failed to retrieve device assignments: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve device assignments",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"assignments": assignments,
+		"count":       len(assignments),
+	})
+}
+
+func (s *AssignmentHandler) GetEmployeeAssignments(c *fiber.Ctx) error {
+	employee := c.Params("abbr")
+	if len(employee) != 3 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid employee abbreviation",
+		})
+	}
+
+	timeRange, err := parseTimeRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	assignments, err := GetEmployeeAssignments(c.Context(), s.db, employee, timeRange)
+	if err != nil {
+		log.Errorf("Failed to retrieve employee assignments: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve employee assignments",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"assignments": assignments,
+		"count":       len(assignments),
+	})
+}
+
+// parseTimeRange reads the from, to and at query parameters. Values are either
+// RFC 3339 timestamps or dates; a date passed as at covers the whole day.
+func parseTimeRange(c *fiber.Ctx) (*TimeRange, error) {
+	timeRange := &TimeRange{}
+
+	if at := c.Query("at"); at != "" {
+		if c.Query("from") != "" || c.Query("to") != "" {
+			return nil, errors.New("at cannot be combined with from or to")
+		}
+
+		from, err := parseTime(at, false)
+		if err != nil {
+			return nil, errors.New("invalid at timestamp")
+		}
+		to, _ := parseTime(at, true)
+
+		timeRange.From = &from
+		timeRange.To = &to
+		return timeRange, nil
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseTime(from, false)
+		if err != nil {
+			return nil, errors.New("invalid from timestamp")
+		}
+		timeRange.From = &t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, err := parseTime(to, true)
+		if err != nil {
+			return nil, errors.New("invalid to timestamp")
+		}
+		timeRange.To = &t
+	}
+
+	if timeRange.From != nil && timeRange.To != nil && timeRange.To.Before(*timeRange.From) {
+		return nil, errors.New("from must be before to")
+	}
+
+	return timeRange, nil
+}
+
+// parseTime accepts RFC 3339 timestamps and dates. A date used as upper bound
+// is extended to the end of that day.
+func parseTime(value string, endOfDay bool) (time.Time, error) {
+	if day, err := time.Parse(dateLayout, value); err == nil {
+		if endOfDay {
+			return day.AddDate(0, 0, 1).Add(-time.Microsecond), nil
+		}
+		return day, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
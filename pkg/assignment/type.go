@@ -0,0 +1,21 @@
+package assignment
+
+import "time"
+
+type Assignment struct {
+	ID           int        `json:"id" db:"id"`
+	DeviceID     int        `json:"device_id" db:"device_id"`
+	Employee     string     `json:"employee" db:"employee"`
+	AssignedAt   time.Time  `json:"assigned_at" db:"assigned_at"`
+	UnassignedAt *time.Time `json:"unassigned_at" db:"unassigned_at"`
+	// UnassignReason is set when the assignment was ended by an operation
+	// giving a reason, e.g. an offboarding.
+	UnassignReason *string `json:"unassign_reason" db:"unassign_reason"`
+}
+
+// TimeRange selects assignments that were active at any point between From and
+// To. Both bounds are inclusive and optional.
+type TimeRange struct {
+	From *time.Time
+	To   *time.Time
+}
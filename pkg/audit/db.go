@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+func InsertEntry(ctx context.Context, db *pgxpool.Pool, entry *Entry) error {
+	return insertEntry(ctx, db, entry)
+}
+
+// querier is implemented by the pool as well as by transactions.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertEntry(ctx context.Context, db querier, entry *Entry) error {
+	query := `
+	INSERT INTO audit_log (actor, action, device_id, before, after, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err := db.QueryRow(ctx, query,
+		entry.Actor,
+		entry.Action,
+		entry.DeviceID,
+		entry.Before,
+		entry.After,
+		entry.RequestID,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetEntries(ctx context.Context, db *pgxpool.Pool, filter *Filter) ([]Entry, error) {
+	query := `
+		SELECT id, created_at, actor, action, device_id, before, after, request_id
+		FROM audit_log
+		WHERE 1=1
+	`
+
+	args := []interface{}{}
+
+	if filter.Actor != "" {
+		args = append(args, filter.Actor)
+		query += fmt.Sprintf(" AND actor = $%d", len(args))
+	}
+
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		query += fmt.Sprintf(" AND action = $%d", len(args))
+	}
+
+	if filter.DeviceID > 0 {
+		args = append(args, filter.DeviceID)
+		query += fmt.Sprintf(" AND device_id = $%d", len(args))
+	}
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		query += fmt.Sprintf(" AND created_at <= $%d", len(args))
+	}
+
+	limit := filter.Limit
+	if limit < 1 || limit > maxLimit {
+		limit = defaultLimit
+	}
+
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries, err := pgx.CollectRows(rows, pgx.RowToStructByName[Entry])
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
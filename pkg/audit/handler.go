@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditHandler(db *pgxpool.Pool) *AuditHandler {
+	return &AuditHandler{db: db}
+}
+
+func (s *AuditHandler) GetEntries(c *fiber.Ctx) error {
+	filter := &Filter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+	}
+
+	if deviceID := c.Query("device_id"); deviceID != "" {
+		id, err := strconv.Atoi(deviceID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid device ID",
+			})
+		}
+		filter.DeviceID = id
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid from timestamp",
+			})
+		}
+		filter.From = &t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid to timestamp",
+			})
+		}
+		filter.To = &t
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 || l > maxLimit {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid limit",
+			})
+		}
+		filter.Limit = l
+	}
+
+	entries, err := GetEntries(c.Context(), s.db, filter)
+	if err != nil {
+		log.Errorf("Failed to retrieve audit entries: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve audit entries",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Record writes an audit entry for a mutating request as part of tx, the
+// transaction applying the change, so the change is only committed with its
+// entry. Snapshots may be nil, e.g. there is no before state on creation.
+func Record(c *fiber.Ctx, tx pgx.Tx, action string, deviceID int, before, after any) error {
+	entry := &Entry{
+		Actor:    actor(c),
+		Action:   action,
+		DeviceID: &deviceID,
+	}
+
+	if requestID, ok := c.Locals("requestid").(string); ok && requestID != "" {
+		entry.RequestID = &requestID
+	}
+
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		return fmt.Errorf("failed to marshal audit snapshot: %w", err)
+	}
+	if entry.After, err = snapshot(after); err != nil {
+		return fmt.Errorf("failed to marshal audit snapshot: %w", err)
+	}
+
+	if err := insertEntry(c.Context(), tx, entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func actor(c *fiber.Ctx) string {
+	if actor, ok := c.Locals(ActorLocal).(string); ok && actor != "" {
+		return actor
+	}
+	return "unknown"
+}
+
+func snapshot(value any) (json.RawMessage, error) {
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" {
+		return nil, err
+	}
+	return data, nil
+}
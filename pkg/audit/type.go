@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionReplace  = "replace"
+	ActionDelete   = "delete"
+	ActionAssign   = "assign"
+	ActionUnassign = "unassign"
+)
+
+// ActorLocal is the fiber.Ctx locals key under which the authentication
+// middleware stores the identity of the caller.
+const ActorLocal = "actor"
+
+type Entry struct {
+	ID        int64           `json:"id" db:"id"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	Actor     string          `json:"actor" db:"actor"`
+	Action    string          `json:"action" db:"action"`
+	DeviceID  *int            `json:"device_id" db:"device_id"`
+	Before    json.RawMessage `json:"before" db:"before"`
+	After     json.RawMessage `json:"after" db:"after"`
+	RequestID *string         `json:"request_id" db:"request_id"`
+}
+
+// Filter narrows down audit log queries. Empty fields are ignored.
+type Filter struct {
+	Actor    string
+	Action   string
+	DeviceID int
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+}
@@ -0,0 +1,121 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	LevelWarning = "warning"
+	LevelInfo    = "info"
+)
+
+// ErrAlertStateSuperseded is returned when saving the alert state of an
+// outbox entry older than the one the state already reflects.
+var ErrAlertStateSuperseded = errors.New("alert state already reflects a newer notification")
+
+// AlertState is what an employee was last alerted about.
+type AlertState struct {
+	// PolicyIDs are the policies the employee is in violation of, empty if
+	// the employee complies with all policies.
+	PolicyIDs []int
+	// OutboxID is the newest outbox entry applied to the state.
+	OutboxID int64
+}
+
+// supersedes reports whether the state already reflects a newer notification
+// than the entry, e.g. when the entry is retried after a later one was
+// delivered.
+func (s *AlertState) supersedes(entry *OutboxEntry) bool {
+	return entry.ID <= s.OutboxID
+}
+
+// GetAlertState returns the alert state of the employee, an empty state if
+// the employee has never been alerted.
+func GetAlertState(ctx context.Context, db *pgxpool.Pool, employee string) (*AlertState, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	state := &AlertState{}
+	err := db.QueryRow(ctx, `SELECT policy_ids, outbox_id FROM employee_alert_state WHERE employee = $1`, employee).Scan(&state.PolicyIDs, &state.OutboxID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// SaveAlertState stores the violated policies of the employee as of the given
+// outbox entry. An empty list clears the violations. The state is left alone
+// if a newer entry has been applied in the meantime, in which case
+// ErrAlertStateSuperseded is returned.
+func SaveAlertState(ctx context.Context, db *pgxpool.Pool, employee string, entry *OutboxEntry, policyIDs []int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if policyIDs == nil {
+		policyIDs = []int{}
+	}
+
+	tag, err := db.Exec(ctx, `
+		INSERT INTO employee_alert_state (employee, policy_ids, outbox_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (employee) DO UPDATE
+		SET policy_ids = EXCLUDED.policy_ids, outbox_id = EXCLUDED.outbox_id, updated_at = NOW()
+		WHERE employee_alert_state.outbox_id < EXCLUDED.outbox_id`,
+		employee, policyIDs, entry.ID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlertStateSuperseded
+	}
+
+	return nil
+}
+
+func violatedPolicyIDs(violations []Violation) []int {
+	ids := make([]int, 0, len(violations))
+	for _, violation := range violations {
+		ids = append(ids, violation.Policy.ID)
+	}
+	return ids
+}
+
+// alertFor decides which notification a change of the device counts needs.
+// An employee is warned once per violated policy and told when all
+// violations are resolved. It returns nil if nothing has to be sent.
+func alertFor(notification *Notification, violations []Violation, alerted []int) *NotificationRequest {
+	if len(violations) == 0 {
+		if len(alerted) == 0 {
+			return nil
+		}
+
+		return &NotificationRequest{
+			Level:                LevelInfo,
+			EmployeeAbbreviation: notification.Employee,
+			Message:              fmt.Sprintf("Device count resolved: Employee %s has %d devices and complies with all policies", notification.Employee, notification.Count),
+		}
+	}
+
+	for _, violation := range violations {
+		if !slices.Contains(alerted, violation.Policy.ID) {
+			return &NotificationRequest{
+				Level:                LevelWarning,
+				EmployeeAbbreviation: notification.Employee,
+				Message:              fmt.Sprintf("Device count warning: Employee %s has %d devices - %s", notification.Employee, notification.Count, describeViolations(violations)),
+			}
+		}
+	}
+
+	return nil
+}
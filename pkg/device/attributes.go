@@ -0,0 +1,191 @@
+package device
+
+import (
+	"dmt/pkg/jsonschema"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AttributeFilterPrefix starts the query parameters filtering devices by their
+// attributes, e.g. attr.ram_gb>=16.
+const AttributeFilterPrefix = "attr."
+
+// The operators of attribute filters and their jsonpath counterparts.
+var attributeOperators = map[string]string{
+	"=":  "==",
+	"!=": "!=",
+	">":  ">",
+	">=": ">=",
+	"<":  "<",
+	"<=": "<=",
+}
+
+var attributeFilterPattern = regexp.MustCompile(`^([A-Za-z0-9_]+(?:\.[A-Za-z0-9_]+)*)(>=|<=|!=|=|>|<)(.+)$`)
+
+// AttributeFilter compares the attribute at a dot separated path with a
+// value. Devices lacking the attribute never match, also not for !=.
+type AttributeFilter struct {
+	Path     []string
+	Operator string
+	Value    any
+
+	expression string
+}
+
+// ParseAttributeFilter reads a filter like ram_gb>=16 or os.name=macOS. Values
+// are compared as numbers or booleans if they look like one, as strings
+// otherwise. A value in double quotes is always a string.
+func ParseAttributeFilter(expression string) (AttributeFilter, error) {
+	match := attributeFilterPattern.FindStringSubmatch(expression)
+	if match == nil {
+		return AttributeFilter{}, fmt.Errorf("invalid attribute filter %q", expression)
+	}
+
+	value, err := parseAttributeValue(match[3])
+	if err != nil {
+		return AttributeFilter{}, fmt.Errorf("invalid attribute filter %q: %w", expression, err)
+	}
+
+	return AttributeFilter{
+		Path:       strings.Split(match[1], "."),
+		Operator:   match[2],
+		Value:      value,
+		expression: expression,
+	}, nil
+}
+
+func parseAttributeValue(raw string) (any, error) {
+	if strings.HasPrefix(raw, `"`) {
+		var s string
+		if err := json.Unmarshal([]byte(raw), &s); err != nil {
+			return nil, errors.New("invalid quoted value")
+		}
+		return s, nil
+	}
+
+	switch raw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	if n, err := strconv.ParseFloat(raw, 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+		return n, nil
+	}
+
+	return raw, nil
+}
+
+func (f AttributeFilter) String() string {
+	return f.expression
+}
+
+func (f AttributeFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.expression)
+}
+
+func (f *AttributeFilter) UnmarshalJSON(data []byte) error {
+	var expression string
+	if err := json.Unmarshal(data, &expression); err != nil {
+		return errors.New("attribute filters must be strings")
+	}
+
+	parsed, err := ParseAttributeFilter(expression)
+	if err != nil {
+		return err
+	}
+	*f = parsed
+
+	return nil
+}
+
+// jsonPath translates the filter into a jsonpath predicate for the @?
+// operator. The GIN index on attributes only serves equality; the other
+// operators are evaluated row by row. The path only holds word characters
+// and the value is encoded as a literal, so nothing of the expression ends up
+// in the query unescaped.
+func (f AttributeFilter) jsonPath() string {
+	var path strings.Builder
+	path.WriteString("$")
+	for _, key := range f.Path {
+		path.WriteString(`."` + key + `"`)
+	}
+
+	var literal string
+	switch v := f.Value.(type) {
+	case float64:
+		literal = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		literal = strconv.FormatBool(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		literal = string(encoded)
+	}
+
+	return fmt.Sprintf("%s ? (@ %s %s)", path.String(), attributeOperators[f.Operator], literal)
+}
+
+// FieldError is a validation error of a single field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// FieldErrors returns the field errors among the problems of a validation
+// error.
+func FieldErrors(err error) []FieldError {
+	var errs validationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+
+	fields := []FieldError{}
+	for _, err := range errs {
+		var field FieldError
+		if errors.As(err, &field) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// compileAttributeSchema compiles the attribute schema of a type. A type
+// without a schema accepts any attributes. Schemas using keywords the
+// validator doesn't implement are rejected, at any depth.
+func compileAttributeSchema(schema json.RawMessage) (*jsonschema.Schema, error) {
+	if len(schema) == 0 {
+		return nil, nil
+	}
+	return jsonschema.Compile(schema)
+}
+
+// attributeErrors validates the attributes of the device against the schema
+// of its type.
+func attributeErrors(device *Device, deviceType DeviceType) []error {
+	if deviceType.schema == nil {
+		return nil
+	}
+
+	attributes := device.Attributes
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+
+	errs := []error{}
+	for _, err := range deviceType.schema.Validate("attributes", attributes) {
+		errs = append(errs, FieldError{Field: err.Path, Message: err.Message})
+	}
+
+	return errs
+}
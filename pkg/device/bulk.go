@@ -0,0 +1,308 @@
+package device
+
+import (
+	"context"
+	"dmt/pkg/employee"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// MaxBulkDevices is the number of devices a single bulk operation may
+	// change.
+	MaxBulkDevices = 1000
+	// MaxBulkBodySize is the body limit of the bulk route, enough for
+	// MaxBulkDevices ids.
+	MaxBulkBodySize = 64 << 10
+)
+
+const (
+	BulkUnassign = "unassign"
+	BulkReassign = "reassign"
+	BulkDelete   = "delete"
+)
+
+const (
+	BulkResultUnassigned = "unassigned"
+	BulkResultReassigned = "reassigned"
+	BulkResultDeleted    = "deleted"
+	// BulkResultUnchanged marks a device that already was in the requested
+	// state, e.g. an unassigned device that should be unassigned.
+	BulkResultUnchanged = "unchanged"
+	BulkResultNotFound  = "not_found"
+)
+
+// BulkRequest selects devices either by ID or by the filters of GetDevices
+// and applies an action to all of them. Employee is the new owner of a
+// reassignment.
+type BulkRequest struct {
+	Action   string        `json:"action"`
+	IDs      []int         `json:"ids"`
+	Filter   *DeviceFilter `json:"filter"`
+	Employee *string       `json:"employee"`
+}
+
+type BulkResult struct {
+	ID               int     `json:"id"`
+	Status           string  `json:"status"`
+	PreviousEmployee *string `json:"previous_employee,omitempty"`
+	Device           *Device `json:"device,omitempty"`
+
+	before *Device
+}
+
+type BulkReport struct {
+	Action  string       `json:"action"`
+	Matched int          `json:"matched"`
+	Changed int          `json:"changed"`
+	Results []BulkResult `json:"results"`
+}
+
+func (r *BulkRequest) validate() []error {
+	var errs []error
+
+	switch r.Action {
+	case BulkUnassign, BulkDelete:
+		if r.Employee != nil {
+			errs = append(errs, fmt.Errorf("employee is only allowed for %s", BulkReassign))
+		}
+	case BulkReassign:
+		if r.Employee == nil || *r.Employee == "" {
+			errs = append(errs, errors.New("employee is required"))
+		} else if err := validateEmployee(r.Employee); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		errs = append(errs, errors.New("action must be unassign, reassign or delete"))
+	}
+
+	switch {
+	case r.IDs != nil && r.Filter != nil:
+		errs = append(errs, errors.New("either ids or filter must be given, not both"))
+	case r.IDs != nil:
+		if len(r.IDs) == 0 || len(r.IDs) > MaxBulkDevices {
+			errs = append(errs, fmt.Errorf("ids must contain between 1 and %d devices", MaxBulkDevices))
+		}
+		if slices.ContainsFunc(r.IDs, func(id int) bool { return id < 1 }) {
+			errs = append(errs, errors.New("ids must be positive"))
+		}
+	case r.Filter != nil:
+		// An empty filter would select every device.
+		if r.Filter.isEmpty() {
+			errs = append(errs, errors.New("filter must not be empty"))
+		}
+	default:
+		errs = append(errs, errors.New("ids or filter is required"))
+	}
+
+	return errs
+}
+
+// changes reports whether the action changes the device.
+func (r *BulkRequest) changes(device *Device) bool {
+	switch r.Action {
+	case BulkUnassign:
+		return device.Employee != nil
+	case BulkReassign:
+		return device.Employee == nil || *device.Employee != *r.Employee
+	}
+	return true
+}
+
+func (r *BulkRequest) resultStatus() string {
+	switch r.Action {
+	case BulkUnassign:
+		return BulkResultUnassigned
+	case BulkReassign:
+		return BulkResultReassigned
+	}
+	return BulkResultDeleted
+}
+
+// BulkUpdate applies the request in a single transaction. The selected
+// devices are locked first and then changed by one statement, so the device
+// count trigger reports the final count of every previous and new owner once.
+func BulkUpdate(ctx context.Context, db *pgxpool.Pool, request *BulkRequest) (*BulkReport, error) {
+	return bulkUpdate(ctx, db, request, nil)
+}
+
+// bulkUpdate is BulkUpdate, auditing every changed device.
+func bulkUpdate(ctx context.Context, db *pgxpool.Pool, request *BulkRequest, audit auditFunc) (*BulkReport, error) {
+	if validationErrors := request.validate(); len(validationErrors) > 0 {
+		return nil, validationError(validationErrors)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	devices, err := lockBulkDevices(ctx, tx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BulkReport{
+		Action:  request.Action,
+		Matched: len(devices),
+		Results: []BulkResult{},
+	}
+
+	found := make(map[int]*Device, len(devices))
+	for i := range devices {
+		found[devices[i].ID] = &devices[i]
+	}
+
+	ids := request.IDs
+	if request.Filter != nil {
+		ids = make([]int, 0, len(devices))
+		for i := range devices {
+			ids = append(ids, devices[i].ID)
+		}
+	}
+
+	changed := []int{}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		device, ok := found[id]
+		if !ok {
+			report.Results = append(report.Results, BulkResult{ID: id, Status: BulkResultNotFound})
+			continue
+		}
+
+		result := BulkResult{
+			ID:               id,
+			Status:           BulkResultUnchanged,
+			PreviousEmployee: device.Employee,
+			Device:           device,
+		}
+		if request.changes(device) {
+			result.Status = request.resultStatus()
+			result.before = device
+			changed = append(changed, id)
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	updated, err := applyBulkAction(ctx, tx, request, changed)
+	if err != nil {
+		if request.Action == BulkReassign {
+			return nil, employee.AssignmentError(err)
+		}
+		return nil, err
+	}
+
+	for i := range report.Results {
+		result := &report.Results[i]
+		if result.before == nil {
+			continue
+		}
+
+		report.Changed++
+		if request.Action == BulkDelete {
+			result.Device = nil
+		} else {
+			result.Device = updated[result.ID]
+		}
+
+		if err := audit.record(tx, result.before, result.Device); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func lockBulkDevices(ctx context.Context, tx pgx.Tx, request *BulkRequest) ([]Device, error) {
+	query := `
+		SELECT ` + DeviceColumns + `
+		FROM device
+	`
+
+	var args []interface{}
+	if request.Filter != nil {
+		var conditions string
+		conditions, args = filterConditions(request.Filter, []interface{}{})
+		query += " WHERE 1=1" + conditions
+		args = append(args, MaxBulkDevices+1)
+		query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+	} else {
+		args = append(args, request.IDs)
+		query += " WHERE id = ANY($1) ORDER BY id"
+	}
+	query += " FOR UPDATE"
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := pgx.CollectRows(rows, pgx.RowToStructByName[Device])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(devices) > MaxBulkDevices {
+		return nil, validationError([]error{
+			fmt.Errorf("filter matches more than %d devices", MaxBulkDevices),
+		})
+	}
+
+	return devices, nil
+}
+
+// applyBulkAction changes the given devices with a single statement and
+// returns the updated devices by ID.
+func applyBulkAction(ctx context.Context, tx pgx.Tx, request *BulkRequest, ids []int) (map[int]*Device, error) {
+	updated := map[int]*Device{}
+	if len(ids) == 0 {
+		return updated, nil
+	}
+
+	if request.Action == BulkDelete {
+		_, err := tx.Exec(ctx, "DELETE FROM device WHERE id = ANY($1)", ids)
+		return updated, err
+	}
+
+	var employee *string
+	if request.Action == BulkReassign {
+		employee = request.Employee
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE device SET employee = $1, updated_at = NOW()
+		WHERE id = ANY($2)
+		RETURNING `+DeviceColumns, employee, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := pgx.CollectRows(rows, pgx.RowToStructByName[Device])
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range devices {
+		updated[devices[i].ID] = &devices[i]
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,69 @@
+package device
+
+import (
+	"dmt/pkg/apikey"
+	"dmt/pkg/audit"
+	"dmt/pkg/employee"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// bulkScopes maps the bulk actions to the scope of their single device
+// counterpart.
+var bulkScopes = map[string]string{
+	BulkUnassign: apikey.ScopeAssignmentsWrite,
+	BulkReassign: apikey.ScopeAssignmentsWrite,
+	BulkDelete:   apikey.ScopeDevicesDelete,
+}
+
+var bulkAuditActions = map[string]string{
+	BulkUnassign: audit.ActionUnassign,
+	BulkReassign: audit.ActionAssign,
+	BulkDelete:   audit.ActionDelete,
+}
+
+// BulkUpdate unassigns, reassigns or deletes the devices selected by ID or
+// filter in a single transaction and reports the outcome per device.
+func (s *DeviceHandler) BulkUpdate(c *fiber.Ctx) error {
+	var request BulkRequest
+	if err := c.BodyParser(&request); err != nil {
+		log.Errorf("Invalid JSON format: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	// The scope depends on the action, so it can't be checked by the route.
+	if scope, ok := bulkScopes[request.Action]; ok {
+		scopes, _ := c.Locals(apikey.ScopesLocal).([]string)
+		if !apikey.HasScope(scopes, scope) {
+			log.Warnf("Forbidden request from '%s' to %s %s - missing scope %s", c.IP(), c.Method(), c.Path(), scope)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":         "Missing required scope",
+				"missing_scope": scope,
+			})
+		}
+	}
+
+	report, err := bulkUpdate(c.Context(), s.db, &request, audited(c, bulkAuditActions[request.Action]))
+	if err != nil {
+		log.Errorf("Failed to apply bulk %s: %s", request.Action, err.Error())
+		if errors.Is(err, ErrValidation) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if employee.IsAssignmentError(err) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply bulk operation",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(report)
+}
@@ -2,6 +2,7 @@ package device
 
 import (
 	"context"
+	"dmt/pkg/employee"
 	"errors"
 	"fmt"
 	"strings"
@@ -11,27 +12,77 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const uniqueViolationCode = "23505"
+
+// DeviceColumns selects a device including its tags, sorted by name. Other
+// packages use it to read the devices their statements change.
+const DeviceColumns = `device.id, device.created_at, device.updated_at, device.name, device.type, device.ip, device.mac,
+	device.description, device.employee, device.attributes,
+	ARRAY(
+		SELECT tag.name FROM device_tag JOIN tag ON tag.id = device_tag.tag_id
+		WHERE device_tag.device_id = device.id ORDER BY tag.name
+	) AS tags`
+
+// auditFunc writes the audit entry of a change to a device as part of the
+// transaction applying it. before is nil on creation, after on deletion.
+type auditFunc func(tx pgx.Tx, before, after *Device) error
+
+func (f auditFunc) record(tx pgx.Tx, before, after *Device) error {
+	if f == nil {
+		return nil
+	}
+	return f(tx, before, after)
+}
+
 func InsertDevice(ctx context.Context, db *pgxpool.Pool, device *Device) error {
+	return createDevice(ctx, db, device, nil)
+}
+
+// createDevice is InsertDevice, auditing the created device.
+func createDevice(ctx context.Context, db *pgxpool.Pool, device *Device, audit auditFunc) error {
 	sanitizeDevice(device)
 
-	if validationErrors := validateDevice(device); len(validationErrors) > 0 {
-		message := ""
-		for _, err := range validationErrors {
-			message += err.Error() + "; "
-		}
+	types, err := catalog.get(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if validationErrors := validateDevice(device, types); len(validationErrors) > 0 {
+		return validationError(validationErrors)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-		return fmt.Errorf("validation failed: %s", message)
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
 
+	if err := insertDevice(ctx, tx, device); err != nil {
+		return err
+	}
+
+	if err := audit.record(tx, nil, device); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// querier is implemented by the pool as well as by transactions.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertDevice(ctx context.Context, db querier, device *Device) error {
 	query := `
-	INSERT INTO device (name, type, ip, mac, description, employee)
-		VALUES ($1, $2, $3, $4, $5, $6)
+	INSERT INTO device (name, type, ip, mac, description, employee, attributes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at, updated_at
 	`
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
 	err := db.QueryRow(ctx, query,
 		device.Name,
 		device.Type,
@@ -39,41 +90,106 @@ func InsertDevice(ctx context.Context, db *pgxpool.Pool, device *Device) error {
 		device.MAC,
 		device.Description,
 		device.Employee,
+		device.Attributes,
 	).Scan(&device.ID, &device.CreatedAt, &device.UpdatedAt)
 	if err != nil {
-		return err
+		return typeError(employee.AssignmentError(err))
 	}
 
 	return nil
 }
 
-// Only updates employee field for now
-func UpdateDevice(ctx context.Context, db *pgxpool.Pool, device *Device) error {
+// UpdateDevice applies the supplied fields of update to the device identified by
+// device.ID and scans the resulting row back into device. The update is
+// rolled back if the resulting device lacks a field its type requires or its
+// attributes don't match the schema of its type.
+func UpdateDevice(ctx context.Context, db *pgxpool.Pool, device *Device, update *DeviceUpdate) error {
+	return updateDevice(ctx, db, device, update, nil)
+}
+
+// updateDevice is UpdateDevice, auditing the change. The before state is read
+// from the locked row, so no concurrent change slips in between.
+func updateDevice(ctx context.Context, db *pgxpool.Pool, device *Device, update *DeviceUpdate, audit auditFunc) error {
 	if device.ID < 1 {
 		return errors.New("device ID is required")
 	}
 
+	sanitizeDeviceUpdate(update)
+
+	types, err := catalog.get(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if validationErrors := validateDeviceUpdate(update, types); len(validationErrors) > 0 {
+		return validationError(validationErrors)
+	}
+
 	args := []interface{}{}
 	sqlChunk := []string{}
 
-	if device.Employee != nil {
-		employee := strings.TrimSpace(*device.Employee)
-		if employee != "" {
-			if err := validateEmployee(&employee); err != nil {
-				return err
-			}
+	if update.Name != nil {
+		args = append(args, *update.Name)
+		sqlChunk = append(sqlChunk, fmt.Sprintf(" name = $%d", len(args)))
+	}
+
+	if update.Type != nil {
+		args = append(args, *update.Type)
+		sqlChunk = append(sqlChunk, fmt.Sprintf(" type = $%d", len(args)))
+	}
+
+	if update.IP != nil {
+		if len(*update.IP) > 0 {
+			args = append(args, *update.IP)
+			sqlChunk = append(sqlChunk, fmt.Sprintf(" ip = $%d", len(args)))
+		} else {
+			sqlChunk = append(sqlChunk, " ip = NULL")
+		}
+	}
+
+	if update.MAC != nil {
+		args = append(args, *update.MAC)
+		sqlChunk = append(sqlChunk, fmt.Sprintf(" mac = $%d", len(args)))
+	}
+
+	if update.Description != nil {
+		if *update.Description != "" {
+			args = append(args, *update.Description)
+			sqlChunk = append(sqlChunk, fmt.Sprintf(" description = $%d", len(args)))
+		} else {
+			sqlChunk = append(sqlChunk, " description = NULL")
+		}
+	}
 
-			args = append(args, employee)
+	if update.Employee != nil {
+		if *update.Employee != "" {
+			args = append(args, *update.Employee)
 			sqlChunk = append(sqlChunk, fmt.Sprintf(" employee = $%d", len(args)))
 		} else {
 			sqlChunk = append(sqlChunk, " employee = NULL")
 		}
 	}
 
+	if update.Attributes != nil {
+		attributes := *update.Attributes
+		if attributes == nil {
+			attributes = map[string]any{}
+		}
+		args = append(args, attributes)
+		sqlChunk = append(sqlChunk, fmt.Sprintf(" attributes = $%d", len(args)))
+	}
+
+	if update.AttributesPatch != nil {
+		args = append(args, update.AttributesPatch)
+		sqlChunk = append(sqlChunk, fmt.Sprintf(" attributes = jsonb_merge_patch(attributes, $%d)", len(args)))
+	}
+
 	if len(sqlChunk) == 0 {
-		return errors.New("no update options provided")
+		return validationError([]error{errors.New("no update options provided")})
 	}
 
+	sqlChunk = append(sqlChunk, " updated_at = NOW()")
+
 	strBuilder := strings.Builder{}
 	strBuilder.WriteString("UPDATE device SET")
 
@@ -86,51 +202,119 @@ func UpdateDevice(ctx context.Context, db *pgxpool.Pool, device *Device) error {
 	}
 
 	args = append(args, device.ID)
-	fmt.Fprintf(&strBuilder, " WHERE id = $%d RETURNING id, created_at, updated_at, name, type, ip, mac, description, employee", len(args))
+	fmt.Fprintf(&strBuilder, " WHERE id = $%d RETURNING %s", len(args), DeviceColumns)
 
 	query := strBuilder.String()
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	err := db.QueryRow(ctx, query, args...).Scan(
-		&device.ID,
-		&device.CreatedAt,
-		&device.UpdatedAt,
-		&device.Name,
-		&device.Type,
-		&device.IP,
-		&device.MAC,
-		&device.Description,
-		&device.Employee,
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockDevice(ctx, tx, device.ID)
+	if err != nil {
+		return err
+	}
+
+	updated := &Device{}
+	err = tx.QueryRow(ctx, query, args...).Scan(
+		&updated.ID,
+		&updated.CreatedAt,
+		&updated.UpdatedAt,
+		&updated.Name,
+		&updated.Type,
+		&updated.IP,
+		&updated.MAC,
+		&updated.Description,
+		&updated.Employee,
+		&updated.Attributes,
+		&updated.Tags,
 	)
 	if err != nil {
+		return typeError(employee.AssignmentError(err))
+	}
+
+	errs := missingRequiredFields(updated, types[updated.Type])
+	errs = append(errs, attributeErrors(updated, types[updated.Type])...)
+	if len(errs) > 0 {
+		return validationError(errs)
+	}
+
+	if err := audit.record(tx, before, updated); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
 		return err
 	}
+	*device = *updated
 
 	return nil
 }
 
+// lockDevice reads the device and locks its row for the rest of the
+// transaction. It returns pgx.ErrNoRows if the device does not exist.
+func lockDevice(ctx context.Context, tx pgx.Tx, id int) (*Device, error) {
+	rows, err := tx.Query(ctx, `SELECT `+DeviceColumns+` FROM device WHERE id = $1 FOR UPDATE`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Device])
+	if err != nil {
+		return nil, err
+	}
+
+	return &locked, nil
+}
+
 func DeleteDevice(ctx context.Context, db *pgxpool.Pool, device *Device) error {
+	return deleteDevice(ctx, db, device, nil)
+}
+
+// deleteDevice is DeleteDevice, auditing the deleted device. Nothing is
+// audited if the device did not exist.
+func deleteDevice(ctx context.Context, db *pgxpool.Pool, device *Device, audit auditFunc) error {
 	query := `
 		DELETE FROM device 
 		WHERE id = $1 
-	`
+		RETURNING ` + DeviceColumns
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := db.Exec(ctx, query, device.ID)
+	tx, err := db.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
-	return nil
+	rows, err := tx.Query(ctx, query, device.ID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := pgx.CollectRows(rows, pgx.RowToStructByName[Device])
+	if err != nil {
+		return err
+	}
+
+	if len(deleted) > 0 {
+		if err := audit.record(tx, &deleted[0], nil); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
 func GetDeviceByID(ctx context.Context, db *pgxpool.Pool, device *Device) error {
 	query := `
-		SELECT id, created_at, updated_at, name, type, ip, mac, description, employee
+		SELECT ` + DeviceColumns + `
 		FROM device 
 		WHERE id = $1 
 		LIMIT 1
@@ -149,6 +333,8 @@ func GetDeviceByID(ctx context.Context, db *pgxpool.Pool, device *Device) error
 		&device.MAC,
 		&device.Description,
 		&device.Employee,
+		&device.Attributes,
+		&device.Tags,
 	)
 	if err != nil {
 		return err
@@ -157,55 +343,134 @@ func GetDeviceByID(ctx context.Context, db *pgxpool.Pool, device *Device) error
 	return nil
 }
 
-func GetDevices(ctx context.Context, db *pgxpool.Pool, employee, deviceType, ip, mac string) ([]Device, error) {
+func GetDevices(ctx context.Context, db *pgxpool.Pool, filter *DeviceFilter, page *PageOptions) (*DevicePage, error) {
+	if err := page.normalize(); err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, created_at, updated_at, name, type, ip, mac, description, employee 
+		SELECT ` + DeviceColumns + `
 		FROM device 
 		WHERE 1=1
 	`
 
-	args := []interface{}{}
-	argIndex := 1
+	conditions, args := filterConditions(filter, []interface{}{})
+	query += conditions
 
-	if employee != "" {
-		query += fmt.Sprintf(" AND employee = $%d", argIndex)
-		args = append(args, employee)
-		argIndex++
-	}
+	keyset, orderBy, args := page.keysetQuery(args)
+	query += keyset + orderBy
+
+	args = append(args, page.Limit+1)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-	if deviceType != "" {
-		query += fmt.Sprintf(" AND type = $%d", argIndex)
-		args = append(args, deviceType)
-		argIndex++
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	if ip != "" {
-		query += fmt.Sprintf(" AND cast(ip as text) LIKE $%d", argIndex)
-		args = append(args, "%"+ip+"%")
-		argIndex++
+	devices, err := pgx.CollectRows(rows, pgx.RowToStructByName[Device])
+	if err != nil {
+		return nil, err
 	}
 
-	if mac != "" {
-		query += fmt.Sprintf(" AND cast(mac as text) ILIKE $%d", argIndex)
-		args = append(args, "%"+mac+"%")
-		argIndex++
+	total, err := CountDevices(ctx, db, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	query += " ORDER BY created_at DESC"
+	devices, next, prev := page.paginate(devices)
+
+	return &DevicePage{
+		Devices: devices,
+		Total:   total,
+		Next:    next,
+		Prev:    prev,
+	}, nil
+}
+
+func CountDevices(ctx context.Context, db *pgxpool.Pool, filter *DeviceFilter) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM device 
+		WHERE 1=1
+	`
+
+	conditions, args := filterConditions(filter, []interface{}{})
+	query += conditions
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	rows, err := db.Query(ctx, query, args...)
+	var total int
+	err := db.QueryRow(ctx, query, args...).Scan(&total)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer rows.Close()
-	devices, err := pgx.CollectRows(rows, pgx.RowToStructByName[Device])
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	return total, nil
+}
+
+func filterConditions(filter *DeviceFilter, args []interface{}) (string, []interface{}) {
+	query := ""
+
+	if filter.Employee != "" {
+		args = append(args, filter.Employee)
+		query += fmt.Sprintf(" AND employee = $%d", len(args))
+	}
+
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		query += fmt.Sprintf(" AND type = $%d", len(args))
+	}
+
+	if filter.IP != "" {
+		args = append(args, "%"+filter.IP+"%")
+		query += fmt.Sprintf(" AND cast(ip as text) LIKE $%d", len(args))
+	}
+
+	if filter.MAC != "" {
+		args = append(args, "%"+filter.MAC+"%")
+		query += fmt.Sprintf(" AND cast(mac as text) ILIKE $%d", len(args))
+	}
+
+	for _, attribute := range filter.Attributes {
+		args = append(args, attribute.jsonPath())
+		query += fmt.Sprintf(" AND attributes @? $%d::jsonpath", len(args))
 	}
 
-	return devices, nil
+	if tags := sanitizeTags(filter.Tags); len(tags) > 0 {
+		args = append(args, tags, len(tags))
+		query += fmt.Sprintf(" AND (%s) = $%d", taggedCount(len(args)-1), len(args))
+	}
+
+	if tags := sanitizeTags(filter.TagsAny); len(tags) > 0 {
+		args = append(args, tags)
+		query += fmt.Sprintf(" AND (%s) > 0", taggedCount(len(args)))
+	}
+
+	if tags := sanitizeTags(filter.TagsNone); len(tags) > 0 {
+		args = append(args, tags)
+		query += fmt.Sprintf(" AND (%s) = 0", taggedCount(len(args)))
+	}
+
+	return query, args
+}
+
+// taggedCount counts how many of the tags in the given parameter the device
+// carries.
+func taggedCount(param int) string {
+	return fmt.Sprintf(`
+		SELECT COUNT(*) FROM device_tag JOIN tag ON tag.id = device_tag.tag_id
+		WHERE device_tag.device_id = device.id AND tag.name = ANY($%d::text[])`, param)
+}
+
+// isEmpty reports whether the filter matches every device. Tag filters only
+// count with tags left after sanitizing, as filterConditions ignores the rest.
+func (f *DeviceFilter) isEmpty() bool {
+	return f.Employee == "" && f.Type == "" && f.IP == "" && f.MAC == "" && len(f.Attributes) == 0 &&
+		len(sanitizeTags(f.Tags)) == 0 && len(sanitizeTags(f.TagsAny)) == 0 && len(sanitizeTags(f.TagsNone)) == 0
 }
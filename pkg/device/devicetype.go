@@ -0,0 +1,456 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"dmt/pkg/jsonschema"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DeviceType is an entry of the device type catalog. RequiredFields names the
+// optional device fields devices of this type must have, AttributeSchema is
+// the JSON Schema their attributes must match.
+type DeviceType struct {
+	Name              string          `json:"name" db:"name"`
+	CreatedAt         time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at" db:"updated_at"`
+	DisplayName       string          `json:"display_name" db:"display_name"`
+	Icon              *string         `json:"icon" db:"icon"`
+	RequiredFields    []string        `json:"required_fields" db:"required_fields"`
+	CountsTowardLimit bool            `json:"counts_toward_limit" db:"counts_toward_limit"`
+	AttributeSchema   json.RawMessage `json:"attribute_schema" db:"attribute_schema"`
+
+	// schema is the compiled AttributeSchema, set for types of the catalog.
+	schema *jsonschema.Schema
+}
+
+// DeviceTypeUpdate holds the fields of a partial device type update. Nil
+// fields are left untouched, an empty icon or attribute schema is cleared.
+type DeviceTypeUpdate struct {
+	DisplayName       *string
+	Icon              *string
+	RequiredFields    *[]string
+	CountsTowardLimit *bool
+	AttributeSchema   *json.RawMessage
+}
+
+// The device fields a type can require.
+const (
+	RequiredIP          = "ip"
+	RequiredDescription = "description"
+)
+
+var requiredFieldOptions = []string{RequiredIP, RequiredDescription}
+
+var typeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ErrTypeInUse is returned when deleting a type devices or policies refer to.
+var ErrTypeInUse = errors.New("device type is still in use")
+
+const foreignKeyViolationCode = "23503"
+
+// Constraints rejecting unknown types, which validation misses if the catalog
+// of this instance is outdated.
+var typeConstraints = []string{"device_type_fkey", "notification_policy_device_type_fkey"}
+
+const deviceTypeColumns = "name, created_at, updated_at, display_name, icon, required_fields, counts_toward_limit, attribute_schema"
+
+// DefaultCatalogTTL bounds how long other instances validate against an
+// outdated catalog after a type was changed.
+const DefaultCatalogTTL = 30 * time.Second
+
+// typeCatalog caches the device types validation reads. Changes made through
+// this instance invalidate it right away.
+type typeCatalog struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	types   map[string]DeviceType
+	expires time.Time
+}
+
+var catalog = &typeCatalog{ttl: DefaultCatalogTTL}
+
+func (c *typeCatalog) get(ctx context.Context, db *pgxpool.Pool) (map[string]DeviceType, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.types != nil && time.Now().Before(c.expires) {
+		return c.types, nil
+	}
+
+	deviceTypes, err := GetDeviceTypes(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load device types: %w", err)
+	}
+
+	types := make(map[string]DeviceType, len(deviceTypes))
+	for _, deviceType := range deviceTypes {
+		deviceType.schema, err = compileAttributeSchema(deviceType.AttributeSchema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile attribute schema of device type %s: %w", deviceType.Name, err)
+		}
+		types[deviceType.Name] = deviceType
+	}
+	c.types = types
+	c.expires = time.Now().Add(c.ttl)
+
+	return c.types, nil
+}
+
+func (c *typeCatalog) invalidate() {
+	c.mu.Lock()
+	c.types = nil
+	c.mu.Unlock()
+}
+
+func sanitizeDeviceType(deviceType *DeviceType) {
+	deviceType.Name = strings.TrimSpace(deviceType.Name)
+	deviceType.DisplayName = strings.TrimSpace(deviceType.DisplayName)
+	if deviceType.Icon != nil {
+		*deviceType.Icon = strings.TrimSpace(*deviceType.Icon)
+		if *deviceType.Icon == "" {
+			deviceType.Icon = nil
+		}
+	}
+	if deviceType.RequiredFields == nil {
+		deviceType.RequiredFields = []string{}
+	}
+	deviceType.AttributeSchema = sanitizeAttributeSchema(deviceType.AttributeSchema)
+}
+
+// sanitizeAttributeSchema turns an empty or null schema into no schema.
+func sanitizeAttributeSchema(schema json.RawMessage) json.RawMessage {
+	trimmed := bytes.TrimSpace(schema)
+	if len(trimmed) == 0 || string(trimmed) == "null" {
+		return nil
+	}
+	return trimmed
+}
+
+func validateAttributeSchema(schema json.RawMessage) error {
+	if _, err := compileAttributeSchema(schema); err != nil {
+		return fmt.Errorf("attribute_schema: %w", err)
+	}
+	return nil
+}
+
+func validateDisplayName(displayName string) error {
+	if displayName == "" {
+		return errors.New("display_name is required")
+	}
+	if len(displayName) > 255 {
+		return errors.New("display_name must be less than 255 characters")
+	}
+	return nil
+}
+
+func validateIcon(icon *string) error {
+	if icon != nil && len(*icon) > 255 {
+		return errors.New("icon must be less than 255 characters")
+	}
+	return nil
+}
+
+func validateRequiredFields(fields []string) error {
+	for i, field := range fields {
+		if !slices.Contains(requiredFieldOptions, field) {
+			return fmt.Errorf("required_fields may only contain %s", strings.Join(requiredFieldOptions, ", "))
+		}
+		if slices.Contains(fields[:i], field) {
+			return fmt.Errorf("required field %q is listed twice", field)
+		}
+	}
+	return nil
+}
+
+func validateDeviceType(deviceType *DeviceType) []error {
+	errs := []error{}
+
+	if !typeNamePattern.MatchString(deviceType.Name) {
+		errs = append(errs, errors.New("name must be 1 to 32 lowercase letters, digits, - or _"))
+	}
+	if err := validateDisplayName(deviceType.DisplayName); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateIcon(deviceType.Icon); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateRequiredFields(deviceType.RequiredFields); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateAttributeSchema(deviceType.AttributeSchema); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// missingRequiredFields returns an error for every field the type of the
+// device requires but the device lacks.
+func missingRequiredFields(device *Device, deviceType DeviceType) []error {
+	errs := []error{}
+
+	for _, field := range deviceType.RequiredFields {
+		missing := false
+		switch field {
+		case RequiredIP:
+			missing = len(device.IP) == 0
+		case RequiredDescription:
+			missing = device.Description == nil || *device.Description == ""
+		}
+
+		if missing {
+			errs = append(errs, fmt.Errorf("%s is required for %s devices", field, deviceType.Name))
+		}
+	}
+
+	return errs
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode
+}
+
+// typeError translates the rejection of an unknown device type by the
+// database into a validation error. Other errors are returned unchanged.
+func typeError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && slices.Contains(typeConstraints, pgErr.ConstraintName) {
+		return validationError([]error{errors.New("invalid device type")})
+	}
+	return err
+}
+
+func InsertDeviceType(ctx context.Context, db *pgxpool.Pool, deviceType *DeviceType) error {
+	sanitizeDeviceType(deviceType)
+
+	if validationErrors := validateDeviceType(deviceType); len(validationErrors) > 0 {
+		return validationError(validationErrors)
+	}
+
+	query := `
+	INSERT INTO device_type (name, display_name, icon, required_fields, counts_toward_limit, attribute_schema)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + deviceTypeColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query,
+		deviceType.Name,
+		deviceType.DisplayName,
+		deviceType.Icon,
+		deviceType.RequiredFields,
+		deviceType.CountsTowardLimit,
+		deviceType.AttributeSchema,
+	)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[DeviceType])
+	if err != nil {
+		return err
+	}
+	*deviceType = inserted
+
+	catalog.invalidate()
+	return nil
+}
+
+// UpdateDeviceType applies the supplied fields of update to the type named by
+// deviceType.Name. The name is immutable, as devices refer to it. If the type
+// stops or starts counting toward the limit, the counts of all employees
+// holding such devices are reported again.
+func UpdateDeviceType(ctx context.Context, db *pgxpool.Pool, deviceType *DeviceType, update *DeviceTypeUpdate) error {
+	args := []interface{}{}
+	sqlChunk := []string{}
+	errs := []error{}
+
+	if update.DisplayName != nil {
+		displayName := strings.TrimSpace(*update.DisplayName)
+		if err := validateDisplayName(displayName); err != nil {
+			errs = append(errs, err)
+		}
+		args = append(args, displayName)
+		sqlChunk = append(sqlChunk, fmt.Sprintf("display_name = $%d", len(args)))
+	}
+
+	if update.Icon != nil {
+		icon := strings.TrimSpace(*update.Icon)
+		if err := validateIcon(&icon); err != nil {
+			errs = append(errs, err)
+		}
+		if icon != "" {
+			args = append(args, icon)
+			sqlChunk = append(sqlChunk, fmt.Sprintf("icon = $%d", len(args)))
+		} else {
+			sqlChunk = append(sqlChunk, "icon = NULL")
+		}
+	}
+
+	if update.RequiredFields != nil {
+		fields := *update.RequiredFields
+		if fields == nil {
+			fields = []string{}
+		}
+		if err := validateRequiredFields(fields); err != nil {
+			errs = append(errs, err)
+		}
+		args = append(args, fields)
+		sqlChunk = append(sqlChunk, fmt.Sprintf("required_fields = $%d", len(args)))
+	}
+
+	if update.CountsTowardLimit != nil {
+		args = append(args, *update.CountsTowardLimit)
+		sqlChunk = append(sqlChunk, fmt.Sprintf("counts_toward_limit = $%d", len(args)))
+	}
+
+	if update.AttributeSchema != nil {
+		schema := sanitizeAttributeSchema(*update.AttributeSchema)
+		if err := validateAttributeSchema(schema); err != nil {
+			errs = append(errs, err)
+		}
+		if schema != nil {
+			args = append(args, schema)
+			sqlChunk = append(sqlChunk, fmt.Sprintf("attribute_schema = $%d", len(args)))
+		} else {
+			sqlChunk = append(sqlChunk, "attribute_schema = NULL")
+		}
+	}
+
+	if len(errs) > 0 {
+		return validationError(errs)
+	}
+
+	if len(sqlChunk) == 0 {
+		return validationError([]error{errors.New("no update options provided")})
+	}
+
+	sqlChunk = append(sqlChunk, "updated_at = NOW()")
+
+	args = append(args, deviceType.Name)
+	query := fmt.Sprintf(`
+		UPDATE device_type SET %s
+		WHERE name = $%d
+		RETURNING %s, (SELECT counts_toward_limit FROM device_type WHERE name = $%d)`,
+		strings.Join(sqlChunk, ", "), len(args), deviceTypeColumns, len(args))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// The subquery of RETURNING reads the row as it was before the update.
+	var updated DeviceType
+	var countedBefore bool
+	err = tx.QueryRow(ctx, query, args...).Scan(
+		&updated.Name,
+		&updated.CreatedAt,
+		&updated.UpdatedAt,
+		&updated.DisplayName,
+		&updated.Icon,
+		&updated.RequiredFields,
+		&updated.CountsTowardLimit,
+		&updated.AttributeSchema,
+		&countedBefore,
+	)
+	if err != nil {
+		return err
+	}
+
+	if countedBefore != updated.CountsTowardLimit {
+		_, err := tx.Exec(ctx, `
+			SELECT enqueue_device_count(employee)
+			FROM (SELECT DISTINCT employee FROM device WHERE type = $1 AND employee IS NOT NULL ORDER BY employee) AS affected
+		`, updated.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	*deviceType = updated
+
+	catalog.invalidate()
+	return nil
+}
+
+// DeleteDeviceType removes a type no device or policy refers to. It returns
+// pgx.ErrNoRows if the type does not exist and ErrTypeInUse if it is in use.
+func DeleteDeviceType(ctx context.Context, db *pgxpool.Pool, deviceType *DeviceType) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := db.Exec(ctx, `DELETE FROM device_type WHERE name = $1`, deviceType.Name)
+	if isForeignKeyViolation(err) {
+		return ErrTypeInUse
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	catalog.invalidate()
+	return nil
+}
+
+func GetDeviceTypeByName(ctx context.Context, db *pgxpool.Pool, deviceType *DeviceType) error {
+	query := `SELECT ` + deviceTypeColumns + ` FROM device_type WHERE name = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, deviceType.Name)
+	if err != nil {
+		return err
+	}
+
+	found, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[DeviceType])
+	if err != nil {
+		return err
+	}
+	*deviceType = found
+
+	return nil
+}
+
+func GetDeviceTypes(ctx context.Context, db *pgxpool.Pool) ([]DeviceType, error) {
+	query := `SELECT ` + deviceTypeColumns + ` FROM device_type ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceTypes, err := pgx.CollectRows(rows, pgx.RowToStructByName[DeviceType])
+	if err != nil {
+		return nil, err
+	}
+
+	return deviceTypes, nil
+}
@@ -0,0 +1,220 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DeviceTypeHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewDeviceTypeHandler(db *pgxpool.Pool) *DeviceTypeHandler {
+	return &DeviceTypeHandler{db: db}
+}
+
+func (s *DeviceTypeHandler) GetDeviceTypes(c *fiber.Ctx) error {
+	deviceTypes, err := GetDeviceTypes(c.Context(), s.db)
+	if err != nil {
+		log.Errorf("Failed to retrieve device types: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve device types",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"device_types": deviceTypes,
+		"count":        len(deviceTypes),
+	})
+}
+
+func (s *DeviceTypeHandler) GetDeviceType(c *fiber.Ctx) error {
+	deviceType := &DeviceType{Name: c.Params("name")}
+	err := GetDeviceTypeByName(c.Context(), s.db, deviceType)
+	if err != nil {
+		log.Errorf("Failed to retrieve device type: %s", err.Error())
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Device type not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve device type",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(deviceType)
+}
+
+// CreateDeviceType adds a type to the catalog. A type counts toward the
+// device limit unless counts_toward_limit is false.
+func (s *DeviceTypeHandler) CreateDeviceType(c *fiber.Ctx) error {
+	var requestBody struct {
+		Name              string          `json:"name"`
+		DisplayName       string          `json:"display_name"`
+		Icon              *string         `json:"icon"`
+		RequiredFields    []string        `json:"required_fields"`
+		CountsTowardLimit *bool           `json:"counts_toward_limit"`
+		AttributeSchema   json.RawMessage `json:"attribute_schema"`
+	}
+	err := c.BodyParser(&requestBody)
+	if err != nil {
+		log.Errorf("Failed to parse device type: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	deviceType := &DeviceType{
+		Name:              requestBody.Name,
+		DisplayName:       requestBody.DisplayName,
+		Icon:              requestBody.Icon,
+		RequiredFields:    requestBody.RequiredFields,
+		CountsTowardLimit: requestBody.CountsTowardLimit == nil || *requestBody.CountsTowardLimit,
+		AttributeSchema:   requestBody.AttributeSchema,
+	}
+
+	err = InsertDeviceType(c.Context(), s.db, deviceType)
+	if err != nil {
+		log.Errorf("Failed to create device type: %s", err.Error())
+
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, ErrValidation):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode:
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Device type already exists",
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create device type",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":     "Device type created successfully",
+		"device_type": deviceType,
+	})
+}
+
+// UpdateDeviceType applies a JSON Merge Patch (RFC 7396) to a device type.
+// The name cannot be changed and the attribute schema is replaced as a whole.
+func (s *DeviceTypeHandler) UpdateDeviceType(c *fiber.Ctx) error {
+	update, err := parseDeviceTypePatch(c.Body())
+	if err != nil {
+		log.Errorf("Invalid merge patch: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	deviceType := &DeviceType{Name: c.Params("name")}
+	err = UpdateDeviceType(c.Context(), s.db, deviceType, update)
+	if err != nil {
+		log.Errorf("Failed to update device type: %s", err.Error())
+		switch {
+		case errors.Is(err, ErrValidation):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, pgx.ErrNoRows):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Device type not found",
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update device type",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":     "Device type updated successfully",
+		"device_type": deviceType,
+	})
+}
+
+func (s *DeviceTypeHandler) DeleteDeviceType(c *fiber.Ctx) error {
+	err := DeleteDeviceType(c.Context(), s.db, &DeviceType{Name: c.Params("name")})
+	if err != nil {
+		log.Errorf("Failed to delete device type: %s", err.Error())
+		switch {
+		case errors.Is(err, ErrTypeInUse):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, pgx.ErrNoRows):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Device type not found",
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete device type",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Device type deleted successfully",
+	})
+}
+
+func parseDeviceTypePatch(body []byte) (*DeviceTypeUpdate, error) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	update := &DeviceTypeUpdate{}
+	for field, value := range patch {
+		isNull := string(value) == "null"
+
+		var target any
+		switch field {
+		case "display_name":
+			if isNull {
+				return nil, errors.New("display_name cannot be cleared")
+			}
+			update.DisplayName = new(string)
+			target = update.DisplayName
+		case "icon":
+			update.Icon = new(string)
+			target = update.Icon
+		case "required_fields":
+			update.RequiredFields = &[]string{}
+			target = update.RequiredFields
+		case "counts_toward_limit":
+			if isNull {
+				return nil, errors.New("counts_toward_limit cannot be cleared")
+			}
+			update.CountsTowardLimit = new(bool)
+			target = update.CountsTowardLimit
+		case "attribute_schema":
+			update.AttributeSchema = &json.RawMessage{}
+			target = update.AttributeSchema
+		default:
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+
+		if isNull {
+			continue
+		}
+
+		if err := json.Unmarshal(value, target); err != nil {
+			return nil, fmt.Errorf("invalid value for field %q", field)
+		}
+	}
+
+	return update, nil
+}
@@ -0,0 +1,263 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportXLSX   = "xlsx"
+)
+
+// exportBatchSize is the number of rows fetched from the cursor at once.
+const exportBatchSize = 500
+
+// ExportColumns are the columns of an export in their default order.
+var ExportColumns = []string{"id", "created_at", "updated_at", "name", "type", "ip", "mac", "description", "employee", "attributes", "tags"}
+
+var exportContentTypes = map[string]string{
+	ExportCSV:    "text/csv; charset=utf-8",
+	ExportNDJSON: "application/x-ndjson",
+	ExportXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// parseExportColumns reads a comma separated column list. An empty list
+// selects every column.
+func parseExportColumns(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return ExportColumns, nil
+	}
+
+	columns := []string{}
+	for _, column := range strings.Split(value, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !slices.Contains(ExportColumns, column) {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+		if slices.Contains(columns, column) {
+			return nil, fmt.Errorf("duplicate column %q", column)
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// exportValue returns the value of a column in the notation used by all
+// formats: addresses as strings, times in RFC 3339 and nil for empty fields.
+func exportValue(device *Device, column string) any {
+	switch column {
+	case "id":
+		return device.ID
+	case "created_at":
+		return device.CreatedAt.Format(time.RFC3339)
+	case "updated_at":
+		return device.UpdatedAt.Format(time.RFC3339)
+	case "name":
+		return device.Name
+	case "type":
+		return device.Type
+	case "ip":
+		if device.IP == nil {
+			return nil
+		}
+		return device.IP.String()
+	case "mac":
+		if device.MAC == nil {
+			return nil
+		}
+		return device.MAC.String()
+	case "description":
+		if device.Description == nil {
+			return nil
+		}
+		return *device.Description
+	case "employee":
+		if device.Employee == nil {
+			return nil
+		}
+		return *device.Employee
+	case "attributes":
+		attributes, _ := json.Marshal(device.Attributes)
+		return rawJSON(attributes)
+	case "tags":
+		if len(device.Tags) == 0 {
+			return nil
+		}
+		return strings.Join(device.Tags, ",")
+	}
+	return nil
+}
+
+// rawJSON is written as JSON text to csv and xlsx and embedded as is into
+// ndjson.
+type rawJSON []byte
+
+func (r rawJSON) String() string {
+	return string(r)
+}
+
+func (r rawJSON) MarshalJSON() ([]byte, error) {
+	return r, nil
+}
+
+// exportWriter writes the rows of an export in one format.
+type exportWriter interface {
+	WriteRow(values []any) error
+	Close() error
+}
+
+func newExportWriter(format string, w io.Writer, columns []string) (exportWriter, error) {
+	switch format {
+	case ExportCSV:
+		writer := &csvExportWriter{writer: csv.NewWriter(w)}
+		header := make([]any, len(columns))
+		for i, column := range columns {
+			header[i] = column
+		}
+		return writer, writer.WriteRow(header)
+	case ExportNDJSON:
+		return &ndjsonExportWriter{writer: w, columns: columns}, nil
+	case ExportXLSX:
+		writer, err := newXLSXWriter(w, "Devices")
+		if err != nil {
+			return nil, err
+		}
+		header := make([]any, len(columns))
+		for i, column := range columns {
+			header[i] = column
+		}
+		return writer, writer.WriteRow(header)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+// WriteRow writes a record. Text starting like a formula is prefixed with a
+// quote, so spreadsheet applications opening the file show it as text instead
+// of evaluating it.
+func (w *csvExportWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+		case int:
+			record[i] = strconv.Itoa(v)
+		default:
+			record[i] = escapeFormula(fmt.Sprint(v))
+		}
+	}
+	return w.writer.Write(record)
+}
+
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (w *csvExportWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// ndjsonExportWriter writes one JSON object per line, keeping the keys in the
+// order of the selected columns.
+type ndjsonExportWriter struct {
+	writer  io.Writer
+	columns []string
+}
+
+func (w *ndjsonExportWriter) WriteRow(values []any) error {
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		key, _ := json.Marshal(w.columns[i])
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		line.Write(key)
+		line.WriteByte(':')
+		line.Write(encoded)
+	}
+	line.WriteString("}\n")
+
+	_, err := w.writer.Write(line.Bytes())
+	return err
+}
+
+func (w *ndjsonExportWriter) Close() error {
+	return nil
+}
+
+// ExportDevices calls fn for every device matching the filter, ordered by ID.
+// The rows are read through a server-side cursor in batches, so the export
+// never holds more than one batch in memory. An error returned by fn stops the
+// export.
+func ExportDevices(ctx context.Context, db *pgxpool.Pool, filter *DeviceFilter, fn func(*Device) error) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		DECLARE device_export NO SCROLL CURSOR FOR
+		SELECT ` + DeviceColumns + `
+		FROM device
+		WHERE 1=1
+	`
+
+	conditions, args := filterConditions(filter, []interface{}{})
+	query += conditions + " ORDER BY id"
+
+	// DECLARE takes no bind parameters, so the arguments are interpolated by
+	// pgx using the simple protocol.
+	if _, err := tx.Exec(ctx, query, append([]any{pgx.QueryExecModeSimpleProtocol}, args...)...); err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM device_export", exportBatchSize)
+	for {
+		rows, err := tx.Query(ctx, fetch)
+		if err != nil {
+			return err
+		}
+
+		devices, err := pgx.CollectRows(rows, pgx.RowToStructByName[Device])
+		if err != nil {
+			return err
+		}
+
+		for i := range devices {
+			if err := fn(&devices[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(devices) < exportBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package device
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// exportTimeout bounds how long a single export may stream.
+const exportTimeout = 10 * time.Minute
+
+// ExportDevices streams all devices matching the filters of GetDevices as
+// csv (default), ndjson or xlsx. The query parameter columns selects and
+// orders the exported columns.
+func (s *DeviceHandler) ExportDevices(c *fiber.Ctx) error {
+	format := c.Query("format", ExportCSV)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv, ndjson or xlsx",
+		})
+	}
+
+	columns, err := parseExportColumns(c.Query("columns"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid columns: %s", err.Error()),
+		})
+	}
+
+	filter, err := deviceFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	db := s.db
+
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="devices-%s.%s"`, time.Now().Format("2006-01-02"), format))
+
+	// The stream writer runs after the handler returned, so it must not use
+	// the request context.
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+
+		writer, err := newExportWriter(format, w, columns)
+		if err != nil {
+			log.Errorf("Failed to start export: %s", err.Error())
+			return
+		}
+
+		row := make([]any, len(columns))
+		err = ExportDevices(ctx, db, filter, func(device *Device) error {
+			for i, column := range columns {
+				row[i] = exportValue(device, column)
+			}
+			return writer.WriteRow(row)
+		})
+		if err != nil {
+			// The status has already been sent, the client receives a
+			// truncated file.
+			log.Errorf("Failed to export devices: %s", err.Error())
+			return
+		}
+
+		if err := writer.Close(); err != nil {
+			log.Errorf("Failed to finish export: %s", err.Error())
+			return
+		}
+
+		if err := w.Flush(); err != nil {
+			log.Errorf("Failed to flush export: %s", err.Error())
+		}
+	})
+
+	return nil
+}
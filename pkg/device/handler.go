@@ -1,12 +1,20 @@
 package device
 
 import (
+	"dmt/pkg/audit"
+	"dmt/pkg/employee"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -28,7 +36,15 @@ func (s *DeviceHandler) CreateDevice(c *fiber.Ctx) error {
 		})
 	}
 
-	err = InsertDevice(c.Context(), s.db, device)
+	err = createDevice(c.Context(), s.db, device, audited(c, audit.ActionCreate))
+	if errors.Is(err, ErrValidation) {
+		return validationErrorResponse(c, err)
+	}
+	if employee.IsAssignmentError(err) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		log.Errorf("Failed to create device: %s", err.Error())
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -42,6 +58,17 @@ func (s *DeviceHandler) CreateDevice(c *fiber.Ctx) error {
 	})
 }
 
+// audited returns the auditFunc recording a change as action of the request.
+func audited(c *fiber.Ctx, action string) auditFunc {
+	return func(tx pgx.Tx, before, after *Device) error {
+		device := after
+		if device == nil {
+			device = before
+		}
+		return audit.Record(c, tx, action, device.ID, before, after)
+	}
+}
+
 func (s *DeviceHandler) GetDeviceByID(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 	id, err := strconv.Atoi(idParam)
@@ -80,7 +107,7 @@ func (s *DeviceHandler) DeleteDevice(c *fiber.Ctx) error {
 	}
 
 	device := &Device{ID: id}
-	err = DeleteDevice(c.Context(), s.db, device)
+	err = deleteDevice(c.Context(), s.db, device, audited(c, audit.ActionDelete))
 	if err != nil {
 		log.Errorf("Failed to delete device: %s", err.Error())
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -93,6 +120,88 @@ func (s *DeviceHandler) DeleteDevice(c *fiber.Ctx) error {
 	})
 }
 
+// ReplaceDevice replaces all writable fields of a device. Optional fields that
+// are missing from the body are cleared.
+func (s *DeviceHandler) ReplaceDevice(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Errorf("Invalid device ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid device ID",
+		})
+	}
+
+	replacement := new(Device)
+	err = c.BodyParser(replacement)
+	if err != nil {
+		log.Errorf("Failed to parse device: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	types, err := catalog.get(c.Context(), s.db)
+	if err != nil {
+		log.Errorf("Failed to replace device: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to replace device",
+		})
+	}
+
+	sanitizeDevice(replacement)
+	if validationErrors := validateDevice(replacement, types); len(validationErrors) > 0 {
+		err = validationError(validationErrors)
+		log.Errorf("Failed to replace device: %s", err.Error())
+		return validationErrorResponse(c, err)
+	}
+
+	device := &Device{ID: id}
+	err = updateDevice(c.Context(), s.db, device, replacementUpdate(replacement), audited(c, audit.ActionReplace))
+	if err != nil {
+		log.Errorf("Failed to replace device: %s", err.Error())
+		return updateErrorResponse(c, err, "Failed to replace device")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Device replaced successfully",
+		"device":  device,
+	})
+}
+
+// PatchDevice applies a JSON Merge Patch (RFC 7396) to a device. Fields set to
+// null are cleared, absent fields are left untouched.
+func (s *DeviceHandler) PatchDevice(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Errorf("Invalid device ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid device ID",
+		})
+	}
+
+	update, err := parseDevicePatch(c.Body())
+	if err != nil {
+		log.Errorf("Invalid merge patch: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	device := &Device{ID: id}
+	err = updateDevice(c.Context(), s.db, device, update, audited(c, audit.ActionUpdate))
+	if err != nil {
+		log.Errorf("Failed to update device: %s", err.Error())
+		return updateErrorResponse(c, err, "Failed to update device")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Device updated successfully",
+		"device":  device,
+	})
+}
+
 func (s *DeviceHandler) UpdateDeviceEmployee(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 	id, err := strconv.Atoi(idParam)
@@ -114,8 +223,13 @@ func (s *DeviceHandler) UpdateDeviceEmployee(c *fiber.Ctx) error {
 		})
 	}
 
-	device := &Device{ID: id, Employee: &requestBody.Employee}
-	err = UpdateDevice(c.Context(), s.db, device)
+	device := &Device{ID: id}
+	err = updateDevice(c.Context(), s.db, device, &DeviceUpdate{Employee: &requestBody.Employee}, audited(c, audit.ActionAssign))
+	if employee.IsAssignmentError(err) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		log.Errorf("Failed to update device: %s", err.Error())
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -140,8 +254,9 @@ func (s *DeviceHandler) DeleteDeviceEmployee(c *fiber.Ctx) error {
 	}
 
 	employee := ""
-	device := &Device{ID: id, Employee: &employee}
-	err = UpdateDevice(c.Context(), s.db, device)
+
+	device := &Device{ID: id}
+	err = updateDevice(c.Context(), s.db, device, &DeviceUpdate{Employee: &employee}, audited(c, audit.ActionUnassign))
 	if err != nil {
 		log.Errorf("Failed to remove device employee: %s", err.Error())
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -155,22 +270,260 @@ func (s *DeviceHandler) DeleteDeviceEmployee(c *fiber.Ctx) error {
 	})
 }
 
+// deviceFilter reads the filters shared by all endpoints listing devices.
+// Attribute filters are read from the raw query, as their operator may
+// contain the equals sign separating keys from values.
+func deviceFilter(c *fiber.Ctx) (*DeviceFilter, error) {
+	filter := &DeviceFilter{
+		Employee: c.Query("employee"),
+		Type:     c.Query("type"),
+		IP:       c.Query("ip"),
+		MAC:      c.Query("mac"),
+		Tags:     queryValues(c, "tag"),
+		TagsAny:  queryValues(c, "tag_any"),
+		TagsNone: queryValues(c, "tag_none"),
+	}
+
+	for _, param := range strings.Split(string(c.Request().URI().QueryString()), "&") {
+		param, err := url.QueryUnescape(param)
+		if err != nil || !strings.HasPrefix(param, AttributeFilterPrefix) {
+			continue
+		}
+
+		attribute, err := ParseAttributeFilter(strings.TrimPrefix(param, AttributeFilterPrefix))
+		if err != nil {
+			return nil, err
+		}
+		filter.Attributes = append(filter.Attributes, attribute)
+	}
+
+	return filter, nil
+}
+
+// queryValues returns every value of a repeatable query parameter, each of
+// which may hold a comma separated list.
+func queryValues(c *fiber.Ctx, key string) []string {
+	values := []string{}
+	for _, value := range c.Context().QueryArgs().PeekMulti(key) {
+		values = append(values, string(value))
+	}
+	return sanitizeTags(values)
+}
+
 func (s *DeviceHandler) GetDevices(c *fiber.Ctx) error {
-	employee := c.Query("employee")
-	deviceType := c.Query("type")
-	ip := c.Query("ip")
-	mac := c.Query("mac")
+	filter, err := deviceFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-	devices, err := GetDevices(c.Context(), s.db, employee, deviceType, ip, mac)
+	page := &PageOptions{
+		Sort:  c.Query("sort"),
+		Order: strings.ToLower(c.Query("order")),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		page.Limit, _ = strconv.Atoi(limit)
+		if page.Limit == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid limit",
+			})
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		page.Cursor, err = DecodeCursor(cursor)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid cursor",
+			})
+		}
+	}
+
+	result, err := GetDevices(c.Context(), s.db, filter, page)
 	if err != nil {
 		log.Errorf("Failed to retrieve devices: %s", err.Error())
+		if errors.Is(err, ErrValidation) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to retrieve devices",
 		})
 	}
 
+	next := pageLink(c, filter, page, result.Next)
+	prev := pageLink(c, filter, page, result.Prev)
+
+	links := []string{}
+	if next != nil {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, *next))
+	}
+	if prev != nil {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, *prev))
+	}
+	if len(links) > 0 {
+		c.Set(fiber.HeaderLink, strings.Join(links, ", "))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"devices": devices,
-		"count":   len(devices),
+		"devices": result.Devices,
+		"count":   len(result.Devices),
+		"total":   result.Total,
+		"next":    next,
+		"prev":    prev,
 	})
 }
+
+func pageLink(c *fiber.Ctx, filter *DeviceFilter, page *PageOptions, cursor *Cursor) *string {
+	if cursor == nil {
+		return nil
+	}
+
+	query := url.Values{}
+	if filter.Employee != "" {
+		query.Set("employee", filter.Employee)
+	}
+	if filter.Type != "" {
+		query.Set("type", filter.Type)
+	}
+	if filter.IP != "" {
+		query.Set("ip", filter.IP)
+	}
+	if filter.MAC != "" {
+		query.Set("mac", filter.MAC)
+	}
+	if len(filter.Tags) > 0 {
+		query.Set("tag", strings.Join(filter.Tags, ","))
+	}
+	if len(filter.TagsAny) > 0 {
+		query.Set("tag_any", strings.Join(filter.TagsAny, ","))
+	}
+	if len(filter.TagsNone) > 0 {
+		query.Set("tag_none", strings.Join(filter.TagsNone, ","))
+	}
+	query.Set("limit", strconv.Itoa(page.Limit))
+	query.Set("cursor", cursor.Encode())
+
+	link := c.BaseURL() + c.Path() + "?" + query.Encode()
+	for _, attribute := range filter.Attributes {
+		link += "&" + url.QueryEscape(AttributeFilterPrefix+attribute.String())
+	}
+	return &link
+}
+
+func updateErrorResponse(c *fiber.Ctx, err error, message string) error {
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.Is(err, ErrValidation):
+		return validationErrorResponse(c, err)
+	case errors.Is(err, pgx.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Device not found",
+		})
+	case employee.IsAssignmentError(err):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Device with this IP already exists",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+}
+
+// validationErrorResponse responds with the validation error and the
+// attributes violating the schema of the device type, if any.
+func validationErrorResponse(c *fiber.Ctx, err error) error {
+	response := fiber.Map{
+		"error": err.Error(),
+	}
+	if fields := FieldErrors(err); len(fields) > 0 {
+		response["fields"] = fields
+	}
+
+	return c.Status(fiber.StatusBadRequest).JSON(response)
+}
+
+func replacementUpdate(device *Device) *DeviceUpdate {
+	empty := ""
+	update := &DeviceUpdate{
+		Name:        &device.Name,
+		Type:        &device.Type,
+		IP:          &device.IP,
+		MAC:         &device.MAC,
+		Description: device.Description,
+		Employee:    device.Employee,
+		Attributes:  &device.Attributes,
+	}
+
+	if update.Description == nil {
+		update.Description = &empty
+	}
+
+	if update.Employee == nil {
+		update.Employee = &empty
+	}
+
+	return update
+}
+
+func parseDevicePatch(body []byte) (*DeviceUpdate, error) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	update := &DeviceUpdate{}
+	for field, value := range patch {
+		isNull := string(value) == "null"
+
+		var target any
+		switch field {
+		case "name":
+			update.Name = new(string)
+			target = update.Name
+		case "type":
+			update.Type = new(string)
+			target = update.Type
+		case "ip":
+			update.IP = new(net.IP)
+			target = update.IP
+		case "mac":
+			update.MAC = new(net.HardwareAddr)
+			target = update.MAC
+		case "description":
+			update.Description = new(string)
+			target = update.Description
+		case "employee":
+			update.Employee = new(string)
+			target = update.Employee
+		case "attributes":
+			// Merged into the current attributes, null removes them all.
+			if isNull {
+				update.Attributes = &map[string]any{}
+				continue
+			}
+			target = &update.AttributesPatch
+		default:
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+
+		if isNull {
+			continue
+		}
+
+		if err := json.Unmarshal(value, target); err != nil {
+			return nil, fmt.Errorf("invalid value for field %q", field)
+		}
+	}
+
+	return update, nil
+}
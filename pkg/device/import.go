@@ -0,0 +1,327 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"dmt/pkg/employee"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// MaxImportBodySize is the body limit of the import route.
+	MaxImportBodySize = 8 << 20
+	MaxImportRows     = 5000
+)
+
+const (
+	ImportAllOrNothing = "all_or_nothing"
+	ImportBestEffort   = "best_effort"
+)
+
+const (
+	ImportRowCreated = "created"
+	// ImportRowValid marks a row that passed but was not stored, because of a
+	// dry run or because another row failed an all-or-nothing import.
+	ImportRowValid   = "valid"
+	ImportRowInvalid = "invalid"
+)
+
+// ImportRow is a device as given in an import file. The MAC address may be
+// given in any notation net.ParseMAC accepts or base64 encoded as returned
+// by the API. The attributes are a JSON object, in CSV files as JSON text.
+type ImportRow struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	IP          string          `json:"ip"`
+	MAC         string          `json:"mac"`
+	Description *string         `json:"description"`
+	Employee    *string         `json:"employee"`
+	Attributes  json.RawMessage `json:"attributes"`
+}
+
+type ImportOptions struct {
+	Mode   string
+	DryRun bool
+}
+
+type ImportRowResult struct {
+	Row      int      `json:"row"`
+	Status   string   `json:"status"`
+	DeviceID *int     `json:"device_id,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+
+	device *Device
+}
+
+type ImportReport struct {
+	Mode      string            `json:"mode"`
+	DryRun    bool              `json:"dry_run"`
+	Committed bool              `json:"committed"`
+	Total     int               `json:"total"`
+	Created   int               `json:"created"`
+	Invalid   int               `json:"invalid"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+var importColumns = []string{"name", "type", "ip", "mac", "description", "employee", "attributes"}
+
+// parseImportCSV reads devices from a CSV file with a header row naming the
+// columns. Only name, type and mac are required columns.
+func parseImportCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !slices.Contains(importColumns, column) {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+		columns[column] = i
+	}
+
+	for _, column := range []string{"name", "type", "mac"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+
+	value := func(record []string, column string) string {
+		if i, ok := columns[column]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	optional := func(record []string, column string) *string {
+		if _, ok := columns[column]; !ok {
+			return nil
+		}
+		v := value(record, column)
+		return &v
+	}
+
+	rows := []ImportRow{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, ImportRow{
+			Name:        value(record, "name"),
+			Type:        value(record, "type"),
+			IP:          value(record, "ip"),
+			MAC:         value(record, "mac"),
+			Description: optional(record, "description"),
+			Employee:    optional(record, "employee"),
+			Attributes:  json.RawMessage(value(record, "attributes")),
+		})
+	}
+
+	return rows, nil
+}
+
+func parseImportJSON(body []byte) ([]ImportRow, error) {
+	var rows []ImportRow
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func parseImportMAC(value string) (net.HardwareAddr, error) {
+	if mac, err := net.ParseMAC(value); err == nil {
+		return mac, nil
+	}
+
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil && len(decoded) == 6 {
+		return net.HardwareAddr(decoded), nil
+	}
+
+	return nil, errors.New("invalid MAC address")
+}
+
+// device converts the row into a sanitized device and returns every problem
+// found with it.
+func (r *ImportRow) device(types map[string]DeviceType) (*Device, []error) {
+	device := &Device{
+		Name:        r.Name,
+		Type:        r.Type,
+		Description: r.Description,
+		Employee:    r.Employee,
+	}
+
+	var errs []error
+
+	if ip := strings.TrimSpace(r.IP); ip != "" {
+		if err := validateIP(ip); err != nil {
+			errs = append(errs, err)
+		} else {
+			device.IP = net.ParseIP(ip)
+		}
+	}
+
+	var macErr error
+	if mac := strings.TrimSpace(r.MAC); mac != "" {
+		device.MAC, macErr = parseImportMAC(mac)
+		if macErr != nil {
+			errs = append(errs, macErr)
+		}
+	}
+
+	var attributesErr error
+	if attributes := bytes.TrimSpace(r.Attributes); len(attributes) > 0 {
+		if err := json.Unmarshal(attributes, &device.Attributes); err != nil {
+			attributesErr = errors.New("attributes must be a JSON object")
+			errs = append(errs, attributesErr)
+		}
+	}
+
+	// After decoding the attributes, as null leaves them nil.
+	sanitizeDevice(device)
+
+	for _, err := range validateDevice(device, types) {
+		if attributesErr != nil && errors.As(err, new(FieldError)) {
+			continue
+		}
+		if macErr != nil && errors.Is(err, errMACRequired) {
+			continue
+		}
+		errs = append(errs, err)
+	}
+
+	return device, errs
+}
+
+func errorMessages(errs []error) []string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+// ImportDevices validates and inserts the rows in a single transaction. Each
+// row is inserted within a savepoint, so constraint violations are reported
+// per row. The transaction is only committed if it is no dry run and either
+// every row is valid or the import is best effort.
+func ImportDevices(ctx context.Context, db *pgxpool.Pool, rows []ImportRow, options ImportOptions) (*ImportReport, error) {
+	return importDevices(ctx, db, rows, options, nil)
+}
+
+// importDevices is ImportDevices, auditing the created devices if the import
+// is committed.
+func importDevices(ctx context.Context, db *pgxpool.Pool, rows []ImportRow, options ImportOptions, audit auditFunc) (*ImportReport, error) {
+	report := &ImportReport{
+		Mode:   options.Mode,
+		DryRun: options.DryRun,
+		Total:  len(rows),
+		Rows:   make([]ImportRowResult, 0, len(rows)),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	types, err := catalog.get(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	for i := range rows {
+		result := ImportRowResult{Row: i + 1, Status: ImportRowValid}
+
+		device, errs := rows[i].device(types)
+		if len(errs) == 0 {
+			savepoint, err := tx.Begin(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			err = insertDevice(ctx, savepoint, device)
+
+			var pgErr *pgconn.PgError
+			switch {
+			case err == nil:
+				if err := savepoint.Commit(ctx); err != nil {
+					return nil, err
+				}
+				result.device = device
+			case errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode:
+				if err := savepoint.Rollback(ctx); err != nil {
+					return nil, err
+				}
+				errs = append(errs, errors.New("device with this IP already exists"))
+			case employee.IsAssignmentError(err) || errors.Is(err, ErrValidation):
+				if err := savepoint.Rollback(ctx); err != nil {
+					return nil, err
+				}
+				errs = append(errs, err)
+			default:
+				return nil, err
+			}
+		}
+
+		if len(errs) > 0 {
+			result.Status = ImportRowInvalid
+			result.Errors = errorMessages(errs)
+			report.Invalid++
+		}
+
+		report.Rows = append(report.Rows, result)
+	}
+
+	if options.DryRun || (options.Mode == ImportAllOrNothing && report.Invalid > 0) {
+		return report, nil
+	}
+
+	for i := range report.Rows {
+		if device := report.Rows[i].device; device != nil {
+			if err := audit.record(tx, nil, device); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	report.Committed = true
+
+	for i := range report.Rows {
+		result := &report.Rows[i]
+		if result.device != nil {
+			result.Status = ImportRowCreated
+			result.DeviceID = &result.device.ID
+			report.Created++
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,69 @@
+package device
+
+import (
+	"bytes"
+	"dmt/pkg/audit"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// ImportDevices creates devices from a CSV file or a JSON array. The query
+// parameter mode selects all_or_nothing (default) or best_effort, dry_run=true
+// only validates. The response reports the outcome of every row.
+func (s *DeviceHandler) ImportDevices(c *fiber.Ctx) error {
+	options := ImportOptions{
+		Mode:   c.Query("mode", ImportAllOrNothing),
+		DryRun: c.QueryBool("dry_run"),
+	}
+
+	if options.Mode != ImportAllOrNothing && options.Mode != ImportBestEffort {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "mode must be all_or_nothing or best_effort",
+		})
+	}
+
+	var rows []ImportRow
+	var err error
+	switch {
+	case c.Is("csv"):
+		rows, err = parseImportCSV(bytes.NewReader(c.Body()))
+	case c.Is("json"):
+		rows, err = parseImportJSON(c.Body())
+	default:
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"error": "Content-Type must be text/csv or application/json",
+		})
+	}
+	if err != nil {
+		log.Errorf("Failed to parse import: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid import file: %s", err.Error()),
+		})
+	}
+
+	if len(rows) == 0 || len(rows) > MaxImportRows {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("import must contain between 1 and %d devices", MaxImportRows),
+		})
+	}
+
+	report, err := importDevices(c.Context(), s.db, rows, options, audited(c, audit.ActionCreate))
+	if err != nil {
+		log.Errorf("Failed to import devices: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to import devices",
+		})
+	}
+
+	status := fiber.StatusOK
+	switch {
+	case report.Committed && report.Created > 0:
+		status = fiber.StatusCreated
+	case !report.Committed && !report.DryRun:
+		status = fiber.StatusUnprocessableEntity
+	}
+
+	return c.Status(status).JSON(report)
+}
@@ -0,0 +1,232 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"dmt/pkg/signature"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// Notifier delivers a notification through a single channel.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, request *NotificationRequest) error
+}
+
+// WebhookNotifier posts the notification as is to the admin notification
+// service. Requests are signed with every given secret, see package
+// signature.
+type WebhookNotifier struct {
+	url     string
+	secrets []string
+	client  *http.Client
+}
+
+func NewWebhookNotifier(url string, secrets ...string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:     url,
+		secrets: secrets,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, request *NotificationRequest) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification request: %w", err)
+	}
+
+	if err := postJSON(ctx, n.client, n.url, jsonData, n.secrets); err != nil {
+		return err
+	}
+
+	log.Infof("Successfully sent notification - Message: %s", request.Message)
+	return nil
+}
+
+// DefaultNotificationTemplate renders a notification the way chat services
+// like Slack or Mattermost expect incoming webhooks.
+const DefaultNotificationTemplate = `{"text": {{json (printf "[%s] %s" .Level .Message)}}}`
+
+// TemplateNotifier posts the notification to a generic webhook, rendering the
+// body from a JSON template. The template is executed with the
+// NotificationRequest and provides a json function to quote values.
+type TemplateNotifier struct {
+	url      string
+	template *template.Template
+	secrets  []string
+	client   *http.Client
+}
+
+func NewTemplateNotifier(url string, body string, secrets ...string) (*TemplateNotifier, error) {
+	if body == "" {
+		body = DefaultNotificationTemplate
+	}
+
+	tmpl, err := template.New("notification").Funcs(template.FuncMap{
+		"json": func(value any) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+	}).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification template: %w", err)
+	}
+
+	return &TemplateNotifier{
+		url:      url,
+		template: tmpl,
+		secrets:  secrets,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+func (n *TemplateNotifier) Name() string {
+	return "template webhook"
+}
+
+func (n *TemplateNotifier) Notify(ctx context.Context, request *NotificationRequest) error {
+	var body bytes.Buffer
+	if err := n.template.Execute(&body, request); err != nil {
+		return fmt.Errorf("failed to render notification template: %w", err)
+	}
+
+	if !json.Valid(body.Bytes()) {
+		return errors.New("notification template did not render valid JSON")
+	}
+
+	return postJSON(ctx, n.client, n.url, body.Bytes(), n.secrets)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, secrets []string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(secrets) > 0 {
+		signature.Sign(req, body, secrets...)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification service returned status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+type EmailConfig struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// EmailNotifier sends the notification as plain text mail through an SMTP
+// relay. Authentication is only used if a username is configured.
+type EmailNotifier struct {
+	config EmailConfig
+}
+
+func NewEmailNotifier(config EmailConfig) *EmailNotifier {
+	return &EmailNotifier{config: config}
+}
+
+func (n *EmailNotifier) Name() string {
+	return "email"
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, request *NotificationRequest) error {
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		host, _, _ := strings.Cut(n.config.Addr, ":")
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [dmt] %s for employee %s\r\n", request.Level, request.EmployeeAbbreviation)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(request.Message)
+	msg.WriteString("\r\n")
+
+	if err := smtp.SendMail(n.config.Addr, auth, n.config.From, n.config.To, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send notification mail: %w", err)
+	}
+
+	return nil
+}
+
+// Route sends the notifications of the given levels to a notifier. A route
+// without levels receives every notification.
+type Route struct {
+	Notifier Notifier
+	Levels   []string
+}
+
+func (r *Route) matches(level string) bool {
+	return len(r.Levels) == 0 || slices.Contains(r.Levels, level)
+}
+
+// Router fans a notification out to every route matching its level. A failing
+// channel fails the whole delivery, so channels that succeeded may receive the
+// notification again when the outbox retries it.
+type Router struct {
+	routes []Route
+}
+
+func NewRouter(routes ...Route) *Router {
+	return &Router{routes: routes}
+}
+
+func (r *Router) Name() string {
+	names := make([]string, 0, len(r.routes))
+	for _, route := range r.routes {
+		names = append(names, route.Notifier.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+func (r *Router) Notify(ctx context.Context, request *NotificationRequest) error {
+	var errs []error
+	for i := range r.routes {
+		route := &r.routes[i]
+		if !route.matches(request.Level) {
+			continue
+		}
+
+		if err := route.Notifier.Notify(ctx, request); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", route.Notifier.Name(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -1,21 +1,24 @@
 package device
 
 import (
-	"bytes"
 	"context"
+	"dmt/pkg/dispatch"
+	"dmt/pkg/leader"
+	"dmt/pkg/webhook"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"sync"
 	"time"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
-	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Notification struct {
-	Employee string `json:"employee"`
-	Count    int    `json:"count"`
+	Employee string         `json:"employee"`
+	Count    int            `json:"count"`
+	Types    map[string]int `json:"types"`
 }
 
 type NotificationRequest struct {
@@ -24,115 +27,307 @@ type NotificationRequest struct {
 	Message              string `json:"message"`
 }
 
-func HandleDeviceCountNotifications(ctx context.Context, db *pgxpool.Pool, notificationUrl string) error {
-	conn, err := db.Acquire(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to acquire connection from pool: %w", err)
-	}
+// notificationLeaderLockID is the advisory lock electing the instance that
+// runs the notification pipeline.
+const notificationLeaderLockID = 0x646d74
 
-	notificationChan, err := DeviceCountListener(ctx, conn.Conn())
-	if err != nil {
-		conn.Release()
-		return fmt.Errorf("failed to start device count listener: %w", err)
+var DefaultLeaderConfig = leader.Config{
+	LockID:        notificationLeaderLockID,
+	RetryInterval: 5 * time.Second,
+	Heartbeat:     5 * time.Second,
+}
+
+// NotificationPipeline ties together the device count listener and the outbox
+// and webhook dispatchers it wakes up. Only the elected leader among all
+// instances runs it.
+type NotificationPipeline struct {
+	db       *pgxpool.Pool
+	notifier Notifier
+	elector  *leader.Elector
+
+	mu       sync.RWMutex
+	listener *DeviceCountListener
+}
+
+type PipelineStatus struct {
+	Leader   leader.Status   `json:"leader"`
+	Listener *ListenerHealth `json:"listener"`
+}
+
+// HandleDeviceCountNotifications campaigns for leadership and runs the
+// notification pipeline while this instance is the leader.
+func HandleDeviceCountNotifications(ctx context.Context, db *pgxpool.Pool, notifier Notifier, instance string) *NotificationPipeline {
+	pipeline := &NotificationPipeline{
+		db:       db,
+		notifier: notifier,
+		elector:  leader.NewElector(db, instance, DefaultLeaderConfig),
 	}
 
+	go pipeline.elector.Run(ctx, pipeline.run)
+
+	return pipeline
+}
+
+func (p *NotificationPipeline) run(ctx context.Context) {
+	defer log.Info("Notification handler stopped")
+
+	listener := NewDeviceCountListener(p.db, DefaultListenerConfig)
+	dispatcher := NewDispatcher(p.db, p.notifier, DefaultDispatcherConfig)
+	webhooks := webhook.NewDispatcher(p.db, webhook.DefaultDispatcherConfig)
+
+	p.mu.Lock()
+	p.listener = listener
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.listener = nil
+		p.mu.Unlock()
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
 	go func() {
-		defer conn.Release()
-		defer log.Info("Notification handler stopped")
-
-		for notification := range notificationChan {
-			if notificationUrl != "" {
-				SendNotification(notificationUrl, &notification)
-			} else {
-				log.Warnf("Notification URL is not set, skipping notification")
-			}
-		}
+		defer wg.Done()
+		dispatcher.Run(ctx)
 	}()
+	go func() {
+		defer wg.Done()
+		webhooks.Run(ctx)
+	}()
+
+	go listener.Run(ctx)
+
+	// Device webhooks are queued in the same transaction as the count change,
+	// so the count notification signals both.
+	for range listener.Wakeups() {
+		dispatcher.Wake()
+		webhooks.Wake()
+	}
 
-	return nil
+	wg.Wait()
 }
 
-func SendNotification(notificationUrl string, notification *Notification) {
-	notificationReq := NotificationRequest{
-		Level:                "warning",
-		EmployeeAbbreviation: notification.Employee,
-		Message:              fmt.Sprintf("Device count warning: Employee %s has %d devices", notification.Employee, notification.Count),
+// Status reports leadership and, on the leader, the listener health.
+func (p *NotificationPipeline) Status(ctx context.Context) (PipelineStatus, error) {
+	var status PipelineStatus
+
+	p.mu.RLock()
+	if p.listener != nil {
+		health := p.listener.Health()
+		status.Listener = &health
 	}
+	p.mu.RUnlock()
+
+	leaderStatus, err := p.elector.Status(ctx)
+	status.Leader = leaderStatus
 
-	jsonData, err := json.Marshal(notificationReq)
+	return status, err
+}
+
+// GetStatus reports the state of the notification pipeline of this instance.
+func (p *NotificationPipeline) GetStatus(c *fiber.Ctx) error {
+	if p == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Notification pipeline is not running",
+		})
+	}
+
+	status, err := p.Status(c.Context())
 	if err != nil {
-		log.Errorf("failed to marshal notification request: %v", err)
-		return
+		log.Errorf("Failed to determine notification leader: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to determine notification pipeline status",
+		})
 	}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	return c.Status(fiber.StatusOK).JSON(status)
+}
+
+const (
+	ListenerConnecting   = "connecting"
+	ListenerListening    = "listening"
+	ListenerReconnecting = "reconnecting"
+	ListenerStopped      = "stopped"
+)
+
+type ListenerHealth struct {
+	State      string    `json:"state"`
+	Since      time.Time `json:"since"`
+	Reconnects int       `json:"reconnects"`
+	LastError  *string   `json:"last_error"`
+}
+
+type ListenerConfig struct {
+	// KeepAlive is how long the listener waits for a notification before it
+	// pings the connection to detect silently dropped connections.
+	KeepAlive   time.Duration
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+var DefaultListenerConfig = ListenerConfig{
+	KeepAlive:   30 * time.Second,
+	BaseBackoff: 500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+}
+
+// DeviceCountListener listens on the device_count channel using a dedicated
+// pool connection and signals a wake-up for every notification. A broken
+// connection is replaced with backoff. Nothing needs to be recomputed for
+// notifications missed meanwhile: the count changes are queued in the outbox
+// in the same transaction, so a single wake-up after reconnecting lets the
+// dispatchers deliver everything that was missed.
+type DeviceCountListener struct {
+	db      *pgxpool.Pool
+	config  ListenerConfig
+	wakeups chan struct{}
+
+	mu     sync.RWMutex
+	health ListenerHealth
+}
+
+func NewDeviceCountListener(db *pgxpool.Pool, config ListenerConfig) *DeviceCountListener {
+	return &DeviceCountListener{
+		db:      db,
+		config:  config,
+		wakeups: make(chan struct{}, 1),
+		health: ListenerHealth{
+			State: ListenerConnecting,
+			Since: time.Now(),
+		},
 	}
+}
 
-	resp, err := client.Post(notificationUrl, "application/json", bytes.NewBuffer(jsonData))
+// Wakeups receives a signal whenever the outbox may hold new entries. It is
+// closed when the listener stops.
+func (l *DeviceCountListener) Wakeups() <-chan struct{} {
+	return l.wakeups
+}
+
+func (l *DeviceCountListener) Health() ListenerHealth {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.health
+}
+
+func (l *DeviceCountListener) setState(state string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if state == ListenerReconnecting && l.health.State != ListenerReconnecting {
+		l.health.Reconnects++
+	}
+	if l.health.State != state {
+		l.health.State = state
+		l.health.Since = time.Now()
+	}
 	if err != nil {
-		log.Errorf("failed to send notification: %v", err)
-		return
+		message := err.Error()
+		l.health.LastError = &message
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Errorf("notification service returned status code: %d", resp.StatusCode)
-		return
-	}
+func (l *DeviceCountListener) Run(ctx context.Context) {
+	defer close(l.wakeups)
+	defer log.Info("Device count listener stopped")
+	defer l.setState(ListenerStopped, nil)
+
+	failures := 0
+	reconnect := false
+
+	for {
+		listening, err := l.listen(ctx, reconnect)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if listening {
+			failures = 0
+			reconnect = true
+		}
+		failures++
+
+		l.setState(ListenerReconnecting, err)
+		log.Errorf("Device count listener lost its connection, reconnecting: %v", err)
 
-	log.Infof("Successfully sent notification - Message: %s", notificationReq.Message)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dispatch.Backoff(l.config.BaseBackoff, l.config.MaxBackoff, failures)):
+		}
+	}
 }
 
-func DeviceCountListener(ctx context.Context, conn *pgx.Conn) (<-chan Notification, error) {
-	notificationChan := make(chan Notification, 10)
+// listen runs until the connection breaks or ctx is cancelled. It reports
+// whether LISTEN succeeded so the caller can reset its backoff.
+func (l *DeviceCountListener) listen(ctx context.Context, reconnected bool) (bool, error) {
+	conn, err := l.db.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire connection from pool: %w", err)
+	}
+
+	broken := false
+	defer func() {
+		if broken {
+			// Closing the connection makes the pool discard it on release
+			_ = conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}()
 
-	_, err := conn.Exec(ctx, "LISTEN device_count")
+	_, err = conn.Exec(ctx, "LISTEN device_count")
 	if err != nil {
-		close(notificationChan)
-		return nil, fmt.Errorf("failed to listen for device count notifications: %w", err)
+		broken = true
+		return false, fmt.Errorf("failed to listen for device count notifications: %w", err)
 	}
 
-	go func() {
-		defer close(notificationChan)
-		defer log.Info("Device count listener stopped")
+	l.setState(ListenerListening, nil)
+	log.Info("Started listening for device count notifications")
 
-		log.Info("Started listening for device count notifications")
+	if reconnected {
+		l.wake()
+	}
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, l.config.KeepAlive)
+		pgNotification, err := conn.Conn().WaitForNotification(waitCtx)
+		timedOut := waitCtx.Err() != nil
+		cancel()
 
-		for {
-			select {
-			case <-ctx.Done():
+		if err != nil {
+			if ctx.Err() != nil {
 				log.Info("Stopping device count notification listener")
-				return
-			default:
-				pgNotification, err := conn.WaitForNotification(ctx)
-				if err != nil {
-					if ctx.Err() != nil {
-						return
-					}
-					log.Errorf("Failed to wait for notification: %v", err)
-					continue
-				}
+				return true, nil
+			}
 
-				var deviceNotification Notification
-				if err := json.Unmarshal([]byte(pgNotification.Payload), &deviceNotification); err != nil {
-					log.Errorf("Failed to parse notification payload: %v", err)
+			if timedOut && !conn.Conn().IsClosed() {
+				if err := conn.Ping(ctx); err == nil {
 					continue
 				}
+			}
 
-				log.Infof("Received device count notification - Employee: %s, Count: %d", deviceNotification.Employee, deviceNotification.Count)
+			broken = true
+			return true, fmt.Errorf("failed to wait for notification: %w", err)
+		}
 
-				if deviceNotification.Count >= 3 {
-					select {
-					case notificationChan <- deviceNotification:
-						log.Infof("Notification sent to alarm service")
-					case <-ctx.Done():
-						return
-					}
-				}
-			}
+		var deviceNotification Notification
+		if err := json.Unmarshal([]byte(pgNotification.Payload), &deviceNotification); err != nil {
+			log.Errorf("Failed to parse notification payload: %v", err)
+			continue
 		}
-	}()
 
-	return notificationChan, nil
+		log.Infof("Received device count notification - Employee: %s, Count: %d", deviceNotification.Employee, deviceNotification.Count)
+
+		l.wake()
+	}
+}
+
+// wake signals the dispatchers without blocking. Signals are coalesced, as a
+// single wake-up delivers all due entries.
+func (l *DeviceCountListener) wake() {
+	select {
+	case l.wakeups <- struct{}{}:
+	default:
+	}
 }
@@ -0,0 +1,350 @@
+package device
+
+import (
+	"context"
+	"dmt/pkg/dispatch"
+	"dmt/pkg/webhook"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxSkipped   = "skipped"
+	OutboxDead      = "dead"
+)
+
+type OutboxEntry struct {
+	ID            int64           `json:"id" db:"id"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Status        string          `json:"status" db:"status"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     *string         `json:"last_error" db:"last_error"`
+	DeliveredAt   *time.Time      `json:"delivered_at" db:"delivered_at"`
+}
+
+const outboxColumns = "id, created_at, payload, status, attempts, next_attempt_at, last_error, delivered_at"
+
+// ClaimOutboxEntries leases up to limit due entries to the caller. Claimed
+// entries are hidden from other dispatchers until the lease expires, so an
+// entry whose dispatcher crashed mid-delivery is picked up again.
+func ClaimOutboxEntries(ctx context.Context, db *pgxpool.Pool, limit int, lease time.Duration) ([]OutboxEntry, error) {
+	query := `
+		UPDATE notification_outbox
+		SET attempts = attempts + 1, next_attempt_at = NOW() + $2::interval
+		WHERE id IN (
+			SELECT id
+			FROM notification_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries, err := pgx.CollectRows(rows, pgx.RowToStructByName[OutboxEntry])
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func MarkOutboxDelivered(ctx context.Context, db *pgxpool.Pool, entry *OutboxEntry) error {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'delivered', delivered_at = NOW(), last_error = NULL
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := db.Exec(ctx, query, entry.ID)
+	return err
+}
+
+func MarkOutboxSkipped(ctx context.Context, db *pgxpool.Pool, entry *OutboxEntry, reason string) error {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'skipped', last_error = $2
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := db.Exec(ctx, query, entry.ID, reason)
+	return err
+}
+
+// MarkOutboxFailed schedules the next attempt of a failed entry, or moves it
+// to the dead-letter state if retryAt is nil.
+func MarkOutboxFailed(ctx context.Context, db *pgxpool.Pool, entry *OutboxEntry, deliveryErr error, retryAt *time.Time) error {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'dead', last_error = $2
+		WHERE id = $1
+	`
+	args := []interface{}{entry.ID, deliveryErr.Error()}
+
+	if retryAt != nil {
+		query = `
+			UPDATE notification_outbox
+			SET next_attempt_at = $3, last_error = $2
+			WHERE id = $1
+		`
+		args = append(args, *retryAt)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := db.Exec(ctx, query, args...)
+	return err
+}
+
+func GetOutboxEntries(ctx context.Context, db *pgxpool.Pool, status string, limit int) ([]OutboxEntry, error) {
+	query := `
+		SELECT ` + outboxColumns + `
+		FROM notification_outbox
+		WHERE status = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries, err := pgx.CollectRows(rows, pgx.RowToStructByName[OutboxEntry])
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// RetryOutboxEntry moves a dead entry back to pending with a fresh attempt budget.
+func RetryOutboxEntry(ctx context.Context, db *pgxpool.Pool, entry *OutboxEntry) error {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+		RETURNING ` + outboxColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, entry.ID)
+	if err != nil {
+		return err
+	}
+
+	retried, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[OutboxEntry])
+	if err != nil {
+		return err
+	}
+	*entry = retried
+
+	return nil
+}
+
+var DefaultDispatcherConfig = dispatch.Config{
+	BatchSize:    10,
+	PollInterval: 5 * time.Second,
+	Lease:        time.Minute,
+	MaxAttempts:  8,
+	BaseBackoff:  2 * time.Second,
+	MaxBackoff:   10 * time.Minute,
+}
+
+// Dispatcher delivers the notification outbox. It polls for due entries and
+// can be woken up early, e.g. by the device count listener.
+type Dispatcher struct {
+	db       *pgxpool.Pool
+	notifier Notifier
+	config   dispatch.Config
+	wake     chan struct{}
+}
+
+func NewDispatcher(db *pgxpool.Pool, notifier Notifier, config dispatch.Config) *Dispatcher {
+	return &Dispatcher{
+		db:       db,
+		notifier: notifier,
+		config:   config,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	defer log.Info("Notification dispatcher stopped")
+
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			claimed, err := d.dispatch(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Errorf("Failed to dispatch notifications: %v", err)
+				break
+			}
+			if claimed < d.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	entries, err := ClaimOutboxEntries(ctx, d.db, d.config.BatchSize, d.config.Lease)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+
+	policies, err := GetPolicies(ctx, d.db)
+	if err != nil {
+		return len(entries), err
+	}
+
+	for i := range entries {
+		if err := d.deliver(ctx, &entries[i], policies); err != nil {
+			return len(entries), err
+		}
+	}
+
+	return len(entries), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, entry *OutboxEntry, policies []Policy) error {
+	var notification Notification
+	if err := json.Unmarshal(entry.Payload, &notification); err != nil {
+		return MarkOutboxFailed(ctx, d.db, entry, fmt.Errorf("invalid payload: %w", err), nil)
+	}
+
+	state, err := GetAlertState(ctx, d.db, notification.Employee)
+	if err != nil {
+		return err
+	}
+
+	// Entries are delivered in order, but a retried entry may come after a
+	// newer entry of the same employee. Its counts are outdated by then.
+	if state.supersedes(entry) {
+		return MarkOutboxSkipped(ctx, d.db, entry, "superseded by a newer notification")
+	}
+
+	violations := EvaluatePolicies(policies, &notification)
+
+	request := alertFor(&notification, violations, state.PolicyIDs)
+	if request == nil {
+		err := SaveAlertState(ctx, d.db, notification.Employee, entry, violatedPolicyIDs(violations))
+		if errors.Is(err, ErrAlertStateSuperseded) {
+			return MarkOutboxSkipped(ctx, d.db, entry, "superseded by a newer notification")
+		}
+		if err != nil {
+			return err
+		}
+
+		reason := "no policy violated"
+		if len(violations) > 0 {
+			reason = "employee already alerted"
+		}
+		return MarkOutboxSkipped(ctx, d.db, entry, reason)
+	}
+
+	if d.notifier == nil {
+		log.Warnf("No notification channel is configured, skipping notification")
+		if err := d.alerted(ctx, entry, &notification, request, violations); err != nil {
+			return err
+		}
+		return MarkOutboxSkipped(ctx, d.db, entry, "no notification channel configured")
+	}
+
+	deliveryErr := d.notifier.Notify(ctx, request)
+	if deliveryErr == nil {
+		if err := d.alerted(ctx, entry, &notification, request, violations); err != nil {
+			return err
+		}
+		return MarkOutboxDelivered(ctx, d.db, entry)
+	}
+
+	if entry.Attempts >= d.config.MaxAttempts {
+		log.Errorf("Giving up on notification %d after %d attempts: %v", entry.ID, entry.Attempts, deliveryErr)
+		return MarkOutboxFailed(ctx, d.db, entry, deliveryErr, nil)
+	}
+
+	retryAt := time.Now().Add(d.config.RetryDelay(entry.Attempts))
+	log.Errorf("Failed to deliver notification %d (attempt %d), retrying at %s: %v", entry.ID, entry.Attempts, retryAt.Format(time.RFC3339), deliveryErr)
+	return MarkOutboxFailed(ctx, d.db, entry, deliveryErr, &retryAt)
+}
+
+// alerted remembers the violations the employee was alerted about and
+// publishes newly exceeded thresholds to webhook subscribers. Nothing is
+// published if a newer entry was applied while the alert was sent.
+func (d *Dispatcher) alerted(ctx context.Context, entry *OutboxEntry, notification *Notification, request *NotificationRequest, violations []Violation) error {
+	err := SaveAlertState(ctx, d.db, notification.Employee, entry, violatedPolicyIDs(violations))
+	if errors.Is(err, ErrAlertStateSuperseded) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if request.Level != LevelWarning {
+		return nil
+	}
+
+	descriptions := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		descriptions = append(descriptions, violation.String())
+	}
+
+	return webhook.Enqueue(ctx, d.db, webhook.EventThresholdExceeded, fiber.Map{
+		"employee":   notification.Employee,
+		"count":      notification.Count,
+		"types":      notification.Types,
+		"violations": descriptions,
+	})
+}
@@ -0,0 +1,81 @@
+package device
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OutboxHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxHandler(db *pgxpool.Pool) *OutboxHandler {
+	return &OutboxHandler{db: db}
+}
+
+// GetEntries lists outbox entries of one status, the dead letters by default.
+func (s *OutboxHandler) GetEntries(c *fiber.Ctx) error {
+	status := c.Query("status", OutboxDead)
+	switch status {
+	case OutboxPending, OutboxDelivered, OutboxSkipped, OutboxDead:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid status",
+		})
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid limit",
+		})
+	}
+
+	entries, err := GetOutboxEntries(c.Context(), s.db, status, limit)
+	if err != nil {
+		log.Errorf("Failed to retrieve outbox entries: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve notifications",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"notifications": entries,
+		"count":         len(entries),
+	})
+}
+
+func (s *OutboxHandler) RetryEntry(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		log.Errorf("Invalid notification ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid notification ID",
+		})
+	}
+
+	entry := &OutboxEntry{ID: id}
+	err = RetryOutboxEntry(c.Context(), s.db, entry)
+	if err != nil {
+		log.Errorf("Failed to retry notification: %s", err.Error())
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Dead notification not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retry notification",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":      "Notification scheduled for redelivery",
+		"notification": entry,
+	})
+}
@@ -0,0 +1,237 @@
+package device
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// sortColumns maps every sortable column to the SQL type cursor values are cast to.
+var sortColumns = map[string]string{
+	"id":          "integer",
+	"created_at":  "timestamptz",
+	"updated_at":  "timestamptz",
+	"name":        "text",
+	"type":        "text",
+	"ip":          "inet",
+	"mac":         "macaddr",
+	"description": "text",
+	"employee":    "text",
+}
+
+// Cursor marks a position in a sorted device listing. It is handed to clients
+// as an opaque string.
+type Cursor struct {
+	Sort     string  `json:"s"`
+	Order    string  `json:"o"`
+	Value    *string `json:"v"`
+	ID       int     `json:"i"`
+	Backward bool    `json:"b,omitempty"`
+}
+
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrValidation)
+	}
+
+	cursor := new(Cursor)
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrValidation)
+	}
+
+	if _, ok := sortColumns[cursor.Sort]; !ok || (cursor.Order != "asc" && cursor.Order != "desc") {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrValidation)
+	}
+
+	return cursor, nil
+}
+
+type PageOptions struct {
+	Limit  int
+	Sort   string
+	Order  string
+	Cursor *Cursor
+}
+
+// normalize fills in defaults and validates the options. The sort order of a
+// cursor takes precedence, a conflicting explicit sort order is rejected.
+func (p *PageOptions) normalize() error {
+	if p.Limit == 0 {
+		p.Limit = DefaultPageLimit
+	}
+	if p.Limit < 1 || p.Limit > MaxPageLimit {
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrValidation, MaxPageLimit)
+	}
+
+	if p.Cursor != nil {
+		if (p.Sort != "" && p.Sort != p.Cursor.Sort) || (p.Order != "" && p.Order != p.Cursor.Order) {
+			return fmt.Errorf("%w: cursor does not match sort order", ErrValidation)
+		}
+		p.Sort = p.Cursor.Sort
+		p.Order = p.Cursor.Order
+		return nil
+	}
+
+	if p.Sort == "" {
+		p.Sort = "created_at"
+		if p.Order == "" {
+			p.Order = "desc"
+		}
+	}
+	if p.Order == "" {
+		p.Order = "asc"
+	}
+
+	if _, ok := sortColumns[p.Sort]; !ok {
+		return fmt.Errorf("%w: cannot sort by %q", ErrValidation, p.Sort)
+	}
+	if p.Order != "asc" && p.Order != "desc" {
+		return fmt.Errorf("%w: order must be asc or desc", ErrValidation)
+	}
+
+	return nil
+}
+
+type DevicePage struct {
+	Devices []Device
+	Total   int
+	Next    *Cursor
+	Prev    *Cursor
+}
+
+// keysetQuery returns the WHERE condition continuing after the cursor and the
+// ORDER BY clause for the scan direction. NULL values always sort last.
+func (p *PageOptions) keysetQuery(args []interface{}) (string, string, []interface{}) {
+	column := p.Sort
+	ascending := p.Order == "asc"
+	backward := p.Cursor != nil && p.Cursor.Backward
+	if backward {
+		ascending = !ascending
+	}
+
+	op, direction := ">", "ASC"
+	if !ascending {
+		op, direction = "<", "DESC"
+	}
+
+	nulls := "NULLS LAST"
+	if backward {
+		nulls = "NULLS FIRST"
+	}
+
+	orderBy := fmt.Sprintf(" ORDER BY %s %s %s", column, direction, nulls)
+	if column != "id" {
+		orderBy += fmt.Sprintf(", id %s", direction)
+	}
+
+	if p.Cursor == nil {
+		return "", orderBy, args
+	}
+
+	args = append(args, p.Cursor.ID)
+	idArg := len(args)
+
+	if column == "id" {
+		return fmt.Sprintf(" AND id %s $%d", op, idArg), orderBy, args
+	}
+
+	if p.Cursor.Value == nil {
+		if backward {
+			return fmt.Sprintf(" AND (%[1]s IS NOT NULL OR id %[2]s $%[3]d)", column, op, idArg), orderBy, args
+		}
+		return fmt.Sprintf(" AND (%[1]s IS NULL AND id %[2]s $%[3]d)", column, op, idArg), orderBy, args
+	}
+
+	args = append(args, *p.Cursor.Value)
+	value := fmt.Sprintf("($%d::text)::%s", len(args), sortColumns[column])
+
+	condition := fmt.Sprintf("%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id %[2]s $%[4]d)", column, op, value, idArg)
+	if !backward {
+		condition += fmt.Sprintf(" OR %s IS NULL", column)
+	}
+
+	return " AND (" + condition + ")", orderBy, args
+}
+
+// paginate trims the over-fetched rows of a page query and derives the
+// cursors of the neighbouring pages.
+func (p *PageOptions) paginate(devices []Device) ([]Device, *Cursor, *Cursor) {
+	hasMore := len(devices) > p.Limit
+	if hasMore {
+		devices = devices[:p.Limit]
+	}
+
+	backward := p.Cursor != nil && p.Cursor.Backward
+	if backward {
+		slices.Reverse(devices)
+	}
+
+	if len(devices) == 0 {
+		return devices, nil, nil
+	}
+
+	var next, prev *Cursor
+	if hasMore || backward {
+		next = p.cursorFor(&devices[len(devices)-1], false)
+	}
+	if (backward && hasMore) || (!backward && p.Cursor != nil) {
+		prev = p.cursorFor(&devices[0], true)
+	}
+
+	return devices, next, prev
+}
+
+func (p *PageOptions) cursorFor(device *Device, backward bool) *Cursor {
+	return &Cursor{
+		Sort:     p.Sort,
+		Order:    p.Order,
+		Value:    sortValue(device, p.Sort),
+		ID:       device.ID,
+		Backward: backward,
+	}
+}
+
+func sortValue(device *Device, column string) *string {
+	var value string
+
+	switch column {
+	case "id":
+		value = strconv.Itoa(device.ID)
+	case "created_at":
+		value = device.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		value = device.UpdatedAt.Format(time.RFC3339Nano)
+	case "name":
+		value = device.Name
+	case "type":
+		value = device.Type
+	case "ip":
+		if device.IP == nil {
+			return nil
+		}
+		value = device.IP.String()
+	case "mac":
+		value = strings.ToLower(device.MAC.String())
+	case "description":
+		return device.Description
+	case "employee":
+		return device.Employee
+	}
+
+	return &value
+}
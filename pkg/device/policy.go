@@ -0,0 +1,283 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	PolicyGlobal   = "global"
+	PolicyEmployee = "employee"
+	PolicyType     = "type"
+)
+
+// Policy limits the number of devices an employee may hold. The employee
+// policy overrides the global one for the total count, type policies limit
+// the count of a single device type on top of that.
+type Policy struct {
+	ID         int       `json:"id" db:"id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	Scope      string    `json:"scope" db:"scope"`
+	Employee   *string   `json:"employee" db:"employee"`
+	DeviceType *string   `json:"device_type" db:"device_type"`
+	MaxDevices int       `json:"max_devices" db:"max_devices"`
+}
+
+type Violation struct {
+	Policy Policy
+	Count  int
+}
+
+func (v *Violation) String() string {
+	switch v.Policy.Scope {
+	case PolicyType:
+		return fmt.Sprintf("type policy #%d allows %d %s devices, has %d", v.Policy.ID, v.Policy.MaxDevices, *v.Policy.DeviceType, v.Count)
+	default:
+		return fmt.Sprintf("%s policy #%d allows %d devices, has %d", v.Policy.Scope, v.Policy.ID, v.Policy.MaxDevices, v.Count)
+	}
+}
+
+// EvaluatePolicies returns every policy the device counts of the notification
+// violate.
+func EvaluatePolicies(policies []Policy, notification *Notification) []Violation {
+	var total *Policy
+	violations := []Violation{}
+
+	for i := range policies {
+		policy := &policies[i]
+
+		switch policy.Scope {
+		case PolicyGlobal:
+			if total == nil {
+				total = policy
+			}
+		case PolicyEmployee:
+			if *policy.Employee == notification.Employee {
+				total = policy
+			}
+		case PolicyType:
+			count := notification.Types[*policy.DeviceType]
+			if count > policy.MaxDevices {
+				violations = append(violations, Violation{Policy: *policy, Count: count})
+			}
+		}
+	}
+
+	if total != nil && notification.Count > total.MaxDevices {
+		violations = append([]Violation{{Policy: *total, Count: notification.Count}}, violations...)
+	}
+
+	return violations
+}
+
+func describeViolations(violations []Violation) string {
+	descriptions := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		descriptions = append(descriptions, violation.String())
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+func validatePolicy(policy *Policy, types map[string]DeviceType) error {
+	if policy.MaxDevices < 0 {
+		return fmt.Errorf("%w: max_devices must not be negative", ErrValidation)
+	}
+
+	switch policy.Scope {
+	case PolicyGlobal:
+		if policy.Employee != nil || policy.DeviceType != nil {
+			return fmt.Errorf("%w: global policy must not have an employee or device type", ErrValidation)
+		}
+	case PolicyEmployee:
+		if policy.Employee == nil || policy.DeviceType != nil {
+			return fmt.Errorf("%w: employee policy requires an employee and no device type", ErrValidation)
+		}
+		if err := validateEmployee(policy.Employee); err != nil {
+			return fmt.Errorf("%w: %s", ErrValidation, err.Error())
+		}
+	case PolicyType:
+		if policy.DeviceType == nil || policy.Employee != nil {
+			return fmt.Errorf("%w: type policy requires a device type and no employee", ErrValidation)
+		}
+		if err := validateType(*policy.DeviceType, types); err != nil {
+			return fmt.Errorf("%w: %s", ErrValidation, err.Error())
+		}
+	default:
+		return fmt.Errorf("%w: scope must be global, employee or type", ErrValidation)
+	}
+
+	return nil
+}
+
+const policyColumns = "id, created_at, updated_at, scope, employee, device_type, max_devices"
+
+func InsertPolicy(ctx context.Context, db *pgxpool.Pool, policy *Policy) error {
+	types, err := catalog.get(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if err := validatePolicy(policy, types); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO notification_policy (scope, employee, device_type, max_devices)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + policyColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, policy.Scope, policy.Employee, policy.DeviceType, policy.MaxDevices)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Policy])
+	if err != nil {
+		return typeError(err)
+	}
+	*policy = inserted
+
+	return nil
+}
+
+// UpdatePolicyLimit changes the limit of a policy, its scope is immutable.
+func UpdatePolicyLimit(ctx context.Context, db *pgxpool.Pool, policy *Policy) error {
+	if policy.MaxDevices < 0 {
+		return fmt.Errorf("%w: max_devices must not be negative", ErrValidation)
+	}
+
+	query := `
+		UPDATE notification_policy
+		SET max_devices = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING ` + policyColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, policy.MaxDevices, policy.ID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Policy])
+	if err != nil {
+		return err
+	}
+	*policy = updated
+
+	return nil
+}
+
+var ErrGlobalPolicy = errors.New("the global policy cannot be deleted")
+
+func DeletePolicy(ctx context.Context, db *pgxpool.Pool, policy *Policy) error {
+	query := `
+		DELETE FROM notification_policy
+		WHERE id = $1
+		RETURNING scope
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, query, policy.ID).Scan(&policy.Scope)
+	if err != nil {
+		return err
+	}
+
+	if policy.Scope == PolicyGlobal {
+		return ErrGlobalPolicy
+	}
+
+	return tx.Commit(ctx)
+}
+
+func GetPolicies(ctx context.Context, db *pgxpool.Pool) ([]Policy, error) {
+	query := `
+		SELECT ` + policyColumns + `
+		FROM notification_policy
+		ORDER BY id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies, err := pgx.CollectRows(rows, pgx.RowToStructByName[Policy])
+	if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// GetDeviceCounts returns the total and per-type device counts of every
+// employee holding at least one device. Like the count trigger, the total
+// leaves out types not counting toward the limit.
+func GetDeviceCounts(ctx context.Context, db *pgxpool.Pool) ([]Notification, error) {
+	query := `
+		SELECT device.employee, device.type, device_type.counts_toward_limit, COUNT(*)
+		FROM device
+		JOIN device_type ON device_type.name = device.type
+		WHERE device.employee IS NOT NULL
+		GROUP BY device.employee, device.type, device_type.counts_toward_limit
+		ORDER BY device.employee
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []Notification{}
+	for rows.Next() {
+		var employee, deviceType string
+		var counted bool
+		var count int
+		if err := rows.Scan(&employee, &deviceType, &counted, &count); err != nil {
+			return nil, err
+		}
+
+		if len(counts) == 0 || counts[len(counts)-1].Employee != employee {
+			counts = append(counts, Notification{Employee: employee, Types: map[string]int{}})
+		}
+
+		current := &counts[len(counts)-1]
+		if counted {
+			current.Count += count
+		}
+		current.Types[deviceType] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,152 @@
+package device
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PolicyHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewPolicyHandler(db *pgxpool.Pool) *PolicyHandler {
+	return &PolicyHandler{db: db}
+}
+
+func (s *PolicyHandler) GetPolicies(c *fiber.Ctx) error {
+	policies, err := GetPolicies(c.Context(), s.db)
+	if err != nil {
+		log.Errorf("Failed to retrieve policies: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve policies",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"policies": policies,
+		"count":    len(policies),
+	})
+}
+
+func (s *PolicyHandler) CreatePolicy(c *fiber.Ctx) error {
+	policy := new(Policy)
+	err := c.BodyParser(policy)
+	if err != nil {
+		log.Errorf("Failed to parse policy: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	err = InsertPolicy(c.Context(), s.db, policy)
+	if err != nil {
+		log.Errorf("Failed to create policy: %s", err.Error())
+
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, ErrValidation):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode:
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A policy for this scope already exists",
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create policy",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Policy created successfully",
+		"policy":  policy,
+	})
+}
+
+func (s *PolicyHandler) UpdatePolicy(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Errorf("Invalid policy ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid policy ID",
+		})
+	}
+
+	var requestBody struct {
+		MaxDevices *int `json:"max_devices"`
+	}
+	err = c.BodyParser(&requestBody)
+	if err != nil || requestBody.MaxDevices == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "max_devices is required",
+		})
+	}
+
+	policy := &Policy{ID: id, MaxDevices: *requestBody.MaxDevices}
+	err = UpdatePolicyLimit(c.Context(), s.db, policy)
+	if err != nil {
+		log.Errorf("Failed to update policy: %s", err.Error())
+		switch {
+		case errors.Is(err, ErrValidation):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, pgx.ErrNoRows):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Policy not found",
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update policy",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Policy updated successfully",
+		"policy":  policy,
+	})
+}
+
+func (s *PolicyHandler) DeletePolicy(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Errorf("Invalid policy ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid policy ID",
+		})
+	}
+
+	err = DeletePolicy(c.Context(), s.db, &Policy{ID: id})
+	if err != nil {
+		log.Errorf("Failed to delete policy: %s", err.Error())
+		switch {
+		case errors.Is(err, ErrGlobalPolicy):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, pgx.ErrNoRows):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Policy not found",
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete policy",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Policy deleted successfully",
+	})
+}
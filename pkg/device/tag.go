@@ -0,0 +1,275 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxTagsPerRequest limits how many tags are added to a device at once.
+const MaxTagsPerRequest = 50
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ErrTagNotFound is returned when removing a tag the device does not carry.
+var ErrTagNotFound = errors.New("device does not carry this tag")
+
+// Tag is a label of devices with the number of devices carrying it.
+type Tag struct {
+	Name    string `json:"name" db:"name"`
+	Devices int    `json:"devices" db:"devices"`
+}
+
+// sanitizeTags lowercases and trims the tags, drops empty ones and
+// duplicates and splits comma separated lists.
+func sanitizeTags(tags []string) []string {
+	sanitized := []string{}
+	for _, list := range tags {
+		for _, tag := range strings.Split(list, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag != "" && !slices.Contains(sanitized, tag) {
+				sanitized = append(sanitized, tag)
+			}
+		}
+	}
+	return sanitized
+}
+
+func validateTags(tags []string) []error {
+	errs := []error{}
+
+	if len(tags) == 0 {
+		errs = append(errs, errors.New("tags are required"))
+	}
+	if len(tags) > MaxTagsPerRequest {
+		errs = append(errs, fmt.Errorf("at most %d tags can be added at once", MaxTagsPerRequest))
+	}
+
+	for _, tag := range tags {
+		if !tagPattern.MatchString(tag) {
+			errs = append(errs, fmt.Errorf("invalid tag %q: tags are 1 to 64 lowercase letters, digits, - or _", tag))
+		}
+	}
+
+	return errs
+}
+
+// AddDeviceTags adds the tags to the device identified by device.ID and scans
+// the resulting device back into it. Unknown tags are created, tags the device
+// already carries are left alone. It returns pgx.ErrNoRows if the device does
+// not exist.
+func AddDeviceTags(ctx context.Context, db *pgxpool.Pool, device *Device, tags []string) error {
+	return addDeviceTags(ctx, db, device, tags, nil)
+}
+
+// addDeviceTags is AddDeviceTags, auditing the change.
+func addDeviceTags(ctx context.Context, db *pgxpool.Pool, device *Device, tags []string, audit auditFunc) error {
+	tags = sanitizeTags(tags)
+	if validationErrors := validateTags(tags); len(validationErrors) > 0 {
+		return validationError(validationErrors)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockDevice(ctx, tx, device.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO tag (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`, tags)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO device_tag (device_id, tag_id)
+			SELECT $1, id FROM tag WHERE name = ANY($2)
+			ON CONFLICT DO NOTHING
+	`, device.ID, tags)
+	if err != nil {
+		return err
+	}
+
+	if err := touchDevice(ctx, tx, device, result.RowsAffected() > 0); err != nil {
+		return err
+	}
+
+	if err := audit.record(tx, before, device); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RemoveDeviceTag removes the tag from the device identified by device.ID and
+// scans the resulting device back into it. The tag itself is kept. It returns
+// pgx.ErrNoRows if the device does not exist and ErrTagNotFound if it does
+// not carry the tag.
+func RemoveDeviceTag(ctx context.Context, db *pgxpool.Pool, device *Device, tag string) error {
+	return removeDeviceTag(ctx, db, device, tag, nil)
+}
+
+// removeDeviceTag is RemoveDeviceTag, auditing the change.
+func removeDeviceTag(ctx context.Context, db *pgxpool.Pool, device *Device, tag string, audit auditFunc) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockDevice(ctx, tx, device.ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(ctx, `
+		DELETE FROM device_tag USING tag
+		WHERE device_tag.tag_id = tag.id AND device_tag.device_id = $1 AND tag.name = $2
+	`, device.ID, strings.ToLower(strings.TrimSpace(tag)))
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+
+	if err := touchDevice(ctx, tx, device, true); err != nil {
+		return err
+	}
+
+	if err := audit.record(tx, before, device); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// touchDevice reads the device back after its tags changed, bumping its
+// updated_at if they actually did.
+func touchDevice(ctx context.Context, tx pgx.Tx, device *Device, changed bool) error {
+	query := `SELECT ` + DeviceColumns + ` FROM device WHERE id = $1`
+	if changed {
+		query = `UPDATE device SET updated_at = NOW() WHERE id = $1 RETURNING ` + DeviceColumns
+	}
+
+	rows, err := tx.Query(ctx, query, device.ID)
+	if err != nil {
+		return err
+	}
+
+	found, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Device])
+	if err != nil {
+		return err
+	}
+	*device = found
+
+	return nil
+}
+
+// GetTags returns every tag with the number of devices carrying it, the most
+// used first.
+func GetTags(ctx context.Context, db *pgxpool.Pool) ([]Tag, error) {
+	query := `
+		SELECT tag.name, COUNT(device_tag.device_id) AS devices
+		FROM tag
+		LEFT JOIN device_tag ON device_tag.tag_id = tag.id
+		GROUP BY tag.name
+		ORDER BY devices DESC, tag.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[Tag])
+}
+
+// DeleteTag removes the tag from every device, bumping their updated_at. It
+// returns pgx.ErrNoRows if the tag does not exist.
+func DeleteTag(ctx context.Context, db *pgxpool.Pool, name string) error {
+	return deleteTag(ctx, db, name, nil)
+}
+
+// deleteTag is DeleteTag, auditing every device that carried the tag.
+func deleteTag(ctx context.Context, db *pgxpool.Pool, name string, audit auditFunc) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the tag keeps it from being added to further devices.
+	var tagID int
+	err = tx.QueryRow(ctx, `SELECT id FROM tag WHERE name = $1 FOR UPDATE`, strings.ToLower(strings.TrimSpace(name))).Scan(&tagID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+DeviceColumns+` FROM device
+		WHERE id IN (SELECT device_id FROM device_tag WHERE tag_id = $1)
+		ORDER BY id
+		FOR UPDATE
+	`, tagID)
+	if err != nil {
+		return err
+	}
+
+	before, err := pgx.CollectRows(rows, pgx.RowToStructByName[Device])
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM tag WHERE id = $1`, tagID); err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(before))
+	previous := make(map[int]*Device, len(before))
+	for i := range before {
+		ids = append(ids, before[i].ID)
+		previous[before[i].ID] = &before[i]
+	}
+
+	rows, err = tx.Query(ctx, `UPDATE device SET updated_at = NOW() WHERE id = ANY($1) RETURNING `+DeviceColumns, ids)
+	if err != nil {
+		return err
+	}
+
+	after, err := pgx.CollectRows(rows, pgx.RowToStructByName[Device])
+	if err != nil {
+		return err
+	}
+
+	for i := range after {
+		if err := audit.record(tx, previous[after[i].ID], &after[i]); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
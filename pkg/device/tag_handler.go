@@ -0,0 +1,120 @@
+package device
+
+import (
+	"dmt/pkg/audit"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AddDeviceTags adds the tags of the body to a device. Adding a tag the device
+// already carries is no error.
+func (s *DeviceHandler) AddDeviceTags(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Errorf("Invalid device ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid device ID",
+		})
+	}
+
+	var requestBody struct {
+		Tags []string `json:"tags"`
+	}
+	err = c.BodyParser(&requestBody)
+	if err != nil {
+		log.Errorf("Failed to parse tags: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	device := &Device{ID: id}
+	err = addDeviceTags(c.Context(), s.db, device, requestBody.Tags, audited(c, audit.ActionUpdate))
+	if err != nil {
+		log.Errorf("Failed to add tags: %s", err.Error())
+		return updateErrorResponse(c, err, "Failed to add tags")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Tags added successfully",
+		"device":  device,
+	})
+}
+
+func (s *DeviceHandler) RemoveDeviceTag(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Errorf("Invalid device ID: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid device ID",
+		})
+	}
+
+	device := &Device{ID: id}
+	err = removeDeviceTag(c.Context(), s.db, device, c.Params("tag"), audited(c, audit.ActionUpdate))
+	if err != nil {
+		log.Errorf("Failed to remove tag: %s", err.Error())
+		if errors.Is(err, ErrTagNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Tag not found on device",
+			})
+		}
+		return updateErrorResponse(c, err, "Failed to remove tag")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Tag removed successfully",
+		"device":  device,
+	})
+}
+
+type TagHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewTagHandler(db *pgxpool.Pool) *TagHandler {
+	return &TagHandler{db: db}
+}
+
+func (s *TagHandler) GetTags(c *fiber.Ctx) error {
+	tags, err := GetTags(c.Context(), s.db)
+	if err != nil {
+		log.Errorf("Failed to retrieve tags: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve tags",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"tags":  tags,
+		"count": len(tags),
+	})
+}
+
+// DeleteTag removes a tag from every device carrying it. Every device is
+// audited as updated.
+func (s *TagHandler) DeleteTag(c *fiber.Ctx) error {
+	err := deleteTag(c.Context(), s.db, c.Params("name"), audited(c, audit.ActionUpdate))
+	if err != nil {
+		log.Errorf("Failed to delete tag: %s", err.Error())
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Tag not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete tag",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Tag deleted successfully",
+	})
+}
@@ -15,4 +15,37 @@ type Device struct {
 	MAC         net.HardwareAddr `json:"mac" db:"mac"`
 	Description *string          `json:"description" db:"description"`
 	Employee    *string          `json:"employee" db:"employee"`
+	Attributes  map[string]any   `json:"attributes" db:"attributes"`
+	Tags        []string         `json:"tags" db:"tags"`
+}
+
+// DeviceUpdate holds the fields of a partial device update. Nil fields are left
+// untouched, fields pointing to an empty value are cleared. Attributes
+// replaces all attributes, while AttributesPatch is merged into them as JSON
+// Merge Patch.
+type DeviceUpdate struct {
+	Name            *string
+	Type            *string
+	IP              *net.IP
+	MAC             *net.HardwareAddr
+	Description     *string
+	Employee        *string
+	Attributes      *map[string]any
+	AttributesPatch map[string]any
+}
+
+// DeviceFilter narrows down device listings. Empty fields are ignored.
+type DeviceFilter struct {
+	Employee string `json:"employee"`
+	Type     string `json:"type"`
+	IP       string `json:"ip"`
+	MAC      string `json:"mac"`
+
+	Attributes []AttributeFilter `json:"attributes"`
+
+	// Devices must carry all of Tags, at least one of TagsAny and none of
+	// TagsNone.
+	Tags     []string `json:"tags"`
+	TagsAny  []string `json:"tags_any"`
+	TagsNone []string `json:"tags_none"`
 }
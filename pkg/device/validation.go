@@ -6,6 +6,10 @@ import (
 	"strings"
 )
 
+var ErrValidation = errors.New("validation failed")
+
+var errMACRequired = errors.New("mac is required")
+
 func validateName(name string) error {
 	if name == "" {
 		return errors.New("name is required")
@@ -16,13 +20,12 @@ func validateName(name string) error {
 	return nil
 }
 
-func validateType(deviceType string) error {
-	switch deviceType {
-	case "desktop", "laptop", "phone", "tablet":
-		return nil
-	default:
+// validateType checks the type against the device type catalog.
+func validateType(deviceType string, types map[string]DeviceType) error {
+	if _, ok := types[deviceType]; !ok {
 		return errors.New("invalid device type")
 	}
+	return nil
 }
 
 func validateIP(ip string) error {
@@ -33,6 +36,13 @@ func validateIP(ip string) error {
 	return nil
 }
 
+func validateMAC(mac net.HardwareAddr) error {
+	if len(mac) == 0 {
+		return errMACRequired
+	}
+	return nil
+}
+
 func validateDescription(description *string) error {
 	if description != nil && len(*description) > 500 {
 		return errors.New("description must be less than 500 characters")
@@ -47,14 +57,21 @@ func validateEmployee(employee *string) error {
 	return nil
 }
 
-func validateDevice(device *Device) []error {
+func validateDevice(device *Device, types map[string]DeviceType) []error {
 	errors := make([]error, 0, 5)
 
 	if err := validateName(device.Name); err != nil {
 		errors = append(errors, err)
 	}
 
-	if err := validateType(device.Type); err != nil {
+	if err := validateType(device.Type, types); err != nil {
+		errors = append(errors, err)
+	} else {
+		errors = append(errors, missingRequiredFields(device, types[device.Type])...)
+		errors = append(errors, attributeErrors(device, types[device.Type])...)
+	}
+
+	if err := validateMAC(device.MAC); err != nil {
 		errors = append(errors, err)
 	}
 
@@ -69,6 +86,42 @@ func validateDevice(device *Device) []error {
 	return errors
 }
 
+func validateDeviceUpdate(update *DeviceUpdate, types map[string]DeviceType) []error {
+	errors := make([]error, 0, 5)
+
+	if update.Name != nil {
+		if err := validateName(*update.Name); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if update.Type != nil {
+		if err := validateType(*update.Type, types); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if update.MAC != nil {
+		if err := validateMAC(*update.MAC); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if update.Description != nil && *update.Description != "" {
+		if err := validateDescription(update.Description); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if update.Employee != nil && *update.Employee != "" {
+		if err := validateEmployee(update.Employee); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	return errors
+}
+
 func sanitizeDevice(device *Device) {
 	device.Name = strings.TrimSpace(device.Name)
 	device.Type = strings.TrimSpace(device.Type)
@@ -86,4 +139,47 @@ func sanitizeDevice(device *Device) {
 			device.Employee = nil
 		}
 	}
+
+	if device.Attributes == nil {
+		device.Attributes = map[string]any{}
+	}
+}
+
+func sanitizeDeviceUpdate(update *DeviceUpdate) {
+	if update.Name != nil {
+		*update.Name = strings.TrimSpace(*update.Name)
+	}
+
+	if update.Type != nil {
+		*update.Type = strings.TrimSpace(*update.Type)
+	}
+
+	if update.Description != nil {
+		*update.Description = strings.TrimSpace(*update.Description)
+	}
+
+	if update.Employee != nil {
+		*update.Employee = strings.TrimSpace(*update.Employee)
+	}
+}
+
+// validationErrors holds every problem found with the input. It matches
+// ErrValidation as well as each of the problems.
+type validationErrors []error
+
+func (e validationErrors) Error() string {
+	message := ""
+	for _, err := range e {
+		message += err.Error() + "; "
+	}
+
+	return ErrValidation.Error() + ": " + message
+}
+
+func (e validationErrors) Unwrap() []error {
+	return append([]error{ErrValidation}, e...)
+}
+
+func validationError(errs []error) error {
+	return validationErrors(errs)
 }
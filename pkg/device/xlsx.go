@@ -0,0 +1,120 @@
+package device
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// xlsxWriter streams a workbook with a single sheet. Rows are written to the
+// zip archive as they come, so the workbook is never held in memory. Strings
+// are stored inline, which keeps the package to the few parts Excel and
+// LibreOffice require.
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const xlsxSheetEnd = `</sheetData></worksheet>`
+
+func newXLSXWriter(w io.Writer, sheetName string) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, sheetName)},
+	}
+
+	for _, part := range parts {
+		writer, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(writer, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetStart); err != nil {
+		return nil, err
+	}
+
+	return &xlsxWriter{archive: archive, sheet: sheet}, nil
+}
+
+// WriteRow appends a row. Integers become numeric cells, everything else is
+// written as an inline string and nil as an empty cell. Inline strings are
+// never evaluated, so text starting like a formula stays text.
+func (x *xlsxWriter) WriteRow(values []any) error {
+	if _, err := io.WriteString(x.sheet, "<row>"); err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		var err error
+		switch v := value.(type) {
+		case nil:
+			_, err = io.WriteString(x.sheet, "<c/>")
+		case int:
+			_, err = io.WriteString(x.sheet, "<c><v>"+strconv.Itoa(v)+"</v></c>")
+		default:
+			if _, err = io.WriteString(x.sheet, `<c t="inlineStr"><is><t xml:space="preserve">`); err != nil {
+				return err
+			}
+			if err = xml.EscapeText(x.sheet, []byte(fmt.Sprint(v))); err != nil {
+				return err
+			}
+			_, err = io.WriteString(x.sheet, "</t></is></c>")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(x.sheet, "</row>")
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return x.archive.Close()
+}
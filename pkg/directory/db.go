@@ -0,0 +1,280 @@
+package directory
+
+import (
+	"context"
+	"dmt/pkg/employee"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const uniqueViolationCode = "23505"
+
+// syncLockID serializes sync runs of all instances, manual runs included.
+const syncLockID = 0x646d7473
+
+// ErrEmptyDirectory is returned if a source lists no users at all. This is
+// most likely a misconfigured filter, so nobody is deactivated.
+var ErrEmptyDirectory = errors.New("directory returned no users")
+
+const syncColumns = "id, started_at, finished_at, source, status, created, updated, deactivated, skipped, error"
+
+// Run pulls the users of the source into the employee directory and records
+// the run. Users are created or updated, and employees of the same source
+// missing from the directory are deactivated. Users are applied one by one,
+// so invalid users are skipped and reported without failing the run.
+//
+// Employees returning to the directory are not reactivated, as they may have
+// been offboarded on purpose.
+func Run(ctx context.Context, db *pgxpool.Pool, source Source) (*Sync, error) {
+	sync := &Sync{
+		StartedAt: time.Now(),
+		Source:    source.Name(),
+		Status:    StatusSucceeded,
+	}
+
+	users, err := source.Users(ctx)
+	if err == nil {
+		err = applyUsers(ctx, db, sync, users)
+	}
+
+	sync.FinishedAt = time.Now()
+	if err != nil {
+		message := err.Error()
+		sync.Status = StatusFailed
+		sync.Error = &message
+		// Nothing was applied, the transaction was rolled back.
+		sync.Created, sync.Updated, sync.Deactivated, sync.Skipped = 0, 0, 0, 0
+		sync.DeactivatedEmployees, sync.SkippedUsers = nil, nil
+	}
+
+	if recordErr := recordSync(ctx, db, sync); recordErr != nil {
+		return sync, errors.Join(err, fmt.Errorf("failed to record sync: %w", recordErr))
+	}
+
+	return sync, err
+}
+
+func applyUsers(ctx context.Context, db *pgxpool.Pool, sync *Sync, users []User) error {
+	if len(users) == 0 {
+		return ErrEmptyDirectory
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", syncLockID); err != nil {
+		return err
+	}
+
+	// Everybody listed stays active, even if the entry itself is skipped.
+	listed := make([]string, 0, len(users))
+	seen := make(map[string]bool, len(users))
+
+	for _, user := range users {
+		listed = append(listed, user.Abbreviation)
+
+		if seen[user.Abbreviation] {
+			sync.skip(user, "duplicate abbreviation")
+			continue
+		}
+		seen[user.Abbreviation] = true
+
+		e := &employee.Employee{
+			Abbreviation: user.Abbreviation,
+			Name:         user.Name,
+			Email:        user.Email,
+			Department:   user.Department,
+			Source:       sync.Source,
+		}
+		if err := employee.Validate(e); err != nil {
+			sync.skip(user, err.Error())
+			continue
+		}
+
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return err
+		}
+
+		inserted, changed, err := upsertEmployee(ctx, savepoint, e)
+
+		var pgErr *pgconn.PgError
+		switch {
+		case err == nil:
+			if err := savepoint.Commit(ctx); err != nil {
+				return err
+			}
+		case errors.Is(err, errOtherSource):
+			if err := savepoint.Rollback(ctx); err != nil {
+				return err
+			}
+			sync.skip(user, err.Error())
+			continue
+		case errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode:
+			if err := savepoint.Rollback(ctx); err != nil {
+				return err
+			}
+			sync.skip(user, "email address is already used by another employee")
+			continue
+		default:
+			return err
+		}
+
+		switch {
+		case inserted:
+			sync.Created++
+		case changed:
+			sync.Updated++
+		}
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE employee SET active = FALSE, updated_at = NOW()
+		WHERE source = $1 AND active AND NOT (abbreviation = ANY($2))
+		RETURNING abbreviation
+	`, sync.Source, listed)
+	if err != nil {
+		return err
+	}
+
+	deactivated, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	sync.DeactivatedEmployees = deactivated
+	sync.Deactivated = len(deactivated)
+
+	return tx.Commit(ctx)
+}
+
+// errOtherSource is returned by upsertEmployee for employees maintained by
+// another source, e.g. created through the API or provisioned via SCIM.
+var errOtherSource = errors.New("employee is maintained by another source")
+
+// upsertEmployee creates the employee or updates it if it belongs to the same
+// source, leaving its active flag untouched. Rows are only written if
+// something changed.
+func upsertEmployee(ctx context.Context, tx pgx.Tx, e *employee.Employee) (inserted bool, changed bool, err error) {
+	var source string
+	err = tx.QueryRow(ctx, `SELECT source FROM employee WHERE abbreviation = $1 FOR UPDATE`, e.Abbreviation).Scan(&source)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return false, false, err
+	case source != e.Source:
+		return false, false, fmt.Errorf("%w: %s", errOtherSource, source)
+	}
+
+	query := `
+	INSERT INTO employee (abbreviation, name, email, department, source)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (abbreviation) DO UPDATE SET
+			name = EXCLUDED.name,
+			email = EXCLUDED.email,
+			department = EXCLUDED.department,
+			updated_at = NOW()
+		WHERE employee.source = EXCLUDED.source
+			AND (employee.name, employee.email, employee.department)
+				IS DISTINCT FROM (EXCLUDED.name, EXCLUDED.email, EXCLUDED.department)
+		RETURNING xmax = 0`
+
+	err = tx.QueryRow(ctx, query, e.Abbreviation, e.Name, e.Email, e.Department, e.Source).Scan(&inserted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	return inserted, !inserted, nil
+}
+
+func (s *Sync) skip(user User, reason string) {
+	s.Skipped++
+	s.SkippedUsers = append(s.SkippedUsers, SkippedUser{
+		ID:           user.ID,
+		Abbreviation: user.Abbreviation,
+		Reason:       reason,
+	})
+}
+
+func recordSync(ctx context.Context, db *pgxpool.Pool, sync *Sync) error {
+	query := `
+	INSERT INTO directory_sync (started_at, finished_at, source, status, created, updated, deactivated, skipped, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return db.QueryRow(ctx, query,
+		sync.StartedAt,
+		sync.FinishedAt,
+		sync.Source,
+		sync.Status,
+		sync.Created,
+		sync.Updated,
+		sync.Deactivated,
+		sync.Skipped,
+		sync.Error,
+	).Scan(&sync.ID)
+}
+
+// GetLastSync returns the latest run of the source.
+func GetLastSync(ctx context.Context, db *pgxpool.Pool, source string, sync *Sync) error {
+	query := `SELECT ` + syncColumns + ` FROM directory_sync WHERE source = $1 ORDER BY started_at DESC, id DESC LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, source)
+	if err != nil {
+		return err
+	}
+
+	found, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Sync])
+	if err != nil {
+		return err
+	}
+	*sync = found
+
+	return nil
+}
+
+// GetOrphanedDevices returns the devices still assigned to deactivated
+// employees, which have to be collected or reassigned.
+func GetOrphanedDevices(ctx context.Context, db *pgxpool.Pool) ([]OrphanedDevice, error) {
+	query := `
+	SELECT device.id AS device_id, device.name, device.type, device.employee,
+			employee.name AS employee_name, employee.updated_at AS inactive_since
+		FROM device
+		JOIN employee ON employee.abbreviation = device.employee
+		WHERE NOT employee.active
+		ORDER BY device.employee, device.id`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := pgx.CollectRows(rows, pgx.RowToStructByName[OrphanedDevice])
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
@@ -0,0 +1,89 @@
+package directory
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DirectoryHandler struct {
+	db     *pgxpool.Pool
+	syncer *Syncer
+}
+
+// NewDirectoryHandler creates the handler, syncer is nil if no directory is
+// configured.
+func NewDirectoryHandler(db *pgxpool.Pool, syncer *Syncer) *DirectoryHandler {
+	return &DirectoryHandler{db: db, syncer: syncer}
+}
+
+func notConfigured(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error": "Directory sync is not configured",
+	})
+}
+
+// GetSync reports the latest sync run, null before the first run.
+func (s *DirectoryHandler) GetSync(c *fiber.Ctx) error {
+	if s.syncer == nil {
+		return notConfigured(c)
+	}
+
+	sync := &Sync{}
+	err := GetLastSync(c.Context(), s.db, s.syncer.Source(), sync)
+	if errors.Is(err, pgx.ErrNoRows) {
+		sync = nil
+	} else if err != nil {
+		log.Errorf("Failed to get directory sync: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get directory sync",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"source":    s.syncer.Source(),
+		"last_sync": sync,
+	})
+}
+
+// RunSync syncs the directory immediately and reports the run including the
+// skipped users and deactivated employees.
+func (s *DirectoryHandler) RunSync(c *fiber.Ctx) error {
+	if s.syncer == nil {
+		return notConfigured(c)
+	}
+
+	sync, err := s.syncer.SyncNow(c.Context())
+	if err != nil {
+		log.Errorf("Directory sync failed: %s", err.Error())
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Directory sync failed",
+			"sync":  sync,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Directory synced successfully",
+		"sync":    sync,
+	})
+}
+
+// GetOrphanedDevices lists the devices still assigned to deactivated
+// employees, whether they were deactivated by a sync or offboarded.
+func (s *DirectoryHandler) GetOrphanedDevices(c *fiber.Ctx) error {
+	devices, err := GetOrphanedDevices(c.Context(), s.db)
+	if err != nil {
+		log.Errorf("Failed to get orphaned devices: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get orphaned devices",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"devices": devices,
+		"count":   len(devices),
+	})
+}
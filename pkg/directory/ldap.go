@@ -0,0 +1,112 @@
+package directory
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const LDAPSourceName = "ldap"
+
+// ldapPageSize is the page size of searches, below the common server limit of
+// 1000 entries.
+const ldapPageSize = 500
+
+type LDAPConfig struct {
+	URL          string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	Filter       string
+
+	// The attributes the employee fields are read from.
+	AbbreviationAttribute string
+	NameAttribute         string
+	EmailAttribute        string
+	DepartmentAttribute   string
+}
+
+// LDAPSource lists the users matching the filter below the base DN.
+type LDAPSource struct {
+	config LDAPConfig
+}
+
+func NewLDAPSource(config LDAPConfig) *LDAPSource {
+	return &LDAPSource{config: config}
+}
+
+func (s *LDAPSource) Name() string {
+	return LDAPSourceName
+}
+
+func (s *LDAPSource) Users(ctx context.Context) ([]User, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	conn, err := ldap.DialURL(s.config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	defer conn.Close()
+
+	// The client has no context support, closing the connection aborts
+	// pending requests.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetTimeout(time.Until(deadline))
+	}
+
+	if s.config.BindDN != "" {
+		if err := conn.Bind(s.config.BindDN, s.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("failed to bind to LDAP server: %w", err)
+		}
+	}
+
+	request := ldap.NewSearchRequest(
+		s.config.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		s.config.Filter,
+		[]string{
+			s.config.AbbreviationAttribute,
+			s.config.NameAttribute,
+			s.config.EmailAttribute,
+			s.config.DepartmentAttribute,
+		},
+		nil,
+	)
+
+	result, err := conn.SearchWithPaging(request, ldapPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP server: %w", err)
+	}
+
+	users := make([]User, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		users = append(users, User{
+			ID:           entry.DN,
+			Abbreviation: strings.TrimSpace(entry.GetEqualFoldAttributeValue(s.config.AbbreviationAttribute)),
+			Name:         entry.GetEqualFoldAttributeValue(s.config.NameAttribute),
+			Email:        optional(entry.GetEqualFoldAttributeValue(s.config.EmailAttribute)),
+			Department:   optional(entry.GetEqualFoldAttributeValue(s.config.DepartmentAttribute)),
+		})
+	}
+
+	return users, nil
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
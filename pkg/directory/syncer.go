@@ -0,0 +1,97 @@
+package directory
+
+import (
+	"context"
+	"dmt/pkg/leader"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// syncLeaderLockID is the advisory lock electing the instance that runs the
+// periodic sync.
+const syncLeaderLockID = 0x646d7464
+
+var DefaultLeaderConfig = leader.Config{
+	LockID:        syncLeaderLockID,
+	RetryInterval: 30 * time.Second,
+	Heartbeat:     5 * time.Second,
+}
+
+// Syncer periodically syncs a directory into the employee directory. Only the
+// elected leader among all instances runs the periodic sync, while manual runs
+// are accepted by every instance.
+type Syncer struct {
+	db       *pgxpool.Pool
+	source   Source
+	interval time.Duration
+	elector  *leader.Elector
+}
+
+func NewSyncer(db *pgxpool.Pool, source Source, interval time.Duration, instance string) *Syncer {
+	return &Syncer{
+		db:       db,
+		source:   source,
+		interval: interval,
+		elector:  leader.NewElector(db, instance, DefaultLeaderConfig),
+	}
+}
+
+// Run campaigns for leadership and syncs once per interval while this
+// instance is the leader, starting right after it became leader.
+func (s *Syncer) Run(ctx context.Context) {
+	s.elector.Run(ctx, s.run)
+}
+
+func (s *Syncer) run(ctx context.Context) {
+	defer log.Info("Directory sync stopped")
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.syncAndLog(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Syncer) syncAndLog(ctx context.Context) {
+	sync, err := s.SyncNow(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Errorf("Directory sync from %s failed: %v", s.source.Name(), err)
+		}
+		return
+	}
+
+	log.Infof("Directory sync from %s: %d created, %d updated, %d deactivated, %d skipped",
+		sync.Source, sync.Created, sync.Updated, sync.Deactivated, sync.Skipped)
+
+	if sync.Deactivated == 0 {
+		return
+	}
+
+	devices, err := GetOrphanedDevices(ctx, s.db)
+	if err != nil {
+		log.Errorf("Failed to get devices of deactivated employees: %v", err)
+		return
+	}
+	if len(devices) > 0 {
+		log.Warnf("%d devices are still assigned to deactivated employees", len(devices))
+	}
+}
+
+// SyncNow runs a sync immediately.
+func (s *Syncer) SyncNow(ctx context.Context) (*Sync, error) {
+	return Run(ctx, s.db, s.source)
+}
+
+func (s *Syncer) Source() string {
+	return s.source.Name()
+}
@@ -0,0 +1,62 @@
+package directory
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// User is an employee as listed by a directory. ID identifies the entry in
+// the directory, e.g. the DN of an LDAP entry.
+type User struct {
+	ID           string
+	Abbreviation string
+	Name         string
+	Email        *string
+	Department   *string
+}
+
+// Source lists the current employees of a directory. Name is stored as the
+// source of the synced employees, so it must not change between runs.
+type Source interface {
+	Name() string
+	Users(ctx context.Context) ([]User, error)
+}
+
+// Sync is a single run of a directory sync.
+type Sync struct {
+	ID          int       `json:"id" db:"id"`
+	StartedAt   time.Time `json:"started_at" db:"started_at"`
+	FinishedAt  time.Time `json:"finished_at" db:"finished_at"`
+	Source      string    `json:"source" db:"source"`
+	Status      string    `json:"status" db:"status"`
+	Created     int       `json:"created" db:"created"`
+	Updated     int       `json:"updated" db:"updated"`
+	Deactivated int       `json:"deactivated" db:"deactivated"`
+	Skipped     int       `json:"skipped" db:"skipped"`
+	Error       *string   `json:"error" db:"error"`
+
+	// The details are only reported by the run itself.
+	DeactivatedEmployees []string      `json:"deactivated_employees,omitempty" db:"-"`
+	SkippedUsers         []SkippedUser `json:"skipped_users,omitempty" db:"-"`
+}
+
+type SkippedUser struct {
+	ID           string `json:"id"`
+	Abbreviation string `json:"abbreviation"`
+	Reason       string `json:"reason"`
+}
+
+// OrphanedDevice is a device still assigned to a deactivated employee.
+type OrphanedDevice struct {
+	DeviceID      int       `json:"device_id" db:"device_id"`
+	Name          string    `json:"name" db:"name"`
+	Type          string    `json:"type" db:"type"`
+	Employee      string    `json:"employee" db:"employee"`
+	EmployeeName  string    `json:"employee_name" db:"employee_name"`
+	InactiveSince time.Time `json:"inactive_since" db:"inactive_since"`
+}
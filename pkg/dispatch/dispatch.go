@@ -0,0 +1,39 @@
+// Package dispatch holds what the dispatchers of the notification outbox and
+// of the webhook deliveries share.
+package dispatch
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Config tunes a dispatcher polling a table of deliveries.
+type Config struct {
+	BatchSize    int
+	PollInterval time.Duration
+	Lease        time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+// RetryDelay returns the delay before the next attempt of a delivery that
+// failed attempts times.
+func (c Config) RetryDelay(attempts int) time.Duration {
+	return Backoff(c.BaseBackoff, c.MaxBackoff, attempts)
+}
+
+// Backoff doubles the delay with every attempt up to max and picks a random
+// delay from the upper half to spread out retries.
+func Backoff(base, max time.Duration, attempt int) time.Duration {
+	delay := max
+	if attempt < 20 {
+		delay = min(base<<(attempt-1), max)
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + rand.N(half)
+}
@@ -0,0 +1,56 @@
+package employee
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrUnknown  = errors.New("unknown employee")
+	ErrInactive = errors.New("inactive employee")
+)
+
+// Constraints named by the errors of the device_employee_check_trigger.
+const (
+	unknownConstraint  = "device_employee_fkey"
+	inactiveConstraint = "device_employee_active"
+)
+
+type assignmentError struct {
+	kind    error
+	message string
+}
+
+func (e *assignmentError) Error() string {
+	return e.message
+}
+
+func (e *assignmentError) Unwrap() error {
+	return e.kind
+}
+
+// AssignmentError translates the database errors raised when a device is
+// assigned to an unknown or inactive employee into errors matching ErrUnknown
+// or ErrInactive. Other errors are returned unchanged.
+func AssignmentError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.ConstraintName {
+	case unknownConstraint:
+		return &assignmentError{kind: ErrUnknown, message: pgErr.Message}
+	case inactiveConstraint:
+		return &assignmentError{kind: ErrInactive, message: pgErr.Message}
+	}
+
+	return err
+}
+
+// IsAssignmentError reports whether err rejected an assignment to an unknown
+// or inactive employee.
+func IsAssignmentError(err error) bool {
+	return errors.Is(err, ErrUnknown) || errors.Is(err, ErrInactive)
+}
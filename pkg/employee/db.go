@@ -0,0 +1,244 @@
+package employee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const uniqueViolationCode = "23505"
+
+const foreignKeyViolationCode = "23503"
+
+// SourceManual marks employees maintained through the API.
+const SourceManual = "manual"
+
+const employeeColumns = "abbreviation, created_at, updated_at, name, email, department, active, source"
+
+func InsertEmployee(ctx context.Context, db *pgxpool.Pool, employee *Employee) error {
+	if err := Validate(employee); err != nil {
+		return err
+	}
+
+	if employee.Source == "" {
+		employee.Source = SourceManual
+	}
+
+	query := `
+	INSERT INTO employee (abbreviation, name, email, department, active, source)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + employeeColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query,
+		employee.Abbreviation,
+		employee.Name,
+		employee.Email,
+		employee.Department,
+		employee.Active,
+		employee.Source,
+	)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Employee])
+	if err != nil {
+		return err
+	}
+	*employee = inserted
+
+	return nil
+}
+
+// UpdateEmployee applies the supplied fields of update to the employee
+// identified by employee.Abbreviation and scans the resulting row back into
+// employee.
+func UpdateEmployee(ctx context.Context, db *pgxpool.Pool, employee *Employee, update *EmployeeUpdate) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return updateEmployee(ctx, db, employee, update)
+}
+
+// UpdateEmployeeTx is UpdateEmployee as part of the transaction tx.
+func UpdateEmployeeTx(ctx context.Context, tx pgx.Tx, employee *Employee, update *EmployeeUpdate) error {
+	return updateEmployee(ctx, tx, employee, update)
+}
+
+// querier is implemented by the pool as well as by transactions.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func updateEmployee(ctx context.Context, db querier, employee *Employee, update *EmployeeUpdate) error {
+	sanitizeEmployeeUpdate(update)
+
+	if validationErrors := validateEmployeeUpdate(update); len(validationErrors) > 0 {
+		return validationError(validationErrors)
+	}
+
+	args := []interface{}{}
+	sqlChunk := []string{}
+
+	if update.Name != nil {
+		args = append(args, *update.Name)
+		sqlChunk = append(sqlChunk, fmt.Sprintf("name = $%d", len(args)))
+	}
+
+	if update.Email != nil {
+		if *update.Email != "" {
+			args = append(args, *update.Email)
+			sqlChunk = append(sqlChunk, fmt.Sprintf("email = $%d", len(args)))
+		} else {
+			sqlChunk = append(sqlChunk, "email = NULL")
+		}
+	}
+
+	if update.Department != nil {
+		if *update.Department != "" {
+			args = append(args, *update.Department)
+			sqlChunk = append(sqlChunk, fmt.Sprintf("department = $%d", len(args)))
+		} else {
+			sqlChunk = append(sqlChunk, "department = NULL")
+		}
+	}
+
+	if update.Active != nil {
+		args = append(args, *update.Active)
+		sqlChunk = append(sqlChunk, fmt.Sprintf("active = $%d", len(args)))
+	}
+
+	if len(sqlChunk) == 0 {
+		return validationError([]error{errors.New("no update options provided")})
+	}
+
+	sqlChunk = append(sqlChunk, "updated_at = NOW()")
+
+	args = append(args, employee.Abbreviation)
+	query := fmt.Sprintf("UPDATE employee SET %s WHERE abbreviation = $%d RETURNING %s",
+		strings.Join(sqlChunk, ", "), len(args), employeeColumns)
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Employee])
+	if err != nil {
+		return err
+	}
+	*employee = updated
+
+	return nil
+}
+
+// DeleteEmployee removes an employee without devices. It returns
+// pgx.ErrNoRows if the employee does not exist.
+func DeleteEmployee(ctx context.Context, db *pgxpool.Pool, employee *Employee) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := db.Exec(ctx, `DELETE FROM employee WHERE abbreviation = $1`, employee.Abbreviation)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func GetEmployeeByAbbreviation(ctx context.Context, db *pgxpool.Pool, employee *Employee) error {
+	query := `SELECT ` + employeeColumns + ` FROM employee WHERE abbreviation = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, employee.Abbreviation)
+	if err != nil {
+		return err
+	}
+
+	found, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Employee])
+	if err != nil {
+		return err
+	}
+	*employee = found
+
+	return nil
+}
+
+func GetEmployees(ctx context.Context, db *pgxpool.Pool, filter *Filter) ([]Employee, error) {
+	conditions, args := filter.conditions()
+	query := `SELECT ` + employeeColumns + ` FROM employee WHERE 1=1` + conditions + ` ORDER BY abbreviation`
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	employees, err := pgx.CollectRows(rows, pgx.RowToStructByName[Employee])
+	if err != nil {
+		return nil, err
+	}
+
+	return employees, nil
+}
+
+// CountEmployees counts the employees matching the filter, ignoring its limit
+// and offset.
+func CountEmployees(ctx context.Context, db *pgxpool.Pool, filter *Filter) (int, error) {
+	conditions, args := filter.conditions()
+	query := `SELECT COUNT(*) FROM employee WHERE 1=1` + conditions
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int
+	err := db.QueryRow(ctx, query, args...).Scan(&count)
+	return count, err
+}
+
+func (f *Filter) conditions() (string, []interface{}) {
+	query := ""
+	args := []interface{}{}
+
+	if f.Active != nil {
+		args = append(args, *f.Active)
+		query += fmt.Sprintf(" AND active = $%d", len(args))
+	}
+
+	if f.Department != "" {
+		args = append(args, f.Department)
+		query += fmt.Sprintf(" AND department = $%d", len(args))
+	}
+
+	if f.Source != "" {
+		args = append(args, f.Source)
+		query += fmt.Sprintf(" AND source = $%d", len(args))
+	}
+
+	return query, args
+}
@@ -0,0 +1,201 @@
+package employee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type EmployeeHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewEmployeeHandler(db *pgxpool.Pool) *EmployeeHandler {
+	return &EmployeeHandler{db: db}
+}
+
+func errorResponse(c *fiber.Ctx, err error, message string) error {
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.Is(err, ErrValidation):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, pgx.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Employee not found",
+		})
+	case errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Employee with this abbreviation or email already exists",
+		})
+	case errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Employee still has devices assigned",
+		})
+	default:
+		log.Errorf("%s: %s", message, err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+}
+
+func (s *EmployeeHandler) CreateEmployee(c *fiber.Ctx) error {
+	var requestBody struct {
+		Abbreviation string  `json:"abbreviation"`
+		Name         string  `json:"name"`
+		Email        *string `json:"email"`
+		Department   *string `json:"department"`
+		Active       *bool   `json:"active"`
+	}
+	err := c.BodyParser(&requestBody)
+	if err != nil {
+		log.Errorf("Invalid JSON format: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	employee := &Employee{
+		Abbreviation: requestBody.Abbreviation,
+		Name:         requestBody.Name,
+		Email:        requestBody.Email,
+		Department:   requestBody.Department,
+		Active:       requestBody.Active == nil || *requestBody.Active,
+	}
+
+	if err := InsertEmployee(c.Context(), s.db, employee); err != nil {
+		return errorResponse(c, err, "Failed to create employee")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":  "Employee created successfully",
+		"employee": employee,
+	})
+}
+
+func (s *EmployeeHandler) GetEmployees(c *fiber.Ctx) error {
+	filter := &Filter{
+		Department: c.Query("department"),
+		Source:     c.Query("source"),
+	}
+
+	if active := c.Query("active"); active != "" {
+		value, err := strconv.ParseBool(active)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "active must be true or false",
+			})
+		}
+		filter.Active = &value
+	}
+
+	employees, err := GetEmployees(c.Context(), s.db, filter)
+	if err != nil {
+		log.Errorf("Failed to retrieve employees: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve employees",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"employees": employees,
+		"count":     len(employees),
+	})
+}
+
+func (s *EmployeeHandler) GetEmployee(c *fiber.Ctx) error {
+	employee := &Employee{Abbreviation: c.Params("abbr")}
+	if err := GetEmployeeByAbbreviation(c.Context(), s.db, employee); err != nil {
+		return errorResponse(c, err, "Failed to retrieve employee")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(employee)
+}
+
+// PatchEmployee applies a JSON Merge Patch (RFC 7396) to an employee. Email
+// and department are cleared by null, the abbreviation can't be changed.
+func (s *EmployeeHandler) PatchEmployee(c *fiber.Ctx) error {
+	update, err := parseEmployeePatch(c.Body())
+	if err != nil {
+		log.Errorf("Invalid merge patch: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	employee := &Employee{Abbreviation: c.Params("abbr")}
+	if err := UpdateEmployee(c.Context(), s.db, employee, update); err != nil {
+		return errorResponse(c, err, "Failed to update employee")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Employee updated successfully",
+		"employee": employee,
+	})
+}
+
+// DeleteEmployee removes an employee that has no devices. Employees who left
+// should be deactivated instead, which keeps them in the assignment history.
+func (s *EmployeeHandler) DeleteEmployee(c *fiber.Ctx) error {
+	employee := &Employee{Abbreviation: c.Params("abbr")}
+	if err := DeleteEmployee(c.Context(), s.db, employee); err != nil {
+		return errorResponse(c, err, "Failed to delete employee")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Employee deleted successfully",
+	})
+}
+
+func parseEmployeePatch(body []byte) (*EmployeeUpdate, error) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	update := &EmployeeUpdate{}
+	for field, value := range patch {
+		isNull := string(value) == "null"
+
+		var target any
+		switch field {
+		case "name":
+			update.Name = new(string)
+			target = update.Name
+		case "email":
+			update.Email = new(string)
+			target = update.Email
+		case "department":
+			update.Department = new(string)
+			target = update.Department
+		case "active":
+			if isNull {
+				return nil, errors.New("active can't be null")
+			}
+			update.Active = new(bool)
+			target = update.Active
+		default:
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+
+		if isNull {
+			continue
+		}
+
+		if err := json.Unmarshal(value, target); err != nil {
+			return nil, fmt.Errorf("invalid value for field %q", field)
+		}
+	}
+
+	return update, nil
+}
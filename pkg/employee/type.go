@@ -0,0 +1,35 @@
+package employee
+
+import "time"
+
+// Employee is a member of the employee directory. Source is "manual" for
+// employees maintained through the API, or the name of the directory the
+// employee is synced from.
+type Employee struct {
+	Abbreviation string    `json:"abbreviation" db:"abbreviation"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	Name         string    `json:"name" db:"name"`
+	Email        *string   `json:"email" db:"email"`
+	Department   *string   `json:"department" db:"department"`
+	Active       bool      `json:"active" db:"active"`
+	Source       string    `json:"source" db:"source"`
+}
+
+// EmployeeUpdate holds the fields of a partial employee update. Nil fields are
+// left untouched, optional fields pointing to an empty value are cleared.
+type EmployeeUpdate struct {
+	Name       *string
+	Email      *string
+	Department *string
+	Active     *bool
+}
+
+// Filter narrows down employee listings. Empty fields are ignored.
+type Filter struct {
+	Active     *bool
+	Department string
+	Source     string
+	Limit      int
+	Offset     int
+}
@@ -0,0 +1,135 @@
+package employee
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+var ErrValidation = errors.New("validation failed")
+
+func validateAbbreviation(abbreviation string) error {
+	if len(abbreviation) != 3 {
+		return errors.New("abbreviation must be 3 characters")
+	}
+	return nil
+}
+
+func validateName(name string) error {
+	if name == "" {
+		return errors.New("name is required")
+	}
+	if len(name) > 255 {
+		return errors.New("name must be less than 255 characters")
+	}
+	return nil
+}
+
+func validateEmail(email *string) error {
+	if email == nil || *email == "" {
+		return nil
+	}
+	if address, err := mail.ParseAddress(*email); err != nil || address.Address != *email {
+		return errors.New("invalid email address")
+	}
+	return nil
+}
+
+func validateDepartment(department *string) error {
+	if department != nil && len(*department) > 255 {
+		return errors.New("department must be less than 255 characters")
+	}
+	return nil
+}
+
+func sanitizeEmployee(employee *Employee) {
+	employee.Abbreviation = strings.TrimSpace(employee.Abbreviation)
+	employee.Name = strings.TrimSpace(employee.Name)
+	employee.Email = trimOptional(employee.Email)
+	employee.Department = trimOptional(employee.Department)
+}
+
+func sanitizeEmployeeUpdate(update *EmployeeUpdate) {
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		update.Name = &name
+	}
+	if update.Email != nil {
+		email := strings.TrimSpace(*update.Email)
+		update.Email = &email
+	}
+	if update.Department != nil {
+		department := strings.TrimSpace(*update.Department)
+		update.Department = &department
+	}
+}
+
+// trimOptional trims the value and drops it if nothing is left.
+func trimOptional(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// Validate sanitizes the employee and checks it the same way InsertEmployee
+// does, for callers writing employees themselves.
+func Validate(employee *Employee) error {
+	sanitizeEmployee(employee)
+
+	if validationErrors := validateEmployee(employee); len(validationErrors) > 0 {
+		return validationError(validationErrors)
+	}
+	return nil
+}
+
+func validateEmployee(employee *Employee) []error {
+	errs := []error{}
+
+	if err := validateAbbreviation(employee.Abbreviation); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateName(employee.Name); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateEmail(employee.Email); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateDepartment(employee.Department); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+func validateEmployeeUpdate(update *EmployeeUpdate) []error {
+	errs := []error{}
+
+	if update.Name != nil {
+		if err := validateName(*update.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validateEmail(update.Email); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateDepartment(update.Department); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+func validationError(validationErrors []error) error {
+	message := ""
+	for _, err := range validationErrors {
+		message += err.Error() + "; "
+	}
+
+	return fmt.Errorf("%w: %s", ErrValidation, message)
+}
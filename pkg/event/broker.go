@@ -0,0 +1,181 @@
+package event
+
+import (
+	"context"
+	"dmt/pkg/leader"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type BrokerConfig struct {
+	// Retention is how long events are kept for clients resuming a stream.
+	Retention     time.Duration
+	PruneInterval time.Duration
+	RetryInterval time.Duration
+}
+
+var DefaultBrokerConfig = BrokerConfig{
+	Retention:     7 * 24 * time.Hour,
+	PruneInterval: time.Hour,
+	RetryInterval: 5 * time.Second,
+}
+
+// pruneLeaderLockID is the advisory lock electing the instance that prunes
+// old events.
+const pruneLeaderLockID = 0x646d7470
+
+var DefaultLeaderConfig = leader.Config{
+	LockID:        pruneLeaderLockID,
+	RetryInterval: time.Minute,
+	Heartbeat:     5 * time.Second,
+}
+
+// Broker listens on the device_event channel and signals every subscribed
+// stream that new events are available. Streams read the events themselves,
+// so the channel payload is never relied upon and a missed signal only delays
+// a stream until its next poll. Every instance runs a broker for its own
+// streams, but only the elected leader among them prunes old events.
+type Broker struct {
+	db      *pgxpool.Pool
+	config  BrokerConfig
+	elector *leader.Elector
+
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	done        chan struct{}
+}
+
+func NewBroker(db *pgxpool.Pool, config BrokerConfig, instance string) *Broker {
+	return &Broker{
+		db:          db,
+		config:      config,
+		elector:     leader.NewElector(db, instance, DefaultLeaderConfig),
+		subscribers: make(map[chan struct{}]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Subscribe registers a stream. The returned channel receives a signal when
+// new events arrive and is closed when the broker stops.
+func (b *Broker) Subscribe() (<-chan struct{}, func()) {
+	signal := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	select {
+	case <-b.done:
+		close(signal)
+	default:
+		b.subscribers[signal] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[signal]; ok {
+			delete(b.subscribers, signal)
+			close(signal)
+		}
+	}
+
+	return signal, unsubscribe
+}
+
+func (b *Broker) broadcast() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for signal := range b.subscribers {
+		select {
+		case signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *Broker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	close(b.done)
+	for signal := range b.subscribers {
+		delete(b.subscribers, signal)
+		close(signal)
+	}
+}
+
+// Run listens for events until the context is cancelled, reconnecting after
+// connection losses, and prunes old events while this instance is the leader.
+func (b *Broker) Run(ctx context.Context) {
+	defer log.Info("Event broker stopped")
+	defer b.stop()
+
+	go b.elector.Run(ctx, b.prune)
+
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Errorf("Event listener failed, retrying in %s: %v", b.config.RetryInterval, err)
+
+		// Streams catch up on anything missed while reconnecting
+		b.broadcast()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.config.RetryInterval):
+		}
+	}
+}
+
+func (b *Broker) listen(ctx context.Context) error {
+	conn, err := b.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection from pool: %w", err)
+	}
+	defer func() {
+		// The connection may still be listening, so it is not returned to the pool
+		_ = conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN device_event"); err != nil {
+		return fmt.Errorf("failed to listen on device_event: %w", err)
+	}
+
+	for {
+		_, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		b.broadcast()
+	}
+}
+
+func (b *Broker) prune(ctx context.Context) {
+	ticker := time.NewTicker(b.config.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pruned, err := PruneEvents(ctx, b.db, b.config.Retention)
+		if err != nil {
+			log.Errorf("Failed to prune events: %v", err)
+			continue
+		}
+		if pruned > 0 {
+			log.Infof("Pruned %d events", pruned)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const eventColumns = "id, created_at, type, device_id, data"
+
+// GetEventsAfter returns up to limit events following the given id in order.
+// The device_event trigger assigns ids in commit order, so no event becomes
+// visible behind an id a stream has already seen.
+func GetEventsAfter(ctx context.Context, db *pgxpool.Pool, id int64, limit int) ([]Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM device_event
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[Event])
+}
+
+// GetLatestEventID returns the id of the newest event, 0 if there is none.
+func GetLatestEventID(ctx context.Context, db *pgxpool.Pool) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var id int64
+	err := db.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM device_event`).Scan(&id)
+	return id, err
+}
+
+// PruneEvents deletes events older than the retention period.
+func PruneEvents(ctx context.Context, db *pgxpool.Pool, retention time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := db.Exec(ctx, `DELETE FROM device_event WHERE created_at < NOW() - $1::interval`, retention)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,142 @@
+package event
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+	streamBatchSize   = 100
+)
+
+type EventHandler struct {
+	db     *pgxpool.Pool
+	broker *Broker
+}
+
+// NewEventHandler creates the handler of the event stream. The broker may be
+// nil if this instance does not serve streams.
+func NewEventHandler(db *pgxpool.Pool, broker *Broker) *EventHandler {
+	return &EventHandler{db: db, broker: broker}
+}
+
+// Stream sends device events as Server-Sent Events. Clients resume after the
+// event given by the Last-Event-ID header or the last_event_id query
+// parameter, otherwise the stream starts with the next event.
+func (s *EventHandler) Stream(c *fiber.Ctx) error {
+	if s.broker == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Event stream is not available",
+		})
+	}
+
+	lastEventID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+
+	var lastID int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || id < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid Last-Event-ID",
+			})
+		}
+		lastID = id
+	} else {
+		id, err := GetLatestEventID(c.Context(), s.db)
+		if err != nil {
+			log.Errorf("Failed to retrieve latest event: %s", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to open event stream",
+			})
+		}
+		lastID = id
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	signal, unsubscribe := s.broker.Subscribe()
+
+	// The fiber.Ctx must not be used once the handler returned, so the stream
+	// only works on copies.
+	db := s.db
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ticker := time.NewTicker(keepAliveInterval)
+		defer ticker.Stop()
+
+		fmt.Fprintf(w, "retry: %d\n\n", 3000)
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			id, err := writeEvents(ctx, db, w, lastID)
+			lastID = id
+			if err != nil {
+				return
+			}
+
+			select {
+			case _, ok := <-signal:
+				if !ok {
+					return
+				}
+			case <-ticker.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeEvents writes all events after lastID to the stream and returns the id
+// of the last event written. Only write errors, i.e. a disconnected client,
+// are returned.
+func writeEvents(ctx context.Context, db *pgxpool.Pool, w *bufio.Writer, lastID int64) (int64, error) {
+	for {
+		events, err := GetEventsAfter(ctx, db, lastID, streamBatchSize)
+		if err != nil {
+			log.Errorf("Failed to retrieve events: %s", err.Error())
+			return lastID, nil
+		}
+
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Errorf("Failed to marshal event %d: %s", event.ID, err.Error())
+				continue
+			}
+
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			lastID = event.ID
+		}
+
+		if err := w.Flush(); err != nil {
+			return lastID, err
+		}
+
+		if len(events) < streamBatchSize {
+			return lastID, nil
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package event
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	TypeDeviceCreated    = "device.created"
+	TypeDeviceUpdated    = "device.updated"
+	TypeDeviceDeleted    = "device.deleted"
+	TypeDeviceAssigned   = "device.assigned"
+	TypeDeviceUnassigned = "device.unassigned"
+)
+
+// Event is a device change as recorded by the device_event trigger.
+type Event struct {
+	ID        int64           `json:"id" db:"id"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	Type      string          `json:"type" db:"type"`
+	DeviceID  int             `json:"device_id" db:"device_id"`
+	Data      json.RawMessage `json:"data" db:"data"`
+}
@@ -0,0 +1,507 @@
+// Package jsonschema validates decoded JSON values against a subset of JSON
+// Schema (draft 2020-12). Supported are the keywords most useful for flat
+// records: type, enum, const, properties, required, additionalProperties,
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf,
+// minLength, maxLength, pattern, items, minItems and maxItems. Annotations
+// like title and description are accepted and ignored, any other keyword is
+// rejected when compiling, so a schema never silently validates less than its
+// author expects.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var types = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+var annotations = []string{"$schema", "$id", "$comment", "title", "description", "default", "examples", "deprecated", "readOnly", "writeOnly"}
+
+// Schema is a compiled schema. The zero value accepts everything.
+type Schema struct {
+	types                []string
+	enum                 []any
+	constant             *any
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	noAdditional         bool
+	minimum              *float64
+	maximum              *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	multipleOf           *float64
+	minLength            *int
+	maxLength            *int
+	pattern              *regexp.Regexp
+	items                *Schema
+	minItems             *int
+	maxItems             *int
+}
+
+// Error is a violation of the schema by the value at Path. The path uses dots
+// for object members and brackets for array items.
+type Error struct {
+	Path    string
+	Message string
+}
+
+func (e Error) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Compile parses a schema given as JSON object or boolean.
+func Compile(data []byte) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if decoder.More() {
+		return nil, errors.New("invalid schema: trailing data")
+	}
+
+	return compile(raw, "")
+}
+
+func compile(raw any, path string) (*Schema, error) {
+	if allowed, ok := raw.(bool); ok {
+		if allowed {
+			return &Schema{}, nil
+		}
+		// false rejects every value.
+		return &Schema{types: []string{}}, nil
+	}
+
+	object, ok := raw.(map[string]any)
+	if !ok {
+		return nil, schemaError(path, "", "must be an object or boolean")
+	}
+
+	schema := &Schema{}
+
+	// Keywords are compiled in a fixed order so errors are deterministic.
+	keywords := make([]string, 0, len(object))
+	for keyword := range object {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		value := object[keyword]
+		var err error
+
+		switch keyword {
+		case "type":
+			schema.types, err = compileTypes(value)
+		case "enum":
+			values, ok := value.([]any)
+			if !ok || len(values) == 0 {
+				err = errors.New("must be a non-empty array")
+			}
+			schema.enum = values
+		case "const":
+			schema.constant = &value
+		case "properties":
+			schema.properties, err = compileProperties(value, path)
+		case "required":
+			schema.required, err = compileStrings(value)
+		case "additionalProperties":
+			if allowed, ok := value.(bool); ok {
+				schema.noAdditional = !allowed
+				break
+			}
+			schema.additionalProperties, err = compile(value, path+"/additionalProperties")
+		case "minimum":
+			schema.minimum, err = compileNumber(value)
+		case "maximum":
+			schema.maximum, err = compileNumber(value)
+		case "exclusiveMinimum":
+			schema.exclusiveMinimum, err = compileNumber(value)
+		case "exclusiveMaximum":
+			schema.exclusiveMaximum, err = compileNumber(value)
+		case "multipleOf":
+			schema.multipleOf, err = compileNumber(value)
+			if err == nil && *schema.multipleOf <= 0 {
+				err = errors.New("must be greater than 0")
+			}
+		case "minLength":
+			schema.minLength, err = compileCount(value)
+		case "maxLength":
+			schema.maxLength, err = compileCount(value)
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				err = errors.New("must be a string")
+				break
+			}
+			schema.pattern, err = regexp.Compile(pattern)
+		case "items":
+			schema.items, err = compile(value, path+"/items")
+		case "minItems":
+			schema.minItems, err = compileCount(value)
+		case "maxItems":
+			schema.maxItems, err = compileCount(value)
+		default:
+			if !slices.Contains(annotations, keyword) {
+				err = errors.New("is not supported")
+			}
+		}
+
+		if err != nil {
+			var nested *compileError
+			if errors.As(err, &nested) {
+				return nil, err
+			}
+			return nil, schemaError(path, keyword, err.Error())
+		}
+	}
+
+	return schema, nil
+}
+
+type compileError struct {
+	message string
+}
+
+func (e *compileError) Error() string {
+	return e.message
+}
+
+func schemaError(path string, keyword string, message string) error {
+	location := path + "/" + keyword
+	if keyword == "" {
+		location = path
+	}
+	if location == "" {
+		location = "/"
+	}
+	return &compileError{message: fmt.Sprintf("invalid schema: %s %s", location, message)}
+}
+
+func compileTypes(value any) ([]string, error) {
+	var names []string
+	switch v := value.(type) {
+	case string:
+		names = []string{v}
+	case []any:
+		for _, name := range v {
+			s, ok := name.(string)
+			if !ok {
+				return nil, errors.New("must be a string or an array of strings")
+			}
+			names = append(names, s)
+		}
+	default:
+		return nil, errors.New("must be a string or an array of strings")
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("must not be empty")
+	}
+
+	for i, name := range names {
+		if !slices.Contains(types, name) {
+			return nil, fmt.Errorf("has unknown type %q", name)
+		}
+		if slices.Contains(names[:i], name) {
+			return nil, fmt.Errorf("has duplicate type %q", name)
+		}
+	}
+
+	return names, nil
+}
+
+func compileProperties(value any, path string) (map[string]*Schema, error) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New("must be an object")
+	}
+
+	properties := make(map[string]*Schema, len(object))
+	for name, raw := range object {
+		property, err := compile(raw, path+"/properties/"+name)
+		if err != nil {
+			return nil, err
+		}
+		properties[name] = property
+	}
+
+	return properties, nil
+}
+
+func compileStrings(value any) ([]string, error) {
+	values, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("must be an array of strings")
+	}
+
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("must be an array of strings")
+		}
+		strs = append(strs, s)
+	}
+
+	return strs, nil
+}
+
+func compileNumber(value any) (*float64, error) {
+	n, ok := number(value)
+	if !ok {
+		return nil, errors.New("must be a number")
+	}
+	return &n, nil
+}
+
+func compileCount(value any) (*int, error) {
+	n, ok := number(value)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, errors.New("must be a non-negative integer")
+	}
+	count := int(n)
+	return &count, nil
+}
+
+// number converts the numeric types produced by encoding/json.
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// Validate checks a value decoded by encoding/json against the schema and
+// returns every violation found, with paths starting at root.
+func (s *Schema) Validate(root string, value any) []Error {
+	errs := []Error{}
+	s.validate(root, value, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, value any, errs *[]Error) {
+	add := func(format string, args ...any) {
+		*errs = append(*errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.types != nil && !slices.ContainsFunc(s.types, func(t string) bool { return isType(value, t) }) {
+		switch len(s.types) {
+		case 0:
+			add("is not allowed")
+		case 1:
+			add("must be of type %s", s.types[0])
+		default:
+			add("must be one of the types %s", strings.Join(s.types, ", "))
+		}
+		return
+	}
+
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(e any) bool { return equal(e, value) }) {
+		add("must be one of %s", joinValues(s.enum))
+	}
+
+	if s.constant != nil && !equal(*s.constant, value) {
+		add("must be %s", joinValues([]any{*s.constant}))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		s.validateObject(path, v, errs)
+	case []any:
+		s.validateArray(path, v, errs)
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			add("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			add("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			add("must match the pattern %s", s.pattern.String())
+		}
+	default:
+		n, ok := number(value)
+		if !ok {
+			return
+		}
+		if s.minimum != nil && n < *s.minimum {
+			add("must be at least %s", formatNumber(*s.minimum))
+		}
+		if s.maximum != nil && n > *s.maximum {
+			add("must be at most %s", formatNumber(*s.maximum))
+		}
+		if s.exclusiveMinimum != nil && n <= *s.exclusiveMinimum {
+			add("must be greater than %s", formatNumber(*s.exclusiveMinimum))
+		}
+		if s.exclusiveMaximum != nil && n >= *s.exclusiveMaximum {
+			add("must be less than %s", formatNumber(*s.exclusiveMaximum))
+		}
+		if s.multipleOf != nil && !isMultiple(n, *s.multipleOf) {
+			add("must be a multiple of %s", formatNumber(*s.multipleOf))
+		}
+	}
+}
+
+func (s *Schema) validateObject(path string, object map[string]any, errs *[]Error) {
+	for _, name := range s.required {
+		if _, ok := object[name]; !ok {
+			*errs = append(*errs, Error{Path: member(path, name), Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := s.properties[name]; ok {
+			property.validate(member(path, name), object[name], errs)
+			continue
+		}
+
+		switch {
+		case s.noAdditional:
+			*errs = append(*errs, Error{Path: member(path, name), Message: "is not allowed"})
+		case s.additionalProperties != nil:
+			s.additionalProperties.validate(member(path, name), object[name], errs)
+		}
+	}
+}
+
+func (s *Schema) validateArray(path string, array []any, errs *[]Error) {
+	if s.minItems != nil && len(array) < *s.minItems {
+		*errs = append(*errs, Error{Path: path, Message: fmt.Sprintf("must have at least %d items", *s.minItems)})
+	}
+	if s.maxItems != nil && len(array) > *s.maxItems {
+		*errs = append(*errs, Error{Path: path, Message: fmt.Sprintf("must have at most %d items", *s.maxItems)})
+	}
+
+	if s.items != nil {
+		for i, item := range array {
+			s.items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	}
+}
+
+func member(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func isType(value any, name string) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := number(value)
+		return ok
+	case "integer":
+		n, ok := number(value)
+		return ok && n == math.Trunc(n) && !math.IsInf(n, 0)
+	}
+	return false
+}
+
+// isMultiple reports whether n is a multiple of divisor. Both are compared by
+// their shortest decimal representation, as binary floats misjudge decimal
+// fractions like 0.07 and 0.01.
+func isMultiple(n float64, divisor float64) bool {
+	x, ok := new(big.Rat).SetString(strconv.FormatFloat(n, 'g', -1, 64))
+	if !ok {
+		return false
+	}
+	y, ok := new(big.Rat).SetString(strconv.FormatFloat(divisor, 'g', -1, 64))
+	if !ok || y.Sign() == 0 {
+		return false
+	}
+	return x.Quo(x, y).IsInt()
+}
+
+// equal compares two decoded JSON values, treating numbers by value.
+func equal(a any, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+
+	switch x := a.(type) {
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for name, value := range x {
+			other, ok := y[name]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a == b
+}
+
+func joinValues(values []any) string {
+	encoded := make([]string, 0, len(values))
+	for _, value := range values {
+		b, _ := json.Marshal(value)
+		encoded = append(encoded, string(b))
+	}
+	return strings.Join(encoded, ", ")
+}
+
+func formatNumber(n float64) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
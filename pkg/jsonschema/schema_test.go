@@ -0,0 +1,183 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{name: "Empty Schema", schema: `{}`},
+		{name: "Boolean Schemas", schema: `{"properties": {"a": true, "b": false}}`},
+		{name: "Annotations", schema: `{"$schema": "https://json-schema.org/draft/2020-12/schema", "$id": "x", "$comment": "c", "title": "t", "description": "d", "default": 1, "examples": [1], "deprecated": false, "readOnly": true, "writeOnly": false}`},
+		{name: "Every Keyword", schema: `{
+			"type": ["object", "null"],
+			"required": ["a"],
+			"additionalProperties": {"type": "string", "minLength": 1, "maxLength": 5, "pattern": "^x"},
+			"properties": {
+				"a": {"enum": [1, "a"], "const": 1, "minimum": 0, "maximum": 2, "exclusiveMinimum": -1, "exclusiveMaximum": 3, "multipleOf": 0.5},
+				"b": {"type": "array", "items": {"type": "integer"}, "minItems": 1, "maxItems": 3}
+			}
+		}`},
+
+		{name: "Invalid JSON", schema: `{"type":`, err: "invalid schema: unexpected EOF"},
+		{name: "Trailing Data", schema: `{} {}`, err: "invalid schema: trailing data"},
+		{name: "Not An Object", schema: `"string"`, err: "invalid schema: / must be an object or boolean"},
+		{name: "Unsupported Keyword", schema: `{"format": "email"}`, err: "invalid schema: /format is not supported"},
+		{name: "Unsupported Nested Keyword", schema: `{"properties": {"a": {"format": "email"}}}`, err: "invalid schema: /properties/a/format is not supported"},
+		{name: "Unsupported Keyword In Items", schema: `{"items": {"$ref": "#"}}`, err: "invalid schema: /items/$ref is not supported"},
+		{name: "Unsupported Keyword In Additional Properties", schema: `{"additionalProperties": {"allOf": []}}`, err: "invalid schema: /additionalProperties/allOf is not supported"},
+		{name: "Empty Type Array", schema: `{"type": []}`, err: "invalid schema: /type must not be empty"},
+		{name: "Duplicate Type", schema: `{"type": ["string", "string"]}`, err: `invalid schema: /type has duplicate type "string"`},
+		{name: "Unknown Type", schema: `{"type": "date"}`, err: `invalid schema: /type has unknown type "date"`},
+		{name: "Type Not A String", schema: `{"type": 1}`, err: "invalid schema: /type must be a string or an array of strings"},
+		{name: "Type Array Not Strings", schema: `{"type": [1]}`, err: "invalid schema: /type must be a string or an array of strings"},
+		{name: "Empty Enum", schema: `{"enum": []}`, err: "invalid schema: /enum must be a non-empty array"},
+		{name: "Enum Not An Array", schema: `{"enum": "a"}`, err: "invalid schema: /enum must be a non-empty array"},
+		{name: "Properties Not An Object", schema: `{"properties": []}`, err: "invalid schema: /properties must be an object"},
+		{name: "Property Not A Schema", schema: `{"properties": {"a": 1}}`, err: "invalid schema: /properties/a must be an object or boolean"},
+		{name: "Required Not Strings", schema: `{"required": [1]}`, err: "invalid schema: /required must be an array of strings"},
+		{name: "Minimum Not A Number", schema: `{"minimum": "1"}`, err: "invalid schema: /minimum must be a number"},
+		{name: "Maximum Not A Number", schema: `{"maximum": null}`, err: "invalid schema: /maximum must be a number"},
+		{name: "Exclusive Minimum Not A Number", schema: `{"exclusiveMinimum": true}`, err: "invalid schema: /exclusiveMinimum must be a number"},
+		{name: "Exclusive Maximum Not A Number", schema: `{"exclusiveMaximum": []}`, err: "invalid schema: /exclusiveMaximum must be a number"},
+		{name: "Zero Multiple Of", schema: `{"multipleOf": 0}`, err: "invalid schema: /multipleOf must be greater than 0"},
+		{name: "Negative Multiple Of", schema: `{"multipleOf": -2}`, err: "invalid schema: /multipleOf must be greater than 0"},
+		{name: "Negative Min Length", schema: `{"minLength": -1}`, err: "invalid schema: /minLength must be a non-negative integer"},
+		{name: "Fractional Max Length", schema: `{"maxLength": 1.5}`, err: "invalid schema: /maxLength must be a non-negative integer"},
+		{name: "Min Items Not A Number", schema: `{"minItems": "1"}`, err: "invalid schema: /minItems must be a non-negative integer"},
+		{name: "Negative Max Items", schema: `{"maxItems": -3}`, err: "invalid schema: /maxItems must be a non-negative integer"},
+		{name: "Pattern Not A String", schema: `{"pattern": 1}`, err: "invalid schema: /pattern must be a string"},
+		{name: "Invalid Pattern", schema: `{"pattern": "("}`, err: "invalid schema: /pattern error parsing regexp"},
+		{name: "Items Not A Schema", schema: `{"items": [{}]}`, err: "invalid schema: /items must be an object or boolean"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema, err := Compile([]byte(test.schema))
+			if test.err == "" {
+				require.NoError(t, err)
+				assert.NotNil(t, schema)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		errs   []string
+	}{
+		{name: "True Accepts Everything", schema: `true`, value: `{"a": [1]}`},
+		{name: "Empty Schema Accepts Everything", schema: `{}`, value: `"x"`},
+		{name: "False Rejects Everything", schema: `false`, value: `null`, errs: []string{"attributes: is not allowed"}},
+
+		{name: "Type Matches", schema: `{"type": "string"}`, value: `"x"`},
+		{name: "Type Mismatch", schema: `{"type": "string"}`, value: `1`, errs: []string{"attributes: must be of type string"}},
+		{name: "One Of Several Types", schema: `{"type": ["string", "null"]}`, value: `null`},
+		{name: "None Of Several Types", schema: `{"type": ["string", "null"]}`, value: `true`, errs: []string{"attributes: must be one of the types string, null"}},
+		{name: "Whole Number Is An Integer", schema: `{"type": "integer"}`, value: `2.0`},
+		{name: "Fraction Is No Integer", schema: `{"type": "integer"}`, value: `2.5`, errs: []string{"attributes: must be of type integer"}},
+		{name: "Integer Is A Number", schema: `{"type": "number"}`, value: `2`},
+		{name: "Boolean", schema: `{"type": "boolean"}`, value: `0`, errs: []string{"attributes: must be of type boolean"}},
+		{name: "Array", schema: `{"type": "array"}`, value: `{}`, errs: []string{"attributes: must be of type array"}},
+		{name: "Object", schema: `{"type": "object"}`, value: `[]`, errs: []string{"attributes: must be of type object"}},
+		{name: "Type Mismatch Skips Other Keywords", schema: `{"type": "string", "minimum": 5}`, value: `1`, errs: []string{"attributes: must be of type string"}},
+
+		{name: "Enum Matches", schema: `{"enum": ["a", 1, null]}`, value: `1.0`},
+		{name: "Enum Mismatch", schema: `{"enum": ["a", 1, null]}`, value: `"b"`, errs: []string{`attributes: must be one of "a", 1, null`}},
+		{name: "Enum Of Objects", schema: `{"enum": [{"a": [1, 2]}]}`, value: `{"a": [1, 2]}`},
+		{name: "Enum Of Objects Mismatch", schema: `{"enum": [{"a": [1, 2]}]}`, value: `{"a": [2, 1]}`, errs: []string{`attributes: must be one of {"a":[1,2]}`}},
+		{name: "Const Matches", schema: `{"const": {"a": 1}}`, value: `{"a": 1}`},
+		{name: "Const Mismatch", schema: `{"const": "a"}`, value: `"b"`, errs: []string{`attributes: must be "a"`}},
+		{name: "Const Is Not Loose", schema: `{"const": 1}`, value: `"1"`, errs: []string{`attributes: must be 1`}},
+
+		{name: "Properties", schema: `{"properties": {"a": {"type": "string"}, "b": {"type": "number"}}}`, value: `{"a": 1, "b": 2, "c": 3}`, errs: []string{"attributes.a: must be of type string"}},
+		{name: "Properties Ignore Other Types", schema: `{"properties": {"a": {"type": "string"}}}`, value: `[1]`},
+		{name: "Required", schema: `{"required": ["a", "b"]}`, value: `{"a": null}`, errs: []string{"attributes.b: is required"}},
+		{name: "No Additional Properties", schema: `{"properties": {"a": {}}, "additionalProperties": false}`, value: `{"a": 1, "c": 2, "b": 3}`, errs: []string{"attributes.b: is not allowed", "attributes.c: is not allowed"}},
+		{name: "Additional Properties Allowed", schema: `{"additionalProperties": true}`, value: `{"a": 1}`},
+		{name: "Additional Properties Schema", schema: `{"properties": {"a": {}}, "additionalProperties": {"type": "string"}}`, value: `{"a": 1, "b": "x", "c": 2}`, errs: []string{"attributes.c: must be of type string"}},
+
+		{name: "Minimum", schema: `{"minimum": 1}`, value: `1`},
+		{name: "Below Minimum", schema: `{"minimum": 1}`, value: `0.5`, errs: []string{"attributes: must be at least 1"}},
+		{name: "Maximum", schema: `{"maximum": 1.5}`, value: `1.5`},
+		{name: "Above Maximum", schema: `{"maximum": 1.5}`, value: `2`, errs: []string{"attributes: must be at most 1.5"}},
+		{name: "Exclusive Minimum", schema: `{"exclusiveMinimum": 1}`, value: `1`, errs: []string{"attributes: must be greater than 1"}},
+		{name: "Above Exclusive Minimum", schema: `{"exclusiveMinimum": 1}`, value: `1.01`},
+		{name: "Exclusive Maximum", schema: `{"exclusiveMaximum": 1}`, value: `1`, errs: []string{"attributes: must be less than 1"}},
+		{name: "Below Exclusive Maximum", schema: `{"exclusiveMaximum": 1}`, value: `-1`},
+		{name: "Number Keywords Ignore Strings", schema: `{"minimum": 10}`, value: `"5"`},
+
+		{name: "Multiple Of Integer", schema: `{"multipleOf": 3}`, value: `12`},
+		{name: "Not A Multiple Of Integer", schema: `{"multipleOf": 3}`, value: `10`, errs: []string{"attributes: must be a multiple of 3"}},
+		{name: "Multiple Of Decimal Fraction", schema: `{"multipleOf": 0.01}`, value: `0.07`},
+		{name: "Not A Multiple Of Decimal Fraction", schema: `{"multipleOf": 0.01}`, value: `0.075`, errs: []string{"attributes: must be a multiple of 0.01"}},
+		{name: "Large Multiple Of Decimal Fraction", schema: `{"multipleOf": 0.01}`, value: `12345678.91`},
+		{name: "Tiny Difference Is No Multiple", schema: `{"multipleOf": 1}`, value: `1.0000000001`, errs: []string{"attributes: must be a multiple of 1"}},
+		{name: "Multiple Of Fraction", schema: `{"multipleOf": 0.5}`, value: `-2.5`},
+		{name: "Zero Is A Multiple", schema: `{"multipleOf": 7}`, value: `0`},
+
+		{name: "Min Length", schema: `{"minLength": 2}`, value: `"a"`, errs: []string{"attributes: must be at least 2 characters"}},
+		{name: "Max Length Counts Characters", schema: `{"maxLength": 2}`, value: `"äö"`},
+		{name: "Above Max Length", schema: `{"maxLength": 2}`, value: `"abc"`, errs: []string{"attributes: must be at most 2 characters"}},
+		{name: "Pattern Is Not Anchored", schema: `{"pattern": "[0-9]+"}`, value: `"abc123"`},
+		{name: "Pattern Mismatch", schema: `{"pattern": "^[A-Z]{3}$"}`, value: `"abcd"`, errs: []string{"attributes: must match the pattern ^[A-Z]{3}$"}},
+		{name: "String Keywords Ignore Numbers", schema: `{"minLength": 5, "pattern": "x"}`, value: `1`},
+
+		{name: "Items", schema: `{"items": {"type": "integer"}}`, value: `[1, "2", 3.5]`, errs: []string{"attributes[1]: must be of type integer", "attributes[2]: must be of type integer"}},
+		{name: "Min Items", schema: `{"minItems": 2}`, value: `[1]`, errs: []string{"attributes: must have at least 2 items"}},
+		{name: "Max Items", schema: `{"maxItems": 1}`, value: `[1, 2]`, errs: []string{"attributes: must have at most 1 items"}},
+		{name: "Array Keywords Ignore Objects", schema: `{"minItems": 2}`, value: `{}`},
+
+		{name: "Nested Paths", schema: `{"properties": {"ports": {"items": {"properties": {"number": {"maximum": 65535}}}}}}`, value: `{"ports": [{"number": 80}, {"number": 70000}]}`, errs: []string{"attributes.ports[1].number: must be at most 65535"}},
+		{name: "Every Violation Is Reported", schema: `{"type": "object", "required": ["a"], "properties": {"b": {"type": "string", "minLength": 3, "pattern": "^x"}}}`, value: `{"b": "y"}`, errs: []string{"attributes.a: is required", "attributes.b: must be at least 3 characters", "attributes.b: must match the pattern ^x"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema, err := Compile([]byte(test.schema))
+			require.NoError(t, err)
+
+			var value any
+			require.NoError(t, json.Unmarshal([]byte(test.value), &value))
+
+			errs := []string{}
+			for _, err := range schema.Validate("attributes", value) {
+				errs = append(errs, err.Error())
+			}
+
+			if test.errs == nil {
+				test.errs = []string{}
+			}
+			assert.Equal(t, test.errs, errs)
+		})
+	}
+}
+
+func TestZeroSchemaAcceptsEverything(t *testing.T) {
+	var schema Schema
+	assert.Empty(t, schema.Validate("attributes", map[string]any{"a": 1}))
+}
+
+func TestValidateDecodedNumbers(t *testing.T) {
+	schema, err := Compile([]byte(`{"type": "integer", "minimum": 1, "multipleOf": 2}`))
+	require.NoError(t, err)
+
+	assert.Empty(t, schema.Validate("value", json.Number("4")))
+	assert.Empty(t, schema.Validate("value", 4))
+	assert.Empty(t, schema.Validate("value", int64(4)))
+	assert.Equal(t, []Error{{Path: "value", Message: "must be at least 1"}}, schema.Validate("value", json.Number("0")))
+}
@@ -0,0 +1,212 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const applicationNamePrefix = "dmt:"
+
+type Status struct {
+	Instance string     `json:"instance"`
+	IsLeader bool       `json:"is_leader"`
+	Leader   *string    `json:"leader"`
+	Since    *time.Time `json:"since"`
+}
+
+type Config struct {
+	// LockID identifies the advisory lock, every elected role needs its own.
+	LockID int64
+	// RetryInterval is how often followers try to take over leadership.
+	RetryInterval time.Duration
+	// Heartbeat is how often the leader checks that it still holds the lock.
+	Heartbeat time.Duration
+}
+
+// Elector elects a single leader among all instances sharing a database using
+// a session-level advisory lock. The lock is bound to a dedicated connection,
+// so it is released by Postgres as soon as the leader dies.
+type Elector struct {
+	db       *pgxpool.Pool
+	instance string
+	config   Config
+
+	mu    sync.RWMutex
+	since *time.Time
+}
+
+func NewElector(db *pgxpool.Pool, instance string, config Config) *Elector {
+	return &Elector{
+		db:       db,
+		instance: instance,
+		config:   config,
+	}
+}
+
+// Run campaigns for leadership until ctx is cancelled and calls lead whenever
+// this instance becomes leader. The context passed to lead is cancelled once
+// leadership is lost, and lead must return afterwards.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	defer log.Info("Leader election stopped")
+
+	for {
+		conn, err := e.tryAcquire(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("Leader election failed: %v", err)
+		}
+
+		if conn != nil {
+			e.hold(ctx, conn, lead)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.config.RetryInterval):
+		}
+	}
+}
+
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.since != nil
+}
+
+// Status reports whether this instance leads and which instance currently
+// holds the lock.
+func (e *Elector) Status(ctx context.Context) (Status, error) {
+	e.mu.RLock()
+	status := Status{
+		Instance: e.instance,
+		IsLeader: e.since != nil,
+		Since:    e.since,
+	}
+	e.mu.RUnlock()
+
+	leader, err := e.currentLeader(ctx)
+	if err != nil {
+		return status, err
+	}
+	status.Leader = leader
+
+	return status, nil
+}
+
+func (e *Elector) tryAcquire(ctx context.Context) (*pgxpool.Conn, error) {
+	conn, err := e.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection from pool: %w", err)
+	}
+
+	var acquired bool
+	err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", e.config.LockID).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Release()
+		return nil, err
+	}
+
+	_, err = conn.Exec(ctx, "SELECT set_config('application_name', $1, false)", applicationNamePrefix+e.instance)
+	if err != nil {
+		e.release(conn, true)
+		return nil, fmt.Errorf("failed to announce leadership: %w", err)
+	}
+
+	return conn, nil
+}
+
+func (e *Elector) hold(ctx context.Context, conn *pgxpool.Conn, lead func(ctx context.Context)) {
+	now := time.Now()
+	e.mu.Lock()
+	e.since = &now
+	e.mu.Unlock()
+
+	log.Infof("Instance %s became leader", e.instance)
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+
+	broken := false
+	ticker := time.NewTicker(e.config.Heartbeat)
+
+	for !broken && leadCtx.Err() == nil {
+		select {
+		case <-leadCtx.Done():
+		case <-done:
+			cancel()
+		case <-ticker.C:
+			if err := conn.Ping(leadCtx); err != nil && leadCtx.Err() == nil {
+				log.Errorf("Instance %s lost leadership: %v", e.instance, err)
+				broken = true
+			}
+		}
+	}
+
+	ticker.Stop()
+	cancel()
+	<-done
+
+	e.mu.Lock()
+	e.since = nil
+	e.mu.Unlock()
+
+	e.release(conn, broken)
+	log.Infof("Instance %s stepped down as leader", e.instance)
+}
+
+func (e *Elector) release(conn *pgxpool.Conn, broken bool) {
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if !broken {
+		_, err := conn.Exec(ctx, "SELECT pg_advisory_unlock($1), set_config('application_name', '', false)", e.config.LockID)
+		if err == nil {
+			return
+		}
+	}
+
+	// Closing the connection releases the lock and makes the pool discard it
+	_ = conn.Conn().Close(ctx)
+}
+
+func (e *Elector) currentLeader(ctx context.Context) (*string, error) {
+	query := `
+		SELECT activity.application_name
+		FROM pg_locks AS locks
+		JOIN pg_stat_activity AS activity ON activity.pid = locks.pid
+		WHERE locks.locktype = 'advisory'
+			AND locks.classid = ($1::bigint >> 32)::oid
+			AND locks.objid = ($1::bigint & 4294967295)::oid
+			AND locks.objsubid = 1
+			AND locks.granted
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var applicationName string
+	err := e.db.QueryRow(ctx, query, e.config.LockID).Scan(&applicationName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	leader := strings.TrimPrefix(applicationName, applicationNamePrefix)
+	return &leader, nil
+}
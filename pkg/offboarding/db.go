@@ -0,0 +1,325 @@
+package offboarding
+
+import (
+	"context"
+	"dmt/pkg/device"
+	"dmt/pkg/employee"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrAlreadyReturned = errors.New("device already returned")
+
+const offboardingColumns = "id, created_at, created_by, employee, reason, reassigned_to, completed_at"
+
+const itemColumns = "id, offboarding_id, device_id, name, type, ip, mac, returned_at, returned_by, note"
+
+// Offboard deactivates the employee and releases all their devices in a single
+// transaction. The devices are unassigned, or reassigned to
+// offboarding.ReassignedTo, and copied to the return checklist. The reason is
+// stored with the ended assignments. An offboarding without devices is
+// completed right away. It returns pgx.ErrNoRows if the employee does not
+// exist.
+func Offboard(ctx context.Context, db *pgxpool.Pool, offboarding *Offboarding) error {
+	return offboard(ctx, db, offboarding, nil)
+}
+
+// offboard is Offboard, auditing the released devices.
+func offboard(ctx context.Context, db *pgxpool.Pool, offboarding *Offboarding, audit AuditFunc) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	created := *offboarding
+	if err := OffboardTx(ctx, tx, &created); err != nil {
+		return err
+	}
+
+	if audit != nil {
+		if err := audit(tx, &created); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	*offboarding = created
+	return nil
+}
+
+// OffboardTx is Offboard as part of the transaction tx. The offboarding only
+// takes effect once tx is committed.
+func OffboardTx(ctx context.Context, tx pgx.Tx, offboarding *Offboarding) error {
+	tag, err := tx.Exec(ctx, `
+		UPDATE employee SET active = FALSE, updated_at = NOW() WHERE abbreviation = $1
+	`, offboarding.Employee)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	rows, err := tx.Query(ctx, `
+		INSERT INTO offboarding (created_by, employee, reason, reassigned_to)
+			VALUES ($1, $2, $3, $4)
+			RETURNING `+offboardingColumns,
+		offboarding.CreatedBy,
+		offboarding.Employee,
+		offboarding.Reason,
+		offboarding.ReassignedTo,
+	)
+	if err != nil {
+		return err
+	}
+
+	created, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Offboarding])
+	if err != nil {
+		return err
+	}
+
+	// Read by the assignment history trigger, reset at the end of the
+	// transaction.
+	reason := fmt.Sprintf("offboarding %d: %s", created.ID, created.Reason)
+	if _, err := tx.Exec(ctx, "SELECT set_config('dmt.assignment_reason', $1, true)", reason); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT `+device.DeviceColumns+` FROM device WHERE employee = $1 ORDER BY id FOR UPDATE
+	`, created.Employee)
+	if err != nil {
+		return err
+	}
+
+	devices, err := pgx.CollectRows(rows, pgx.RowToStructByName[device.Device])
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(devices))
+	previous := make(map[int]device.Device, len(devices))
+	for _, d := range devices {
+		ids = append(ids, d.ID)
+		previous[d.ID] = d
+	}
+
+	// A single statement, so the device count trigger reports each owner once.
+	rows, err = tx.Query(ctx, `
+		UPDATE device SET employee = $2, updated_at = NOW()
+		WHERE id = ANY($1)
+		RETURNING `+device.DeviceColumns,
+		ids,
+		created.ReassignedTo,
+	)
+	if err != nil {
+		return err
+	}
+
+	released, err := pgx.CollectRows(rows, pgx.RowToStructByName[device.Device])
+	if err != nil {
+		return employee.AssignmentError(err)
+	}
+
+	created.Released = make([]Release, 0, len(released))
+	for _, d := range released {
+		created.Released = append(created.Released, Release{Before: previous[d.ID], After: d})
+	}
+
+	rows, err = tx.Query(ctx, `
+		INSERT INTO offboarding_item (offboarding_id, device_id, name, type, ip, mac)
+			SELECT $1, id, name, type, ip, mac FROM device WHERE id = ANY($2) ORDER BY id
+			RETURNING `+itemColumns,
+		created.ID,
+		ids,
+	)
+	if err != nil {
+		return err
+	}
+
+	created.Items, err = pgx.CollectRows(rows, pgx.RowToStructByName[Item])
+	if err != nil {
+		return err
+	}
+
+	if len(created.Items) == 0 {
+		err := tx.QueryRow(ctx, `
+			UPDATE offboarding SET completed_at = NOW() WHERE id = $1 RETURNING completed_at
+		`, created.ID).Scan(&created.CompletedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	*offboarding = created
+	return nil
+}
+
+func GetOffboardingByID(ctx context.Context, db *pgxpool.Pool, offboarding *Offboarding) error {
+	query := `SELECT ` + offboardingColumns + ` FROM offboarding WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, offboarding.ID)
+	if err != nil {
+		return err
+	}
+
+	found, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Offboarding])
+	if err != nil {
+		return err
+	}
+
+	offboardings := []Offboarding{found}
+	if err := loadItems(ctx, db, offboardings); err != nil {
+		return err
+	}
+	*offboarding = offboardings[0]
+
+	return nil
+}
+
+func GetOffboardings(ctx context.Context, db *pgxpool.Pool, filter *Filter) ([]Offboarding, error) {
+	query := `SELECT ` + offboardingColumns + ` FROM offboarding WHERE 1=1`
+	args := []interface{}{}
+
+	if filter.Employee != "" {
+		args = append(args, filter.Employee)
+		query += fmt.Sprintf(" AND employee = $%d", len(args))
+	}
+
+	switch filter.Status {
+	case StatusOpen:
+		query += " AND completed_at IS NULL"
+	case StatusCompleted:
+		query += " AND completed_at IS NOT NULL"
+	}
+
+	query += " ORDER BY created_at DESC, id DESC"
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	offboardings, err := pgx.CollectRows(rows, pgx.RowToStructByName[Offboarding])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadItems(ctx, db, offboardings); err != nil {
+		return nil, err
+	}
+
+	return offboardings, nil
+}
+
+func loadItems(ctx context.Context, db *pgxpool.Pool, offboardings []Offboarding) error {
+	ids := make([]int, 0, len(offboardings))
+	byID := make(map[int]*Offboarding, len(offboardings))
+	for i := range offboardings {
+		offboardings[i].Items = []Item{}
+		ids = append(ids, offboardings[i].ID)
+		byID[offboardings[i].ID] = &offboardings[i]
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query(ctx, `SELECT `+itemColumns+` FROM offboarding_item WHERE offboarding_id = ANY($1) ORDER BY id`, ids)
+	if err != nil {
+		return err
+	}
+
+	items, err := pgx.CollectRows(rows, pgx.RowToStructByName[Item])
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		offboarding := byID[item.OffboardingID]
+		offboarding.Items = append(offboarding.Items, item)
+	}
+
+	return nil
+}
+
+// ReturnItem marks a device of the checklist as returned and completes the
+// offboarding once nothing is left to collect. It returns pgx.ErrNoRows if the
+// item does not belong to the offboarding and ErrAlreadyReturned if it was
+// returned before.
+func ReturnItem(ctx context.Context, db *pgxpool.Pool, item *Item) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var returnedAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT returned_at FROM offboarding_item
+		WHERE id = $1 AND offboarding_id = $2
+		FOR UPDATE
+	`, item.ID, item.OffboardingID).Scan(&returnedAt)
+	if err != nil {
+		return err
+	}
+	if returnedAt != nil {
+		return ErrAlreadyReturned
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE offboarding_item SET returned_at = NOW(), returned_by = $2, note = $3
+		WHERE id = $1
+		RETURNING `+itemColumns,
+		item.ID,
+		item.ReturnedBy,
+		item.Note,
+	)
+	if err != nil {
+		return err
+	}
+
+	updated, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Item])
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE offboarding SET completed_at = NOW()
+		WHERE id = $1 AND completed_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM offboarding_item
+				WHERE offboarding_id = $1 AND returned_at IS NULL
+			)
+	`, item.OffboardingID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	*item = updated
+	return nil
+}
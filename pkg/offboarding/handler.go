@@ -0,0 +1,232 @@
+package offboarding
+
+import (
+	"dmt/pkg/audit"
+	"dmt/pkg/employee"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OffboardingHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewOffboardingHandler(db *pgxpool.Pool) *OffboardingHandler {
+	return &OffboardingHandler{db: db}
+}
+
+func actor(c *fiber.Ctx) string {
+	if actor, ok := c.Locals(audit.ActorLocal).(string); ok && actor != "" {
+		return actor
+	}
+	return "unknown"
+}
+
+// AuditFunc writes the audit entries of an offboarding as part of the
+// transaction applying it.
+type AuditFunc func(tx pgx.Tx, offboarding *Offboarding) error
+
+// Audited returns the AuditFunc recording the devices released by an
+// offboarding of the request.
+func Audited(c *fiber.Ctx) AuditFunc {
+	return func(tx pgx.Tx, offboarding *Offboarding) error {
+		action := audit.ActionUnassign
+		if offboarding.ReassignedTo != nil {
+			action = audit.ActionAssign
+		}
+
+		for i := range offboarding.Released {
+			released := &offboarding.Released[i]
+			if err := audit.Record(c, tx, action, released.After.ID, &released.Before, &released.After); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func invalidID(c *fiber.Ctx, err error, message string) error {
+	log.Errorf("%s: %s", message, err.Error())
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": message,
+	})
+}
+
+func notFoundOr(c *fiber.Ctx, err error, notFound string, message string) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": notFound,
+		})
+	}
+	log.Errorf("%s: %s", message, err.Error())
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
+
+// Offboard releases all devices of the employee, optionally reassigning them
+// to a pool owner, and returns the checklist of devices to collect.
+func (s *OffboardingHandler) Offboard(c *fiber.Ctx) error {
+	abbreviation := c.Params("abbr")
+	if len(abbreviation) != 3 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid employee abbreviation",
+		})
+	}
+
+	var requestBody struct {
+		Reason     string  `json:"reason"`
+		ReassignTo *string `json:"reassign_to"`
+	}
+	err := c.BodyParser(&requestBody)
+	if err != nil {
+		log.Errorf("Invalid JSON format: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	offboarding := &Offboarding{
+		CreatedBy:    actor(c),
+		Employee:     abbreviation,
+		Reason:       strings.TrimSpace(requestBody.Reason),
+		ReassignedTo: requestBody.ReassignTo,
+	}
+
+	switch {
+	case offboarding.Reason == "":
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "reason is required",
+		})
+	case len(offboarding.Reason) > 500:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "reason must be less than 500 characters",
+		})
+	case offboarding.ReassignedTo != nil && len(*offboarding.ReassignedTo) != 3:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "reassign_to must be 3 characters",
+		})
+	case offboarding.ReassignedTo != nil && *offboarding.ReassignedTo == abbreviation:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "reassign_to must differ from the offboarded employee",
+		})
+	}
+
+	err = offboard(c.Context(), s.db, offboarding, Audited(c))
+	if employee.IsAssignmentError(err) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Employee not found",
+		})
+	}
+	if err != nil {
+		log.Errorf("Failed to offboard employee: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to offboard employee",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":     "Employee offboarded successfully",
+		"offboarding": offboarding,
+	})
+}
+
+func (s *OffboardingHandler) GetOffboardings(c *fiber.Ctx) error {
+	filter := &Filter{
+		Employee: c.Query("employee"),
+		Status:   c.Query("status"),
+	}
+
+	if filter.Status != "" && filter.Status != StatusOpen && filter.Status != StatusCompleted {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "status must be open or completed",
+		})
+	}
+
+	offboardings, err := GetOffboardings(c.Context(), s.db, filter)
+	if err != nil {
+		log.Errorf("Failed to retrieve offboardings: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve offboardings",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"offboardings": offboardings,
+		"count":        len(offboardings),
+	})
+}
+
+func (s *OffboardingHandler) GetOffboarding(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return invalidID(c, err, "Invalid offboarding ID")
+	}
+
+	offboarding := &Offboarding{ID: id}
+	if err := GetOffboardingByID(c.Context(), s.db, offboarding); err != nil {
+		return notFoundOr(c, err, "Offboarding not found", "Failed to retrieve offboarding")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(offboarding)
+}
+
+// ReturnItem records that a device of the checklist was physically returned.
+func (s *OffboardingHandler) ReturnItem(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return invalidID(c, err, "Invalid offboarding ID")
+	}
+
+	itemID, err := strconv.Atoi(c.Params("item"))
+	if err != nil {
+		return invalidID(c, err, "Invalid item ID")
+	}
+
+	var requestBody struct {
+		Note *string `json:"note"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&requestBody); err != nil {
+			log.Errorf("Invalid JSON format: %s", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid JSON format",
+			})
+		}
+	}
+
+	returnedBy := actor(c)
+	item := &Item{
+		ID:            itemID,
+		OffboardingID: id,
+		ReturnedBy:    &returnedBy,
+		Note:          requestBody.Note,
+	}
+
+	err = ReturnItem(c.Context(), s.db, item)
+	if errors.Is(err, ErrAlreadyReturned) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Device already returned",
+		})
+	}
+	if err != nil {
+		return notFoundOr(c, err, "Item not found", "Failed to return device")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Device returned successfully",
+		"item":    item,
+	})
+}
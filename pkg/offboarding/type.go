@@ -0,0 +1,57 @@
+package offboarding
+
+import (
+	"dmt/pkg/device"
+	"net"
+	"time"
+)
+
+const (
+	StatusOpen      = "open"
+	StatusCompleted = "completed"
+)
+
+// Offboarding records that an employee left, what happened to their devices
+// and which devices still have to be collected. It is completed once every
+// item has been returned.
+type Offboarding struct {
+	ID           int        `json:"id" db:"id"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	CreatedBy    string     `json:"created_by" db:"created_by"`
+	Employee     string     `json:"employee" db:"employee"`
+	Reason       string     `json:"reason" db:"reason"`
+	ReassignedTo *string    `json:"reassigned_to" db:"reassigned_to"`
+	CompletedAt  *time.Time `json:"completed_at" db:"completed_at"`
+	Items        []Item     `json:"items" db:"-"`
+
+	// Released are the devices released by the offboarding, for the audit
+	// log.
+	Released []Release `json:"-" db:"-"`
+}
+
+// Release is a device as it was before and after being released.
+type Release struct {
+	Before device.Device
+	After  device.Device
+}
+
+// Item is a device on the return checklist of an offboarding. The device is
+// copied, DeviceID is cleared if the device is deleted later on.
+type Item struct {
+	ID            int              `json:"id" db:"id"`
+	OffboardingID int              `json:"offboarding_id" db:"offboarding_id"`
+	DeviceID      *int             `json:"device_id" db:"device_id"`
+	Name          string           `json:"name" db:"name"`
+	Type          string           `json:"type" db:"type"`
+	IP            net.IP           `json:"ip" db:"ip"`
+	MAC           net.HardwareAddr `json:"mac" db:"mac"`
+	ReturnedAt    *time.Time       `json:"returned_at" db:"returned_at"`
+	ReturnedBy    *string          `json:"returned_by" db:"returned_by"`
+	Note          *string          `json:"note" db:"note"`
+}
+
+// Filter narrows down offboarding listings. Empty fields are ignored.
+type Filter struct {
+	Employee string
+	Status   string
+}
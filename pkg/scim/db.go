@@ -0,0 +1,95 @@
+package scim
+
+import (
+	"context"
+	"dmt/pkg/employee"
+	"dmt/pkg/offboarding"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// updateUser applies the update to the employee and, if o is set, offboards
+// them in the same transaction, so a user is never deactivated without their
+// devices being released. Employees already inactive are not offboarded
+// again. The released devices are audited with audit. It reports whether the
+// employee was offboarded.
+func updateUser(ctx context.Context, db *pgxpool.Pool, e *employee.Employee, update *employee.EmployeeUpdate, o *offboarding.Offboarding, audit offboarding.AuditFunc) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	active, _, err := lockEmployee(ctx, tx, e.Abbreviation)
+	if err != nil {
+		return false, err
+	}
+
+	if update.Name != nil || update.Email != nil || update.Department != nil || update.Active != nil {
+		if err := employee.UpdateEmployeeTx(ctx, tx, e, update); err != nil {
+			return false, err
+		}
+	}
+
+	offboard := o != nil && active
+	if offboard {
+		if err := offboarding.OffboardTx(ctx, tx, o); err != nil {
+			return false, err
+		}
+		if err := audit(tx, o); err != nil {
+			return false, err
+		}
+	}
+
+	return offboard, tx.Commit(ctx)
+}
+
+// deleteUser removes the employee. Active employees and employees still
+// holding devices are offboarded first, in the same transaction, and the
+// released devices are audited with audit.
+func deleteUser(ctx context.Context, db *pgxpool.Pool, o *offboarding.Offboarding, audit offboarding.AuditFunc) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	active, holdsDevices, err := lockEmployee(ctx, tx, o.Employee)
+	if err != nil {
+		return err
+	}
+
+	if active || holdsDevices {
+		if err := offboarding.OffboardTx(ctx, tx, o); err != nil {
+			return err
+		}
+		if err := audit(tx, o); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM employee WHERE abbreviation = $1`, o.Employee); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// lockEmployee locks the employee row for the rest of the transaction. It
+// returns pgx.ErrNoRows if the employee does not exist.
+func lockEmployee(ctx context.Context, tx pgx.Tx, abbreviation string) (active bool, holdsDevices bool, err error) {
+	err = tx.QueryRow(ctx, `
+		SELECT active, EXISTS (SELECT 1 FROM device WHERE employee = $1)
+		FROM employee WHERE abbreviation = $1
+		FOR UPDATE
+	`, abbreviation).Scan(&active, &holdsDevices)
+	return active, holdsDevices, err
+}
@@ -0,0 +1,25 @@
+package scim
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// userNameFilterPattern matches the only filter supported, the one identity
+// providers use to look up a user before creating it.
+var userNameFilterPattern = regexp.MustCompile(`(?i)^\s*username\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// parseFilter returns the userName of a userName eq "..." filter.
+func parseFilter(filter string) (string, error) {
+	match := userNameFilterPattern.FindStringSubmatch(filter)
+	if match == nil {
+		return "", &requestError{scimType: typeInvalidFilter, detail: `only filters of the form userName eq "value" are supported`}
+	}
+
+	userName, err := strconv.Unquote(match[1])
+	if err != nil {
+		return "", &requestError{scimType: typeInvalidFilter, detail: "invalid string in filter"}
+	}
+
+	return userName, nil
+}
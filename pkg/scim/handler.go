@@ -0,0 +1,271 @@
+package scim
+
+import (
+	"dmt/pkg/audit"
+	"dmt/pkg/employee"
+	"dmt/pkg/offboarding"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const uniqueViolationCode = "23505"
+
+// DeprovisionReason is the offboarding reason of users deactivated or deleted
+// by the identity provider.
+const DeprovisionReason = "deprovisioned via SCIM"
+
+// UserHandler serves the SCIM Users resource on top of the employee
+// directory.
+type UserHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewUserHandler(db *pgxpool.Pool) *UserHandler {
+	return &UserHandler{db: db}
+}
+
+// SendError responds with a SCIM error. scimType may be empty.
+func SendError(c *fiber.Ctx, status int, scimType string, detail string) error {
+	return c.Status(status).JSON(Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}, ContentType)
+}
+
+func errorResponse(c *fiber.Ctx, err error, message string) error {
+	var requestErr *requestError
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.As(err, &requestErr):
+		return SendError(c, fiber.StatusBadRequest, requestErr.scimType, requestErr.detail)
+	case errors.Is(err, employee.ErrValidation):
+		return SendError(c, fiber.StatusBadRequest, typeInvalidValue, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		return SendError(c, fiber.StatusNotFound, "", "User not found")
+	case errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode:
+		return SendError(c, fiber.StatusConflict, typeUniqueness, "User with this userName or email already exists")
+	default:
+		log.Errorf("%s: %s", message, err.Error())
+		return SendError(c, fiber.StatusInternalServerError, "", message)
+	}
+}
+
+func actor(c *fiber.Ctx) string {
+	if actor, ok := c.Locals(audit.ActorLocal).(string); ok && actor != "" {
+		return actor
+	}
+	return "unknown"
+}
+
+func location(c *fiber.Ctx, abbreviation string) string {
+	return c.BaseURL() + "/scim/v2/Users/" + abbreviation
+}
+
+func (s *UserHandler) respond(c *fiber.Ctx, status int, e *employee.Employee) error {
+	return c.Status(status).JSON(toUser(e, location(c, e.Abbreviation)), ContentType)
+}
+
+func (s *UserHandler) CreateUser(c *fiber.Ctx) error {
+	var user User
+	if err := c.BodyParser(&user); err != nil {
+		return SendError(c, fiber.StatusBadRequest, typeInvalidSyntax, "Invalid JSON format")
+	}
+
+	if user.UserName == "" {
+		return SendError(c, fiber.StatusBadRequest, typeInvalidValue, "userName is required")
+	}
+
+	e := user.employee()
+	if err := employee.InsertEmployee(c.Context(), s.db, e); err != nil {
+		return errorResponse(c, err, "Failed to create user")
+	}
+
+	c.Location(location(c, e.Abbreviation))
+	return s.respond(c, fiber.StatusCreated, e)
+}
+
+// GetUsers lists all users in pages, or looks up a single user by a
+// userName eq "..." filter.
+func (s *UserHandler) GetUsers(c *fiber.Ctx) error {
+	startIndex, err := strconv.Atoi(c.Query("startIndex", "1"))
+	if err != nil {
+		return SendError(c, fiber.StatusBadRequest, typeInvalidValue, "startIndex must be an integer")
+	}
+	startIndex = max(startIndex, 1)
+
+	count, err := strconv.Atoi(c.Query("count", strconv.Itoa(DefaultCount)))
+	if err != nil {
+		return SendError(c, fiber.StatusBadRequest, typeInvalidValue, "count must be an integer")
+	}
+	count = min(max(count, 0), DefaultCount)
+
+	var employees []employee.Employee
+	total := 0
+	if filter := c.Query("filter"); filter != "" {
+		userName, err := parseFilter(filter)
+		if err != nil {
+			return errorResponse(c, err, "Failed to list users")
+		}
+
+		e := &employee.Employee{Abbreviation: userName}
+		err = employee.GetEmployeeByAbbreviation(c.Context(), s.db, e)
+		switch {
+		case err == nil:
+			total = 1
+			if startIndex == 1 && count > 0 {
+				employees = append(employees, *e)
+			}
+		case !errors.Is(err, pgx.ErrNoRows):
+			return errorResponse(c, err, "Failed to list users")
+		}
+	} else {
+		total, err = employee.CountEmployees(c.Context(), s.db, &employee.Filter{})
+		if err != nil {
+			return errorResponse(c, err, "Failed to list users")
+		}
+
+		if count > 0 {
+			employees, err = employee.GetEmployees(c.Context(), s.db, &employee.Filter{
+				Limit:  count,
+				Offset: startIndex - 1,
+			})
+			if err != nil {
+				return errorResponse(c, err, "Failed to list users")
+			}
+		}
+	}
+
+	response := ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(employees),
+		Resources:    []User{},
+	}
+
+	for i := range employees {
+		response.Resources = append(response.Resources, toUser(&employees[i], location(c, employees[i].Abbreviation)))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response, ContentType)
+}
+
+func (s *UserHandler) GetUser(c *fiber.Ctx) error {
+	e := &employee.Employee{Abbreviation: c.Params("id")}
+	if err := employee.GetEmployeeByAbbreviation(c.Context(), s.db, e); err != nil {
+		return errorResponse(c, err, "Failed to retrieve user")
+	}
+
+	return s.respond(c, fiber.StatusOK, e)
+}
+
+// ReplaceUser replaces all mapped attributes of the user.
+func (s *UserHandler) ReplaceUser(c *fiber.Ctx) error {
+	var user User
+	if err := c.BodyParser(&user); err != nil {
+		return SendError(c, fiber.StatusBadRequest, typeInvalidSyntax, "Invalid JSON format")
+	}
+
+	abbreviation := c.Params("id")
+	if user.UserName != "" && user.UserName != abbreviation {
+		return SendError(c, fiber.StatusBadRequest, typeMutability, "userName can't be changed")
+	}
+
+	return s.update(c, abbreviation, user.replacement())
+}
+
+func (s *UserHandler) PatchUser(c *fiber.Ctx) error {
+	var request PatchRequest
+	if err := c.BodyParser(&request); err != nil {
+		return SendError(c, fiber.StatusBadRequest, typeInvalidSyntax, "Invalid JSON format")
+	}
+
+	abbreviation := c.Params("id")
+	update := &employee.EmployeeUpdate{}
+	if err := applyPatch(&request, abbreviation, update); err != nil {
+		return errorResponse(c, err, "Failed to update user")
+	}
+
+	return s.update(c, abbreviation, update)
+}
+
+// update applies the update to the employee. Deactivating an active employee
+// deprovisions them, which releases their devices.
+func (s *UserHandler) update(c *fiber.Ctx, abbreviation string, update *employee.EmployeeUpdate) error {
+	e := &employee.Employee{Abbreviation: abbreviation}
+	if err := employee.GetEmployeeByAbbreviation(c.Context(), s.db, e); err != nil {
+		return errorResponse(c, err, "Failed to update user")
+	}
+
+	// Offboarding deactivates the employee.
+	var o *offboarding.Offboarding
+	if update.Active != nil && !*update.Active {
+		update.Active = nil
+		o = s.offboarding(c, abbreviation)
+	}
+
+	offboarded, err := updateUser(c.Context(), s.db, e, update, o, offboarding.Audited(c))
+	if err != nil {
+		return errorResponse(c, err, "Failed to update user")
+	}
+
+	if offboarded {
+		if err := employee.GetEmployeeByAbbreviation(c.Context(), s.db, e); err != nil {
+			return errorResponse(c, err, "Failed to retrieve user")
+		}
+	}
+
+	return s.respond(c, fiber.StatusOK, e)
+}
+
+// DeleteUser deprovisions the user and removes the employee. The assignment
+// history and the offboarding checklist keep the abbreviation.
+func (s *UserHandler) DeleteUser(c *fiber.Ctx) error {
+	o := s.offboarding(c, c.Params("id"))
+
+	if err := deleteUser(c.Context(), s.db, o, offboarding.Audited(c)); err != nil {
+		return errorResponse(c, err, "Failed to delete user")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// offboarding prepares the offboarding deprovisioning the employee, which
+// unassigns their devices and puts them on a return checklist.
+func (s *UserHandler) offboarding(c *fiber.Ctx, abbreviation string) *offboarding.Offboarding {
+	return &offboarding.Offboarding{
+		CreatedBy: actor(c),
+		Employee:  abbreviation,
+		Reason:    DeprovisionReason,
+	}
+}
+
+// GetServiceProviderConfig describes the supported SCIM features, which some
+// identity providers read before provisioning.
+func (s *UserHandler) GetServiceProviderConfig(c *fiber.Ctx) error {
+	unsupported := fiber.Map{"supported": false}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"schemas":        []string{SchemaServiceConfig},
+		"patch":          fiber.Map{"supported": true},
+		"bulk":           fiber.Map{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         fiber.Map{"supported": true, "maxResults": DefaultCount},
+		"changePassword": unsupported,
+		"sort":           unsupported,
+		"etag":           unsupported,
+		"authenticationSchemes": []fiber.Map{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Authentication with the SCIM token of dmt",
+		}},
+	}, ContentType)
+}
@@ -0,0 +1,116 @@
+package scim
+
+import (
+	"dmt/pkg/employee"
+	"fmt"
+	"strings"
+)
+
+// requestError is a client error reported with its scimType.
+type requestError struct {
+	scimType string
+	detail   string
+}
+
+func (e *requestError) Error() string {
+	return e.detail
+}
+
+func invalidValue(format string, args ...any) error {
+	return &requestError{scimType: typeInvalidValue, detail: fmt.Sprintf(format, args...)}
+}
+
+func toUser(e *employee.Employee, location string) User {
+	user := User{
+		Schemas:     []string{SchemaUser},
+		ID:          e.Abbreviation,
+		UserName:    e.Abbreviation,
+		DisplayName: e.Name,
+		Name:        &Name{Formatted: e.Name},
+		Active:      &e.Active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      e.CreatedAt,
+			LastModified: e.UpdatedAt,
+			Location:     location,
+		},
+	}
+
+	if e.Email != nil {
+		user.Emails = []Email{{Value: *e.Email, Type: "work", Primary: true}}
+	}
+
+	if e.Department != nil {
+		user.Schemas = append(user.Schemas, SchemaEnterpriseUser)
+		user.Enterprise = &EnterpriseUser{Department: *e.Department}
+	}
+
+	return user
+}
+
+// displayName picks the name of the employee, preferring the display name
+// over the formatted name over given and family name.
+func (u *User) displayName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	if u.Name == nil {
+		return ""
+	}
+	if u.Name.Formatted != "" {
+		return u.Name.Formatted
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// primaryEmail returns the primary email, or the first one if none is marked
+// primary.
+func primaryEmail(emails []Email) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func (u *User) employee() *employee.Employee {
+	e := &employee.Employee{
+		Abbreviation: u.UserName,
+		Name:         u.displayName(),
+		Active:       u.Active == nil || *u.Active,
+		Source:       Source,
+	}
+
+	if email := primaryEmail(u.Emails); email != "" {
+		e.Email = &email
+	}
+
+	if u.Enterprise != nil && u.Enterprise.Department != "" {
+		e.Department = &u.Enterprise.Department
+	}
+
+	return e
+}
+
+// replacement turns a replaced user into an update of every mapped field, so
+// attributes missing from the user are cleared. An omitted active flag is left
+// untouched.
+func (u *User) replacement() *employee.EmployeeUpdate {
+	name := u.displayName()
+	email := primaryEmail(u.Emails)
+	department := ""
+	if u.Enterprise != nil {
+		department = u.Enterprise.Department
+	}
+
+	return &employee.EmployeeUpdate{
+		Name:       &name,
+		Email:      &email,
+		Department: &department,
+		Active:     u.Active,
+	}
+}
@@ -0,0 +1,180 @@
+package scim
+
+import (
+	"dmt/pkg/employee"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// emailPathPattern matches the email paths identity providers send, e.g.
+// emails[type eq "work"].value.
+var emailPathPattern = regexp.MustCompile(`^emails(\[[^\]]*\])?(\.value)?$`)
+
+var enterprisePrefix = strings.ToLower(SchemaEnterpriseUser) + ":"
+
+// applyPatch turns the operations of a PatchOp request into an update of the
+// employee. Attributes dmt does not store, e.g. externalId or the parts of
+// the name, are ignored.
+func applyPatch(request *PatchRequest, abbreviation string, update *employee.EmployeeUpdate) error {
+	if len(request.Operations) == 0 {
+		return &requestError{scimType: typeInvalidSyntax, detail: "Operations are required"}
+	}
+
+	for _, operation := range request.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return &requestError{scimType: typeInvalidSyntax, detail: fmt.Sprintf("unsupported op %q", operation.Op)}
+		}
+
+		if operation.Path != "" {
+			if err := patchAttribute(op, operation.Path, operation.Value, abbreviation, update); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if op == "remove" {
+			return &requestError{scimType: typeNoTarget, detail: "remove requires a path"}
+		}
+
+		attributes, ok := operation.Value.(map[string]any)
+		if !ok {
+			return invalidValue("value must be an object if no path is given")
+		}
+		for path, value := range attributes {
+			if err := patchAttribute(op, path, value, abbreviation, update); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func patchAttribute(op, path string, value any, abbreviation string, update *employee.EmployeeUpdate) error {
+	path = strings.ToLower(strings.TrimSpace(path))
+	remove := op == "remove"
+
+	// Attributes of the core schema may be qualified with its URN.
+	path = strings.TrimPrefix(path, strings.ToLower(SchemaUser)+":")
+
+	switch {
+	case path == "username":
+		userName, err := stringValue(path, value)
+		if err != nil {
+			return err
+		}
+		if remove || userName != abbreviation {
+			return &requestError{scimType: typeMutability, detail: "userName can't be changed"}
+		}
+
+	case path == "active":
+		if remove {
+			return invalidValue("active can't be removed")
+		}
+		active, err := boolValue(value)
+		if err != nil {
+			return err
+		}
+		update.Active = &active
+
+	case path == "displayname" || path == "name.formatted":
+		if remove {
+			return invalidValue("%s can't be removed", path)
+		}
+		name, err := stringValue(path, value)
+		if err != nil {
+			return err
+		}
+		update.Name = &name
+
+	case path == "name":
+		name, ok := value.(map[string]any)
+		if remove || !ok {
+			return invalidValue("name must be an object")
+		}
+		if formatted, ok := name["formatted"].(string); ok && formatted != "" && update.Name == nil {
+			update.Name = &formatted
+		}
+
+	case emailPathPattern.MatchString(path):
+		email := ""
+		if !remove {
+			var err error
+			if email, err = emailValue(value); err != nil {
+				return err
+			}
+		}
+		update.Email = &email
+
+	case path == enterprisePrefix+"department":
+		department := ""
+		if !remove {
+			var err error
+			if department, err = stringValue(path, value); err != nil {
+				return err
+			}
+		}
+		update.Department = &department
+
+	case path == strings.TrimSuffix(enterprisePrefix, ":"):
+		extension, ok := value.(map[string]any)
+		if remove || !ok {
+			return invalidValue("%s must be an object", SchemaEnterpriseUser)
+		}
+		if department, ok := extension["department"]; ok {
+			return patchAttribute(op, enterprisePrefix+"department", department, abbreviation, update)
+		}
+	}
+
+	return nil
+}
+
+func stringValue(path string, value any) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", invalidValue("%s must be a string", path)
+	}
+	return s, nil
+}
+
+// boolValue also accepts "True" and "False", which some identity providers
+// send instead of booleans.
+func boolValue(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		parsed, err := strconv.ParseBool(strings.ToLower(v))
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return false, invalidValue("active must be a boolean")
+}
+
+// emailValue reads an email given as plain value, as a single email object or
+// as a list of them.
+func emailValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case map[string]any:
+		return emailValue([]any{v})
+	case []any:
+		var emails []Email
+		for _, item := range v {
+			object, ok := item.(map[string]any)
+			if !ok {
+				return "", invalidValue("emails must be email objects")
+			}
+			address, _ := object["value"].(string)
+			primary, _ := object["primary"].(bool)
+			emails = append(emails, Email{Value: address, Primary: primary})
+		}
+		return primaryEmail(emails), nil
+	}
+	return "", invalidValue("emails must be a list of email objects")
+}
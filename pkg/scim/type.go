@@ -0,0 +1,98 @@
+package scim
+
+import "time"
+
+const (
+	SchemaUser           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaEnterpriseUser = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	SchemaListResponse   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError          = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceConfig  = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// ContentType is the media type of all SCIM requests and responses.
+const ContentType = "application/scim+json"
+
+// Source marks employees provisioned through SCIM.
+const Source = "scim"
+
+// DefaultCount is the page size of user listings without a count.
+const DefaultCount = 100
+
+// User is the SCIM representation of an employee. The userName is the
+// employee abbreviation, which also serves as the immutable id.
+type User struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id,omitempty"`
+	ExternalID  string          `json:"externalId,omitempty"`
+	UserName    string          `json:"userName"`
+	DisplayName string          `json:"displayName,omitempty"`
+	Name        *Name           `json:"name,omitempty"`
+	Emails      []Email         `json:"emails,omitempty"`
+	Active      *bool           `json:"active,omitempty"`
+	Enterprise  *EnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta        *Meta           `json:"meta,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type EnterpriseUser struct {
+	Department string `json:"department,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []User   `json:"Resources"`
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// Error is the error response of RFC 7644, section 3.12. Status is a string
+// as required by the RFC.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// The scimType values of RFC 7644 used by this server.
+const (
+	typeInvalidFilter = "invalidFilter"
+	typeInvalidPath   = "invalidPath"
+	typeInvalidSyntax = "invalidSyntax"
+	typeInvalidValue  = "invalidValue"
+	typeMutability    = "mutability"
+	typeNoTarget      = "noTarget"
+	typeUniqueness    = "uniqueness"
+)
@@ -0,0 +1,303 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const subscriptionColumns = "id, created_at, updated_at, url, description, events, secret, enabled"
+
+const deliveryColumns = "id, subscription_id, created_at, event, payload, status, attempts, next_attempt_at, response_status, last_error, delivered_at"
+
+func generateSecret() (string, error) {
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secretBytes), nil
+}
+
+// InsertSubscription stores the subscription with a newly generated secret.
+func InsertSubscription(ctx context.Context, db *pgxpool.Pool, subscription *Subscription) error {
+	secret, err := generateSecret()
+	if err != nil {
+		return fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	query := `
+		INSERT INTO webhook_subscription (url, description, events, secret, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + subscriptionColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, subscription.URL, subscription.Description, subscription.Events, secret, subscription.Enabled)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Subscription])
+	if err != nil {
+		return err
+	}
+	*subscription = inserted
+
+	return nil
+}
+
+func UpdateSubscription(ctx context.Context, db *pgxpool.Pool, subscription *Subscription, update *SubscriptionUpdate) error {
+	args := []interface{}{subscription.ID}
+	sets := []string{}
+
+	if update.URL != nil {
+		args = append(args, *update.URL)
+		sets = append(sets, fmt.Sprintf("url = $%d", len(args)))
+	}
+	if update.Description != nil {
+		args = append(args, update.Description)
+		sets = append(sets, fmt.Sprintf("description = NULLIF($%d, '')", len(args)))
+	}
+	if update.Events != nil {
+		args = append(args, *update.Events)
+		sets = append(sets, fmt.Sprintf("events = $%d", len(args)))
+	}
+	if update.Enabled != nil {
+		args = append(args, *update.Enabled)
+		sets = append(sets, fmt.Sprintf("enabled = $%d", len(args)))
+	}
+	sets = append(sets, "updated_at = NOW()")
+
+	query := `
+		UPDATE webhook_subscription
+		SET ` + strings.Join(sets, ", ") + `
+		WHERE id = $1
+		RETURNING ` + subscriptionColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Subscription])
+	if err != nil {
+		return err
+	}
+	*subscription = updated
+
+	return nil
+}
+
+func DeleteSubscription(ctx context.Context, db *pgxpool.Pool, subscription *Subscription) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := db.Exec(ctx, `DELETE FROM webhook_subscription WHERE id = $1`, subscription.ID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func GetSubscriptionByID(ctx context.Context, db *pgxpool.Pool, subscription *Subscription) error {
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscription WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, subscription.ID)
+	if err != nil {
+		return err
+	}
+
+	found, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Subscription])
+	if err != nil {
+		return err
+	}
+	*subscription = found
+
+	return nil
+}
+
+func GetSubscriptions(ctx context.Context, db *pgxpool.Pool) ([]Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscription ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[Subscription])
+}
+
+// Enqueue queues a delivery of the event for every enabled subscription of
+// its type. Device events are queued by a trigger, this is for events which
+// are computed by the application.
+func Enqueue(ctx context.Context, db *pgxpool.Pool, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err = db.Exec(ctx, `SELECT enqueue_webhook_event($1, $2)`, event, payload)
+	return err
+}
+
+// InsertTestDelivery records a test event for a single subscription regardless
+// of its filters. It is inserted as claimed, since the caller delivers it.
+func InsertTestDelivery(ctx context.Context, db *pgxpool.Pool, subscription *Subscription) (*Delivery, error) {
+	query := `
+		INSERT INTO webhook_delivery (subscription_id, event, payload, attempts, next_attempt_at)
+		VALUES ($1, $2, jsonb_build_object('message', 'This is a test event'), 1, NOW() + INTERVAL '1 minute')
+		RETURNING ` + deliveryColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, subscription.ID, EventTest)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Delivery])
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// claimedDelivery is a leased delivery along with the target it goes to.
+type claimedDelivery struct {
+	Delivery
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// claimDeliveries leases up to limit due deliveries like the notification
+// outbox does.
+func claimDeliveries(ctx context.Context, db *pgxpool.Pool, limit int, lease time.Duration) ([]claimedDelivery, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE webhook_delivery
+			SET attempts = attempts + 1, next_attempt_at = NOW() + $2::interval
+			WHERE id IN (
+				SELECT id
+				FROM webhook_delivery
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+				ORDER BY id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT claimed.id, claimed.subscription_id, claimed.created_at, claimed.event, claimed.payload,
+			claimed.status, claimed.attempts, claimed.next_attempt_at, claimed.response_status,
+			claimed.last_error, claimed.delivered_at, subscription.url, subscription.secret
+		FROM claimed
+		JOIN webhook_subscription subscription ON subscription.id = claimed.subscription_id
+		ORDER BY claimed.id`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[claimedDelivery])
+}
+
+// recordAttempt stores the outcome of a delivery attempt. A failed attempt is
+// retried at retryAt, or given up if retryAt is nil.
+func recordAttempt(ctx context.Context, db *pgxpool.Pool, delivery *Delivery, responseStatus *int, deliveryErr error, retryAt *time.Time) error {
+	query := `
+		UPDATE webhook_delivery
+		SET status = 'delivered', delivered_at = NOW(), response_status = $2, last_error = NULL
+		WHERE id = $1
+		RETURNING ` + deliveryColumns
+	args := []interface{}{delivery.ID, responseStatus}
+
+	if deliveryErr != nil {
+		query = `
+			UPDATE webhook_delivery
+			SET status = 'dead', response_status = $2, last_error = $3
+			WHERE id = $1
+			RETURNING ` + deliveryColumns
+		args = append(args, deliveryErr.Error())
+
+		if retryAt != nil {
+			query = `
+				UPDATE webhook_delivery
+				SET next_attempt_at = $4, response_status = $2, last_error = $3
+				WHERE id = $1
+				RETURNING ` + deliveryColumns
+			args = append(args, *retryAt)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Delivery])
+	if err != nil {
+		return err
+	}
+	*delivery = updated
+
+	return nil
+}
+
+// GetDeliveries returns the delivery history of a subscription, newest first.
+func GetDeliveries(ctx context.Context, db *pgxpool.Pool, subscriptionID int, status string, limit int) ([]Delivery, error) {
+	args := []interface{}{subscriptionID}
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_delivery WHERE subscription_id = $1`
+
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[Delivery])
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"dmt/pkg/dispatch"
+	"dmt/pkg/signature"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	EventHeader    = "X-DMT-Event"
+	DeliveryHeader = "X-DMT-Delivery"
+)
+
+var DefaultDispatcherConfig = dispatch.Config{
+	BatchSize:    20,
+	PollInterval: 2 * time.Second,
+	Lease:        time.Minute,
+	MaxAttempts:  8,
+	BaseBackoff:  5 * time.Second,
+	MaxBackoff:   30 * time.Minute,
+}
+
+// Dispatcher delivers queued webhook events to their subscribers. It polls
+// for due deliveries and can be woken up early.
+type Dispatcher struct {
+	db     *pgxpool.Pool
+	config dispatch.Config
+	client *http.Client
+	wake   chan struct{}
+}
+
+func NewDispatcher(db *pgxpool.Pool, config dispatch.Config) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		config: config,
+		client: newClient(),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	defer log.Info("Webhook dispatcher stopped")
+
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			claimed, err := d.dispatch(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Errorf("Failed to dispatch webhooks: %v", err)
+				break
+			}
+			if claimed < d.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	deliveries, err := claimDeliveries(ctx, d.db, d.config.BatchSize, d.config.Lease)
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		claimed := &deliveries[i]
+		responseStatus, deliveryErr := Send(ctx, d.client, claimed.URL, claimed.Secret, &claimed.Delivery)
+
+		var retryAt *time.Time
+		if deliveryErr != nil && claimed.Attempts < d.config.MaxAttempts {
+			next := time.Now().Add(d.config.RetryDelay(claimed.Attempts))
+			retryAt = &next
+		}
+
+		if err := recordAttempt(ctx, d.db, &claimed.Delivery, responseStatus, deliveryErr, retryAt); err != nil {
+			return len(deliveries), err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// Send posts a single delivery to the subscriber and returns the response
+// status if there was a response.
+func Send(ctx context.Context, client *http.Client, url string, secret string, delivery *Delivery) (*int, error) {
+	body, err := json.Marshal(Event{
+		ID:        delivery.ID,
+		Type:      delivery.Event,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	signature.Sign(req, body, secret)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &resp.StatusCode, fmt.Errorf("subscriber returned status code: %d", resp.StatusCode)
+	}
+
+	return &resp.StatusCode, nil
+}
@@ -0,0 +1,294 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type WebhookHandler struct {
+	db     *pgxpool.Pool
+	client *http.Client
+}
+
+func NewWebhookHandler(db *pgxpool.Pool) *WebhookHandler {
+	return &WebhookHandler{
+		db:     db,
+		client: newClient(),
+	}
+}
+
+func validateURL(ctx context.Context, value string) error {
+	parsed, err := url.ParseRequestURI(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	return validateTarget(ctx, parsed.Hostname())
+}
+
+func validateEvents(events []string) error {
+	if len(events) == 0 {
+		return errors.New("at least one event is required")
+	}
+	for _, event := range events {
+		if !IsKnownEvent(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+func validateDescription(description *string) error {
+	if description != nil && len(*description) > 255 {
+		return errors.New("description must be less than 255 characters")
+	}
+	return nil
+}
+
+func invalidID(c *fiber.Ctx, err error) error {
+	log.Errorf("Invalid webhook ID: %s", err.Error())
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": "Invalid webhook ID",
+	})
+}
+
+func notFoundOr(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Webhook not found",
+		})
+	}
+	log.Errorf("%s: %s", message, err.Error())
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
+
+func (s *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	var requestBody struct {
+		URL         string   `json:"url"`
+		Description *string  `json:"description"`
+		Events      []string `json:"events"`
+		Enabled     *bool    `json:"enabled"`
+	}
+	err := c.BodyParser(&requestBody)
+	if err != nil {
+		log.Errorf("Invalid JSON format: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	subscription := &Subscription{
+		URL:         strings.TrimSpace(requestBody.URL),
+		Description: requestBody.Description,
+		Events:      requestBody.Events,
+		Enabled:     requestBody.Enabled == nil || *requestBody.Enabled,
+	}
+
+	for _, err := range []error{validateURL(c.Context(), subscription.URL), validateEvents(subscription.Events), validateDescription(subscription.Description)} {
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	err = InsertSubscription(c.Context(), s.db, subscription)
+	if err != nil {
+		log.Errorf("Failed to create webhook: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create webhook",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Webhook created successfully, the secret is only shown once",
+		"webhook": subscription,
+		"secret":  subscription.Secret,
+	})
+}
+
+func (s *WebhookHandler) GetWebhooks(c *fiber.Ctx) error {
+	subscriptions, err := GetSubscriptions(c.Context(), s.db)
+	if err != nil {
+		log.Errorf("Failed to retrieve webhooks: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve webhooks",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"webhooks": subscriptions,
+		"count":    len(subscriptions),
+	})
+}
+
+func (s *WebhookHandler) GetWebhook(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return invalidID(c, err)
+	}
+
+	subscription := &Subscription{ID: id}
+	if err := GetSubscriptionByID(c.Context(), s.db, subscription); err != nil {
+		return notFoundOr(c, err, "Failed to retrieve webhook")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(subscription)
+}
+
+// UpdateWebhook changes the given fields of a subscription, e.g.
+// {"enabled": false} to pause it.
+func (s *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return invalidID(c, err)
+	}
+
+	var update SubscriptionUpdate
+	err = c.BodyParser(&update)
+	if err != nil {
+		log.Errorf("Invalid JSON format: %s", err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON format",
+		})
+	}
+
+	var errs []error
+	if update.URL != nil {
+		*update.URL = strings.TrimSpace(*update.URL)
+		errs = append(errs, validateURL(c.Context(), *update.URL))
+	}
+	if update.Events != nil {
+		errs = append(errs, validateEvents(*update.Events))
+	}
+	errs = append(errs, validateDescription(update.Description))
+
+	if err := errors.Join(errs...); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	subscription := &Subscription{ID: id}
+	if err := UpdateSubscription(c.Context(), s.db, subscription, &update); err != nil {
+		return notFoundOr(c, err, "Failed to update webhook")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Webhook updated successfully",
+		"webhook": subscription,
+	})
+}
+
+func (s *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return invalidID(c, err)
+	}
+
+	subscription := &Subscription{ID: id}
+	if err := DeleteSubscription(c.Context(), s.db, subscription); err != nil {
+		return notFoundOr(c, err, "Failed to delete webhook")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// GetDeliveries lists the delivery history of a subscription, optionally
+// filtered by ?status=.
+func (s *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return invalidID(c, err)
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", DeliveryPending, DeliveryDelivered, DeliveryDead:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid status",
+		})
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid limit",
+		})
+	}
+
+	subscription := &Subscription{ID: id}
+	if err := GetSubscriptionByID(c.Context(), s.db, subscription); err != nil {
+		return notFoundOr(c, err, "Failed to retrieve deliveries")
+	}
+
+	deliveries, err := GetDeliveries(c.Context(), s.db, id, status, limit)
+	if err != nil {
+		log.Errorf("Failed to retrieve deliveries: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve deliveries",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// SendTestEvent delivers a test event right away, even to a disabled
+// subscription, and reports the outcome. It is recorded in the history but
+// not retried.
+func (s *WebhookHandler) SendTestEvent(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return invalidID(c, err)
+	}
+
+	subscription := &Subscription{ID: id}
+	if err := GetSubscriptionByID(c.Context(), s.db, subscription); err != nil {
+		return notFoundOr(c, err, "Failed to send test event")
+	}
+
+	delivery, err := InsertTestDelivery(c.Context(), s.db, subscription)
+	if err != nil {
+		log.Errorf("Failed to send test event: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to send test event",
+		})
+	}
+
+	responseStatus, deliveryErr := Send(c.Context(), s.client, subscription.URL, subscription.Secret, delivery)
+	if err := recordAttempt(c.Context(), s.db, delivery, responseStatus, deliveryErr, nil); err != nil {
+		log.Errorf("Failed to record test event: %s", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to send test event",
+		})
+	}
+
+	if deliveryErr != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":    "Test event could not be delivered",
+			"delivery": delivery,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Test event delivered successfully",
+		"delivery": delivery,
+	})
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// AllowInternalTargets lets subscriptions reach loopback, link-local and
+// cloud metadata addresses. They are refused by default, so subscriptions
+// can't be used to probe the host or read instance credentials.
+var AllowInternalTargets = false
+
+var errInternalTarget = errors.New("url must not point to a loopback, link-local or metadata address")
+
+// metadataAddresses are cloud metadata services outside of the link-local
+// ranges.
+var metadataAddresses = []netip.Addr{
+	netip.MustParseAddr("fd00:ec2::254"),
+	netip.MustParseAddr("100.100.100.200"),
+}
+
+func isInternal(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsUnspecified() ||
+		slices.Contains(metadataAddresses, addr)
+}
+
+// validateTarget rejects subscriber hosts that are or resolve to internal
+// addresses. Hosts that can't be resolved right now are accepted, as every
+// connection checks the address it dials again.
+func validateTarget(ctx context.Context, host string) error {
+	if AllowInternalTargets {
+		return nil
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errInternalTarget
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if isInternal(addr) {
+			return errInternalTarget
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	if slices.ContainsFunc(addrs, isInternal) {
+		return errInternalTarget
+	}
+
+	return nil
+}
+
+// newClient returns the client deliveries are sent with. It refuses to
+// connect to internal addresses, also when a host only resolves to one after
+// its subscription was validated.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: refuseInternal,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+	}
+}
+
+// refuseInternal is called with the resolved address before every connection.
+func refuseInternal(network, address string, _ syscall.RawConn) error {
+	if AllowInternalTargets {
+		return nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if isInternal(addrPort.Addr()) {
+		return fmt.Errorf("refusing to connect to %s: %w", address, errInternalTarget)
+	}
+
+	return nil
+}
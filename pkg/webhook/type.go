@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+const (
+	EventDeviceCreated     = "device.created"
+	EventDeviceDeleted     = "device.deleted"
+	EventDeviceAssigned    = "device.assigned"
+	EventDeviceUnassigned  = "device.unassigned"
+	EventThresholdExceeded = "threshold.exceeded"
+	// EventTest is only sent by the test action and cannot be subscribed to.
+	EventTest = "webhook.test"
+)
+
+var knownEvents = []string{
+	EventDeviceCreated,
+	EventDeviceDeleted,
+	EventDeviceAssigned,
+	EventDeviceUnassigned,
+	EventThresholdExceeded,
+}
+
+func IsKnownEvent(event string) bool {
+	return slices.Contains(knownEvents, event)
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Subscription receives the events it is subscribed to at its URL. Requests
+// are signed with its secret, see package signature.
+type Subscription struct {
+	ID          int       `json:"id" db:"id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	URL         string    `json:"url" db:"url"`
+	Description *string   `json:"description" db:"description"`
+	Events      []string  `json:"events" db:"events"`
+	Secret      string    `json:"-" db:"secret"`
+	Enabled     bool      `json:"enabled" db:"enabled"`
+}
+
+// SubscriptionUpdate holds the fields of a partial update, nil fields are
+// left untouched.
+type SubscriptionUpdate struct {
+	URL         *string   `json:"url"`
+	Description *string   `json:"description"`
+	Events      *[]string `json:"events"`
+	Enabled     *bool     `json:"enabled"`
+}
+
+type Delivery struct {
+	ID             int64           `json:"id" db:"id"`
+	SubscriptionID int             `json:"subscription_id" db:"subscription_id"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	Event          string          `json:"event" db:"event"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status" db:"response_status"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+}
+
+// Event is the body posted to subscribers.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}